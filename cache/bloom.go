@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"context"
+	"hash/fnv"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// BloomOptions defines the options of a bloom filter.
+type BloomOptions struct {
+	// Capacity is the number of items expected, defaults to 10000. Once it's reached a new layer is added to the filter,
+	// so that it keeps growing with the false positive rate bounded.
+	Capacity int
+	// ErrorRate is the false positive rate wanted, defaults to 0.01.
+	ErrorRate float64
+	// Growth is how many times larger each new layer is than the previous one, defaults to 2.
+	Growth int
+	// TighteningRatio is how much lower the false positive rate of each new layer is, defaults to 0.5.
+	// The false positive rate of the whole filter stays below ErrorRate / (1 - TighteningRatio).
+	TighteningRatio float64
+}
+
+func (options *BloomOptions) setDefaults() {
+	if options.Capacity <= 0 {
+		options.Capacity = 10000
+	}
+	if options.ErrorRate <= 0 || options.ErrorRate >= 1 {
+		options.ErrorRate = 0.01
+	}
+	if options.Growth < 1 {
+		options.Growth = 2
+	}
+	if options.TighteningRatio <= 0 || options.TighteningRatio >= 1 {
+		options.TighteningRatio = 0.5
+	}
+}
+
+// bloomLayersLua finds the layers of the filter KEYS[1] described by the hash KEYS[2], the options are read from the hash,
+// or from ARGV[1:4] for a new filter, found tells whether the item hashed to ARGV[5] and ARGV[6] is in one of them.
+// A layer is sized as m = -n * ln(p) / ln(2)^2 with k = log2(1 / p) hash positions, the positions are h1 + i * h2 mod m.
+const bloomLayersLua = `
+local meta = redis.call("HMGET", KEYS[2], "capacity", "error_rate", "growth", "ratio", "layers", "count")
+local new = not meta[1]
+if new then
+	meta = {ARGV[1], ARGV[2], ARGV[3], ARGV[4], 1, 0}
+end
+local capacity, rate, growth, ratio = tonumber(meta[1]), tonumber(meta[2]), tonumber(meta[3]), tonumber(meta[4])
+local layers, count = tonumber(meta[5]), tonumber(meta[6])
+local h1, h2 = tonumber(ARGV[5]), tonumber(ARGV[6])
+
+local function layer(i)
+	local n, p = capacity * growth ^ i, rate * ratio ^ i
+	return n, math.ceil(-n * math.log(p) / math.log(2) ^ 2), math.ceil(-math.log(p) / math.log(2))
+end
+
+local function position(offset, size, i)
+	local bit = (h1 + i * h2) % size
+	if bit < 0 then
+		bit = bit + size
+	end
+	return offset + bit
+end
+
+local function check(offset, size, hashes)
+	for i = 0, hashes - 1 do
+		if redis.call("GETBIT", KEYS[1], position(offset, size, i)) == 0 then
+			return false
+		end
+	end
+	return true
+end
+
+local found, offset, items, size, hashes = false, 0
+for i = 0, layers - 1 do
+	items, size, hashes = layer(i)
+	if check(offset, size, hashes) then
+		found = true
+		break
+	end
+	if i < layers - 1 then
+		offset = offset + size
+	end
+end
+`
+
+var (
+	// bloomAddScript adds the item hashed to ARGV[5] and ARGV[6] to the filter, it returns 1 if the item is new,
+	// 0 if it may have been added already. A layer is added once the last one holds its capacity.
+	bloomAddScript = redis.NewScript(2, bloomLayersLua+`
+if found then
+	return 0
+end
+if count >= items then
+	offset = offset + size
+	layers = layers + 1
+	count = 0
+	items, size, hashes = layer(layers - 1)
+	if offset + size > 4294967296 then
+		return redis.error_reply("cache: the bloom filter is full")
+	end
+end
+for i = 0, hashes - 1 do
+	redis.call("SETBIT", KEYS[1], position(offset, size, i), 1)
+end
+if new then
+	redis.call("HSET", KEYS[2], "capacity", ARGV[1], "error_rate", ARGV[2], "growth", ARGV[3], "ratio", ARGV[4])
+end
+redis.call("HSET", KEYS[2], "layers", layers, "count", count + 1)
+redis.call("HINCRBY", KEYS[2], "items", 1)
+return 1`)
+	// bloomExistsScript returns 1 if the item hashed to ARGV[5] and ARGV[6] may be in the filter, 0 if it isn't.
+	bloomExistsScript = redis.NewScript(2, bloomLayersLua+`
+if found then
+	return 1
+end
+return 0`)
+)
+
+// BloomFilter tells whether an item may have been added, with false positives but no false negatives.
+// It's stored in a bitmap, and it scales by adding layers to the bitmap as it fills up.
+type BloomFilter struct {
+	cacher  *RedisCacher
+	key     string
+	meta    string
+	options BloomOptions
+}
+
+// NewBloomFilter creates a bloom filter stored in the bitmap key, its options and layers are stored in the hash "<key>:meta".
+// The options are fixed by the first item added, the ones of the later filters on the same key are ignored.
+// In the cluster mode, key should have a hash tag, e.g. "{visitors}", so that both keys are in the same slot.
+func (o *RedisCacher) NewBloomFilter(key string, options BloomOptions) *BloomFilter {
+	options.setDefaults()
+	return &BloomFilter{cacher: o, key: key, meta: key + ":meta", options: options}
+}
+
+// Add adds item to the filter, it returns false if the item may have been added already.
+func (b *BloomFilter) Add(ctx context.Context, item string) (bool, error) {
+	return redis.Bool(b.cacher.RunScript(ctx, bloomAddScript, b.args(item)...))
+}
+
+// Exists tells whether item may have been added to the filter, it's false if it hasn't for sure.
+func (b *BloomFilter) Exists(ctx context.Context, item string) (bool, error) {
+	return redis.Bool(b.cacher.RunScript(ctx, bloomExistsScript, b.args(item)...))
+}
+
+// Count gets the number of items added to the filter, the ones found in it already aren't counted.
+func (b *BloomFilter) Count(ctx context.Context) (int, error) {
+	count, err := redis.Int(b.cacher.DoContext(ctx, "HGET", b.meta, "items"))
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+	return count, err
+}
+
+// Delete deletes the filter.
+func (b *BloomFilter) Delete(ctx context.Context) error {
+	_, err := b.cacher.DoContext(ctx, "DEL", b.key, b.meta)
+	return err
+}
+
+// args gets the keys and arguments of the scripts for item, the 64 bits FNV-1a hash of the item is mixed and split into
+// the two 32 bits hashes the positions are derived from, the second one is odd so that it's never 0.
+func (b *BloomFilter) args(item string) []interface{} {
+	h := fnv.New64a()
+	h.Write([]byte(item))
+	// The finalizer of MurmurHash3 spreads the bits, FNV-1a alone is poorly distributed for the items alike.
+	sum := h.Sum64()
+	sum ^= sum >> 33
+	sum *= 0xff51afd7ed558ccd
+	sum ^= sum >> 33
+	sum *= 0xc4ceb9fe1a85ec53
+	sum ^= sum >> 33
+	return []interface{}{b.key, b.meta, b.options.Capacity, b.options.ErrorRate, b.options.Growth, b.options.TighteningRatio,
+		sum & 0xffffffff, sum>>32 | 1}
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBloomFilter(t *testing.T) {
+	Convey("Test The Bloom Filter\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+		ctx := context.Background()
+
+		Convey("The Items Added Should Exist", func() {
+			filter := cacher.NewBloomFilter("seen", BloomOptions{Capacity: 100})
+			exists, err := filter.Exists(ctx, "alice")
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+
+			added, err := filter.Add(ctx, "alice")
+			So(err, ShouldBeNil)
+			So(added, ShouldBeTrue)
+			added, _ = filter.Add(ctx, "alice")
+			So(added, ShouldBeFalse)
+			exists, _ = filter.Exists(ctx, "alice")
+			So(exists, ShouldBeTrue)
+			count, _ := filter.Count(ctx)
+			So(count, ShouldEqual, 1)
+
+			So(filter.Delete(ctx), ShouldBeNil)
+			exists, _ = filter.Exists(ctx, "alice")
+			So(exists, ShouldBeFalse)
+			count, err = filter.Count(ctx)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 0)
+		})
+
+		Convey("The Filter Should Grow With A Bounded False Positive Rate", func() {
+			filter := cacher.NewBloomFilter("seen", BloomOptions{Capacity: 100, ErrorRate: 0.01})
+			added := 0
+			for i := 0; i < 1000; i++ {
+				if ok, _ := filter.Add(ctx, fmt.Sprintf("item%d", i)); ok {
+					added++
+				}
+			}
+			So(added, ShouldBeGreaterThan, 980)
+			count, _ := filter.Count(ctx)
+			So(count, ShouldEqual, added)
+			layers, _ := redis.Int(cacher.HGet("seen:meta", "layers"))
+			So(layers, ShouldEqual, 4)
+
+			falsePositives := 0
+			for i := 0; i < 1000; i++ {
+				exists, err := filter.Exists(ctx, fmt.Sprintf("item%d", i))
+				So(err, ShouldBeNil)
+				So(exists, ShouldBeTrue)
+				if exists, _ = filter.Exists(ctx, fmt.Sprintf("other%d", i)); exists {
+					falsePositives++
+				}
+			}
+			So(falsePositives, ShouldBeLessThan, 40)
+
+			// The options of the existing filter are kept.
+			other := cacher.NewBloomFilter("seen", BloomOptions{Capacity: 1})
+			exists, _ := other.Exists(ctx, "item999")
+			So(exists, ShouldBeTrue)
+		})
+	})
+}
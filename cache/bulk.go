@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+)
+
+var errInvalidMapDst = errors.New("cache: the destination must be a map with string keys or a pointer to one")
+
+// Item is a value to set along with its own ttl, a ttl <= 0 means the key doesn't expire.
+type Item struct {
+	Value interface{}
+	TTL   time.Duration
+}
+
+// MultipleSet sets the key value pairs in one round trip, they all expire after ttl, a ttl <= 0 means they don't expire.
+// The values are written as is, like Set.
+func (o *RedisCacher) MultipleSet(values map[string]interface{}, ttl time.Duration) error {
+	return o.MultipleSetContext(context.Background(), values, ttl)
+}
+
+// MultipleSetContext sets the key value pairs in one round trip with context.
+func (o *RedisCacher) MultipleSetContext(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	items := make(map[string]Item, len(values))
+	for key, value := range values {
+		items[key] = Item{Value: value, TTL: ttl}
+	}
+	return o.MultipleSetItemsContext(ctx, items)
+}
+
+// MultipleSetItems sets the items in one round trip, each with its own ttl.
+func (o *RedisCacher) MultipleSetItems(items map[string]Item) error {
+	return o.MultipleSetItemsContext(context.Background(), items)
+}
+
+// MultipleSetItemsContext sets the items in one round trip with context, each with its own ttl.
+func (o *RedisCacher) MultipleSetItemsContext(ctx context.Context, items map[string]Item) error {
+	pipeline := o.Pipeline()
+	for key, item := range items {
+		if item.TTL > 0 {
+			pipeline.Queue("SET", key, item.Value, "PX", durationMilliseconds(item.TTL))
+		} else {
+			pipeline.Queue("SET", key, item.Value)
+		}
+	}
+	_, err := pipeline.Exec(ctx)
+	return err
+}
+
+// MultipleGetGob gets the gob encoded values for keys in bulk, the keys which don't exist are returned as missed.
+func (o *RedisCacher) MultipleGetGob(keys ...string) (values map[string]interface{}, missed []string, err error) {
+	return o.MultipleGetGobContext(context.Background(), keys...)
+}
+
+// MultipleGetGobContext gets the gob encoded values for keys in bulk with context.
+func (o *RedisCacher) MultipleGetGobContext(ctx context.Context, keys ...string) (values map[string]interface{}, missed []string, err error) {
+	// A key which doesn't exist is a nil slice.
+	replies, err := o.MultipleGetJSONContext(ctx, keys...)
+	if err != nil {
+		return nil, nil, err
+	}
+	values = make(map[string]interface{}, len(keys))
+	for i, b := range replies {
+		if b == nil {
+			missed = append(missed, keys[i])
+			continue
+		}
+		if values[keys[i]], err = decodeGob(b); err != nil {
+			return nil, nil, err
+		}
+	}
+	return values, missed, nil
+}
+
+// MultipleGetJSONInto gets the json values for keys in bulk and decodes them into dst,
+// which must be a map with string keys, e.g. map[string]User, or a pointer to one.
+// The keys which don't exist are returned as missed and left out of dst.
+func (o *RedisCacher) MultipleGetJSONInto(dst interface{}, keys ...string) (missed []string, err error) {
+	return o.MultipleGetJSONIntoContext(context.Background(), dst, keys...)
+}
+
+// MultipleGetJSONIntoContext gets the json values for keys in bulk with context and decodes them into dst.
+func (o *RedisCacher) MultipleGetJSONIntoContext(ctx context.Context, dst interface{}, keys ...string) (missed []string, err error) {
+	m := reflect.ValueOf(dst)
+	if m.Kind() == reflect.Ptr {
+		m = m.Elem()
+	}
+	if m.Kind() != reflect.Map || m.Type().Key().Kind() != reflect.String {
+		return nil, errInvalidMapDst
+	}
+	if m.IsNil() {
+		if !m.CanSet() {
+			return nil, errInvalidMapDst
+		}
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+
+	replies, err := o.MultipleGetJSONContext(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+	for i, b := range replies {
+		if b == nil {
+			missed = append(missed, keys[i])
+			continue
+		}
+		value := reflect.New(m.Type().Elem())
+		if err := json.Unmarshal(b, value.Interface()); err != nil {
+			return nil, err
+		}
+		m.SetMapIndex(reflect.ValueOf(keys[i]).Convert(m.Type().Key()), value.Elem())
+	}
+	return missed, nil
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type bulkUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestBulk(t *testing.T) {
+	Convey("Test Bulk Sets And Gets\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		Convey("MultipleSet Should Set Every Key With The TTL", func() {
+			err := cacher.MultipleSet(map[string]interface{}{"key1": "value1", "key2": 2}, time.Minute)
+			So(err, ShouldBeNil)
+			values, err := redis.Strings(cacher.MultipleGet("key1", "key2"))
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, []string{"value1", "2"})
+			ttl, err := cacher.TTL("key1")
+			So(err, ShouldBeNil)
+			So(ttl, ShouldBeBetweenOrEqual, 59, 60)
+
+			So(cacher.MultipleSet(map[string]interface{}{"key3": 3}, 0), ShouldBeNil)
+			ttl, _ = cacher.TTL("key3")
+			So(ttl, ShouldEqual, -1)
+			So(cacher.MultipleSet(nil, time.Minute), ShouldBeNil)
+		})
+
+		Convey("MultipleSetItems Should Set A TTL Per Key", func() {
+			err := cacher.MultipleSetItems(map[string]Item{
+				"key1": {Value: "value1", TTL: time.Minute},
+				"key2": {Value: "value2", TTL: time.Hour},
+				"key3": {Value: "value3"},
+			})
+			So(err, ShouldBeNil)
+			ttl, _ := cacher.TTL("key1")
+			So(ttl, ShouldBeBetweenOrEqual, 59, 60)
+			ttl, _ = cacher.TTL("key2")
+			So(ttl, ShouldBeBetweenOrEqual, 3599, 3600)
+			ttl, _ = cacher.TTL("key3")
+			So(ttl, ShouldEqual, -1)
+		})
+
+		Convey("MultipleGetGob Should Decode The Values And Report Misses", func() {
+			So(cacher.SetGob("key1", "value1"), ShouldBeNil)
+			So(cacher.SetGob("key2", 2), ShouldBeNil)
+			values, missed, err := cacher.MultipleGetGob("key1", "notExist", "key2")
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, map[string]interface{}{"key1": "value1", "key2": 2})
+			So(missed, ShouldResemble, []string{"notExist"})
+
+			So(cacher.Set("key3", "not gob"), ShouldBeNil)
+			_, _, err = cacher.MultipleGetGob("key3")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("MultipleGetJSONInto Should Decode The Values Into A Map", func() {
+			So(cacher.SetJSON("user:1", bulkUser{Name: "alice", Age: 30}), ShouldBeNil)
+			So(cacher.SetJSON("user:2", bulkUser{Name: "bob", Age: 40}), ShouldBeNil)
+
+			var users map[string]bulkUser
+			missed, err := cacher.MultipleGetJSONInto(&users, "user:1", "user:2", "user:3")
+			So(err, ShouldBeNil)
+			So(missed, ShouldResemble, []string{"user:3"})
+			So(users, ShouldResemble, map[string]bulkUser{"user:1": {"alice", 30}, "user:2": {"bob", 40}})
+
+			pointers := make(map[string]*bulkUser)
+			missed, err = cacher.MultipleGetJSONInto(pointers, "user:1")
+			So(err, ShouldBeNil)
+			So(missed, ShouldBeEmpty)
+			So(pointers["user:1"].Name, ShouldEqual, "alice")
+
+			_, err = cacher.MultipleGetJSONInto(users, "user:1")
+			So(err, ShouldBeNil)
+			var nilMap map[string]bulkUser
+			_, err = cacher.MultipleGetJSONInto(nilMap, "user:1")
+			So(err, ShouldEqual, errInvalidMapDst)
+			_, err = cacher.MultipleGetJSONInto(&[]bulkUser{}, "user:1")
+			So(err, ShouldEqual, errInvalidMapDst)
+		})
+	})
+}
@@ -0,0 +1,1127 @@
+package cachetest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cache/internal/glob"
+)
+
+var (
+	errWrongType   = errorReply("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errNotInteger  = errorReply("ERR value is not an integer or out of range")
+	errNotFloat    = errorReply("ERR value is not a valid float")
+	errSyntax      = errorReply("ERR syntax error")
+	errOverflow    = errorReply("ERR increment or decrement would overflow")
+	errNoAuth      = errorReply("NOAUTH Authentication required.")
+	errWrongPass   = errorReply("WRONGPASS invalid username-password pair or user is disabled.")
+	errHashInteger = errorReply("ERR hash value is not an integer")
+	errHashFloat   = errorReply("ERR hash value is not a float")
+)
+
+// command is a command of the server.
+type command struct {
+	run func(s *Server, c *client, args [][]byte) interface{}
+	// arity is the number of arguments including the command name, -n means at least n.
+	arity int
+	// subscribed tells whether the command is allowed while the client is subscribed.
+	subscribed bool
+	// transaction tells whether the command controls a transaction, it's run even within MULTI.
+	transaction bool
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		// Connection
+		"ping":   {run: cmdPing, arity: -1, subscribed: true},
+		"echo":   {run: cmdEcho, arity: 2},
+		"auth":   {run: cmdAuth, arity: -2},
+		"select": {run: cmdSelect, arity: 2},
+		"quit":   {run: cmdQuit, arity: -1, subscribed: true},
+		"client": {run: cmdClient, arity: -2},
+
+		// Keys
+		"del":       {run: cmdDel, arity: -2},
+		"unlink":    {run: cmdDel, arity: -2},
+		"exists":    {run: cmdExists, arity: -2},
+		"type":      {run: cmdType, arity: 2},
+		"expire":    {run: cmdExpire(time.Second, false), arity: 3},
+		"pexpire":   {run: cmdExpire(time.Millisecond, false), arity: 3},
+		"expireat":  {run: cmdExpire(time.Second, true), arity: 3},
+		"pexpireat": {run: cmdExpire(time.Millisecond, true), arity: 3},
+		"ttl":       {run: cmdTTL(time.Second), arity: 2},
+		"pttl":      {run: cmdTTL(time.Millisecond), arity: 2},
+		"persist":   {run: cmdPersist, arity: 2},
+		"keys":      {run: cmdKeys, arity: 2},
+		"scan":      {run: cmdScan, arity: -2},
+		"dbsize":    {run: cmdDBSize, arity: 1},
+		"flushdb":   {run: cmdFlushDB, arity: -1},
+		"flushall":  {run: cmdFlushAll, arity: -1},
+
+		// Strings
+		"get":         {run: cmdGet, arity: 2},
+		"set":         {run: cmdSet, arity: -3},
+		"setex":       {run: cmdSetEx(time.Second), arity: 4},
+		"psetex":      {run: cmdSetEx(time.Millisecond), arity: 4},
+		"setnx":       {run: cmdSetNX, arity: 3},
+		"getset":      {run: cmdGetSet, arity: 3},
+		"getdel":      {run: cmdGetDel, arity: 2},
+		"getex":       {run: cmdGetEx, arity: -2},
+		"mget":        {run: cmdMGet, arity: -2},
+		"mset":        {run: cmdMSet, arity: -3},
+		"msetnx":      {run: cmdMSetNX, arity: -3},
+		"incr":        {run: cmdIncr(1), arity: 2},
+		"decr":        {run: cmdIncr(-1), arity: 2},
+		"incrby":      {run: cmdIncrBy(1), arity: 3},
+		"decrby":      {run: cmdIncrBy(-1), arity: 3},
+		"incrbyfloat": {run: cmdIncrByFloat, arity: 3},
+		"append":      {run: cmdAppend, arity: 3},
+		"strlen":      {run: cmdStrlen, arity: 2},
+
+		// Hashes
+		"hset":         {run: cmdHSet, arity: -4},
+		"hmset":        {run: cmdHMSet, arity: -4},
+		"hsetnx":       {run: cmdHSetNX, arity: 4},
+		"hget":         {run: cmdHGet, arity: 3},
+		"hmget":        {run: cmdHMGet, arity: -3},
+		"hgetall":      {run: cmdHGetAll, arity: 2},
+		"hdel":         {run: cmdHDel, arity: -3},
+		"hexists":      {run: cmdHExists, arity: 3},
+		"hlen":         {run: cmdHLen, arity: 2},
+		"hkeys":        {run: cmdHKeys, arity: 2},
+		"hvals":        {run: cmdHVals, arity: 2},
+		"hincrby":      {run: cmdHIncrBy, arity: 4},
+		"hincrbyfloat": {run: cmdHIncrByFloat, arity: 4},
+
+		// Pub/sub
+		"subscribe":    {run: cmdSubscribe, arity: -2, subscribed: true},
+		"unsubscribe":  {run: cmdUnsubscribe, arity: -1, subscribed: true},
+		"psubscribe":   {run: cmdPSubscribe, arity: -2, subscribed: true},
+		"punsubscribe": {run: cmdPUnsubscribe, arity: -1, subscribed: true},
+		"publish":      {run: cmdPublish, arity: 3},
+		"pubsub":       {run: cmdPubSub, arity: -2},
+
+		// Transactions
+		"multi":   {run: cmdMulti, arity: 1, transaction: true},
+		"exec":    {run: cmdExec, arity: 1, transaction: true},
+		"discard": {run: cmdDiscard, arity: 1, transaction: true},
+		"watch":   {run: cmdWatch, arity: -2, transaction: true},
+		"unwatch": {run: cmdUnwatch, arity: 1},
+	}
+}
+
+// run runs a command for the client, mu must be held.
+func (s *Server) run(c *client, args [][]byte) interface{} {
+	name := strings.ToLower(string(args[0]))
+	cmd, found := commands[name]
+	if !found {
+		c.dirty = c.multi
+		return errorReply(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		c.dirty = c.multi
+		return errorReply(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+	}
+	if s.password != "" && !c.authenticated && name != "auth" && name != "quit" {
+		c.dirty = c.multi
+		return errNoAuth
+	}
+	if c.subscriptions() > 0 && !cmd.subscribed {
+		return errorReply(fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", name))
+	}
+	if c.multi && !cmd.transaction {
+		c.queued = append(c.queued, args)
+		return Status("QUEUED")
+	}
+	return cmd.run(s, c, args[1:])
+}
+
+func (s *Server) db(c *client) *db {
+	return s.dbs[c.db]
+}
+
+func parseInt(b []byte) (int64, bool) {
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	return n, err == nil
+}
+
+func parseFloat(b []byte) (float64, bool) {
+	f, err := strconv.ParseFloat(string(b), 64)
+	return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+func formatFloat(f float64) []byte {
+	return []byte(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// bulks converts strings to an array of bulk strings.
+func bulks(strs []string) []interface{} {
+	values := make([]interface{}, len(strs))
+	for i, str := range strs {
+		values[i] = str
+	}
+	return values
+}
+
+// expireTime converts the argument of EX, PX, EXAT or PXAT to a time.
+func expireTime(now time.Time, option string, n int64) time.Time {
+	switch option {
+	case "EX":
+		return now.Add(time.Duration(n) * time.Second)
+	case "PX":
+		return now.Add(time.Duration(n) * time.Millisecond)
+	case "EXAT":
+		return time.Unix(n, 0)
+	default:
+		return time.Unix(0, n*int64(time.Millisecond))
+	}
+}
+
+func cmdPing(s *Server, c *client, args [][]byte) interface{} {
+	if len(args) > 1 {
+		return errorReply("ERR wrong number of arguments for 'ping' command")
+	}
+	if c.subscriptions() > 0 {
+		message := []byte{}
+		if len(args) == 1 {
+			message = args[0]
+		}
+		return []interface{}{"pong", message}
+	}
+	if len(args) == 1 {
+		return args[0]
+	}
+	return Status("PONG")
+}
+
+func cmdEcho(s *Server, c *client, args [][]byte) interface{} {
+	return args[0]
+}
+
+func cmdAuth(s *Server, c *client, args [][]byte) interface{} {
+	if len(args) > 2 {
+		return errSyntax
+	}
+	if s.password == "" {
+		return errorReply("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+	}
+	if (len(args) == 2 && string(args[0]) != "default") || string(args[len(args)-1]) != s.password {
+		return errWrongPass
+	}
+	c.authenticated = true
+	return ok
+}
+
+func cmdSelect(s *Server, c *client, args [][]byte) interface{} {
+	index, valid := parseInt(args[0])
+	if !valid {
+		return errNotInteger
+	}
+	if index < 0 || index >= databases {
+		return errorReply("ERR DB index is out of range")
+	}
+	c.db = int(index)
+	return ok
+}
+
+func cmdQuit(s *Server, c *client, args [][]byte) interface{} {
+	c.quit = true
+	return ok
+}
+
+func cmdClient(s *Server, c *client, args [][]byte) interface{} {
+	switch strings.ToUpper(string(args[0])) {
+	case "SETNAME":
+		if len(args) != 2 {
+			return errSyntax
+		}
+		c.name = string(args[1])
+		return ok
+	case "GETNAME":
+		if c.name == "" {
+			return nil
+		}
+		return c.name
+	}
+	return errorReply(fmt.Sprintf("ERR unknown subcommand '%s'", args[0]))
+}
+
+func cmdDel(s *Server, c *client, args [][]byte) interface{} {
+	db, now := s.db(c), s.now()
+	n := 0
+	for _, arg := range args {
+		key := string(arg)
+		if db.get(key, now) != nil {
+			delete(db.entries, key)
+			s.touch(db, key)
+			n++
+		}
+	}
+	return n
+}
+
+func cmdExists(s *Server, c *client, args [][]byte) interface{} {
+	db, now := s.db(c), s.now()
+	n := 0
+	for _, arg := range args {
+		if db.get(string(arg), now) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdType(s *Server, c *client, args [][]byte) interface{} {
+	e := s.db(c).get(string(args[0]), s.now())
+	if e == nil {
+		return Status("none")
+	}
+	return Status(e.kind())
+}
+
+// cmdExpire is EXPIRE and its variants, the argument is a number of units, from now or from the epoch if absolute.
+func cmdExpire(unit time.Duration, absolute bool) func(s *Server, c *client, args [][]byte) interface{} {
+	return func(s *Server, c *client, args [][]byte) interface{} {
+		n, valid := parseInt(args[1])
+		if !valid {
+			return errNotInteger
+		}
+		db, now, key := s.db(c), s.now(), string(args[0])
+		e := db.get(key, now)
+		if e == nil {
+			return 0
+		}
+		expireAt := now.Add(time.Duration(n) * unit)
+		if absolute {
+			expireAt = time.Unix(0, 0).Add(time.Duration(n) * unit)
+		}
+		if !expireAt.After(now) {
+			delete(db.entries, key)
+		} else {
+			e.expireAt = expireAt
+		}
+		s.touch(db, key)
+		return 1
+	}
+}
+
+func cmdTTL(unit time.Duration) func(s *Server, c *client, args [][]byte) interface{} {
+	return func(s *Server, c *client, args [][]byte) interface{} {
+		now := s.now()
+		e := s.db(c).get(string(args[0]), now)
+		switch {
+		case e == nil:
+			return -2
+		case e.expireAt.IsZero():
+			return -1
+		}
+		// Rounded like redis does.
+		return int64((e.expireAt.Sub(now) + unit/2) / unit)
+	}
+}
+
+func cmdPersist(s *Server, c *client, args [][]byte) interface{} {
+	db, key := s.db(c), string(args[0])
+	e := db.get(key, s.now())
+	if e == nil || e.expireAt.IsZero() {
+		return 0
+	}
+	e.expireAt = time.Time{}
+	s.touch(db, key)
+	return 1
+}
+
+func cmdKeys(s *Server, c *client, args [][]byte) interface{} {
+	pattern := string(args[0])
+	var keys []string
+	for _, key := range s.db(c).keys(s.now()) {
+		if glob.Match(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return bulks(keys)
+}
+
+// cmdScan visits the keys in the order of their scan positions and the cursor is the next position to visit,
+// so a key that exists during the whole iteration is always returned.
+func cmdScan(s *Server, c *client, args [][]byte) interface{} {
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		return errorReply("ERR invalid cursor")
+	}
+	pattern, count, kind := "*", int64(10), ""
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			pattern = string(args[i+1])
+		case "COUNT":
+			var valid bool
+			if count, valid = parseInt(args[i+1]); !valid {
+				return errNotInteger
+			}
+			if count < 1 {
+				return errSyntax
+			}
+		case "TYPE":
+			kind = strings.ToLower(string(args[i+1]))
+		default:
+			return errSyntax
+		}
+	}
+
+	type positionedKey struct {
+		position uint64
+		key      string
+	}
+	db, now := s.db(c), s.now()
+	var candidates []positionedKey
+	for _, key := range db.keys(now) {
+		if position := scanPosition(key); position >= cursor {
+			candidates = append(candidates, positionedKey{position, key})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].position == candidates[j].position {
+			return candidates[i].key < candidates[j].key
+		}
+		return candidates[i].position < candidates[j].position
+	})
+
+	keys := []string{}
+	next := uint64(0)
+	for i, candidate := range candidates {
+		// Never split the keys sharing a position between two pages.
+		if int64(i) >= count && candidate.position != candidates[i-1].position {
+			next = candidate.position
+			break
+		}
+		if glob.Match(pattern, candidate.key) && (kind == "" || db.entries[candidate.key].kind() == kind) {
+			keys = append(keys, candidate.key)
+		}
+	}
+	return []interface{}{strconv.FormatUint(next, 10), bulks(keys)}
+}
+
+func cmdDBSize(s *Server, c *client, args [][]byte) interface{} {
+	return len(s.db(c).keys(s.now()))
+}
+
+func cmdFlushDB(s *Server, c *client, args [][]byte) interface{} {
+	s.flush(s.db(c))
+	return ok
+}
+
+func cmdFlushAll(s *Server, c *client, args [][]byte) interface{} {
+	for _, db := range s.dbs {
+		s.flush(db)
+	}
+	return ok
+}
+
+func cmdGet(s *Server, c *client, args [][]byte) interface{} {
+	e, errReply := s.db(c).str(string(args[0]), s.now())
+	if errReply != nil {
+		return errReply
+	}
+	if e == nil {
+		return nil
+	}
+	return e.str
+}
+
+func cmdSet(s *Server, c *client, args [][]byte) interface{} {
+	key, value := string(args[0]), args[1]
+	var nx, xx, keepTTL, get bool
+	var expireAt time.Time
+	now := s.now()
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToUpper(string(args[i])); option {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "KEEPTTL":
+			keepTTL = true
+		case "GET":
+			get = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if i+1 >= len(args) || !expireAt.IsZero() {
+				return errSyntax
+			}
+			n, valid := parseInt(args[i+1])
+			if !valid {
+				return errNotInteger
+			}
+			if n <= 0 {
+				return errorReply("ERR invalid expire time in 'set' command")
+			}
+			expireAt = expireTime(now, option, n)
+			i++
+		default:
+			return errSyntax
+		}
+	}
+	if (nx && xx) || (keepTTL && !expireAt.IsZero()) {
+		return errSyntax
+	}
+
+	db := s.db(c)
+	e := db.get(key, now)
+	var previous interface{}
+	if get && e != nil {
+		if e.hash != nil {
+			return errWrongType
+		}
+		previous = e.str
+	}
+	if (nx && e != nil) || (xx && e == nil) {
+		return previous
+	}
+	if keepTTL && e != nil {
+		expireAt = e.expireAt
+	}
+	db.entries[key] = &entry{str: value, expireAt: expireAt}
+	s.touch(db, key)
+	if get {
+		return previous
+	}
+	return ok
+}
+
+func cmdSetEx(unit time.Duration) func(s *Server, c *client, args [][]byte) interface{} {
+	return func(s *Server, c *client, args [][]byte) interface{} {
+		n, valid := parseInt(args[1])
+		if !valid {
+			return errNotInteger
+		}
+		if n <= 0 {
+			return errorReply("ERR invalid expire time in 'setex' command")
+		}
+		db, key := s.db(c), string(args[0])
+		db.entries[key] = &entry{str: args[2], expireAt: s.now().Add(time.Duration(n) * unit)}
+		s.touch(db, key)
+		return ok
+	}
+}
+
+func cmdSetNX(s *Server, c *client, args [][]byte) interface{} {
+	db, key := s.db(c), string(args[0])
+	if db.get(key, s.now()) != nil {
+		return 0
+	}
+	db.entries[key] = &entry{str: args[1]}
+	s.touch(db, key)
+	return 1
+}
+
+func cmdGetSet(s *Server, c *client, args [][]byte) interface{} {
+	db, key := s.db(c), string(args[0])
+	e, errReply := db.str(key, s.now())
+	if errReply != nil {
+		return errReply
+	}
+	db.entries[key] = &entry{str: args[1]}
+	s.touch(db, key)
+	if e == nil {
+		return nil
+	}
+	return e.str
+}
+
+func cmdGetDel(s *Server, c *client, args [][]byte) interface{} {
+	db, key := s.db(c), string(args[0])
+	e, errReply := db.str(key, s.now())
+	if errReply != nil || e == nil {
+		return errReply
+	}
+	delete(db.entries, key)
+	s.touch(db, key)
+	return e.str
+}
+
+func cmdGetEx(s *Server, c *client, args [][]byte) interface{} {
+	db, key, now := s.db(c), string(args[0]), s.now()
+	var expireAt time.Time
+	persist := false
+	for i := 1; i < len(args); i++ {
+		switch option := strings.ToUpper(string(args[i])); option {
+		case "PERSIST":
+			persist = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if i+1 >= len(args) || !expireAt.IsZero() {
+				return errSyntax
+			}
+			n, valid := parseInt(args[i+1])
+			if !valid {
+				return errNotInteger
+			}
+			if n <= 0 {
+				return errorReply("ERR invalid expire time in 'getex' command")
+			}
+			expireAt = expireTime(now, option, n)
+			i++
+		default:
+			return errSyntax
+		}
+	}
+	if persist && !expireAt.IsZero() {
+		return errSyntax
+	}
+
+	e, errReply := db.str(key, now)
+	if errReply != nil || e == nil {
+		return errReply
+	}
+	if persist || !expireAt.IsZero() {
+		e.expireAt = expireAt
+		s.touch(db, key)
+	}
+	return e.str
+}
+
+func cmdMGet(s *Server, c *client, args [][]byte) interface{} {
+	db, now := s.db(c), s.now()
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		if e := db.get(string(arg), now); e != nil && e.hash == nil {
+			values[i] = e.str
+		}
+	}
+	return values
+}
+
+func cmdMSet(s *Server, c *client, args [][]byte) interface{} {
+	if len(args)%2 != 0 {
+		return errorReply("ERR wrong number of arguments for 'mset' command")
+	}
+	db := s.db(c)
+	for i := 0; i < len(args); i += 2 {
+		key := string(args[i])
+		db.entries[key] = &entry{str: args[i+1]}
+		s.touch(db, key)
+	}
+	return ok
+}
+
+func cmdMSetNX(s *Server, c *client, args [][]byte) interface{} {
+	if len(args)%2 != 0 {
+		return errorReply("ERR wrong number of arguments for 'msetnx' command")
+	}
+	db, now := s.db(c), s.now()
+	for i := 0; i < len(args); i += 2 {
+		if db.get(string(args[i]), now) != nil {
+			return 0
+		}
+	}
+	cmdMSet(s, c, args)
+	return 1
+}
+
+// incrBy adds delta to the integer at key.
+func (s *Server) incrBy(c *client, key string, delta int64) interface{} {
+	db := s.db(c)
+	e, errReply := db.str(key, s.now())
+	if errReply != nil {
+		return errReply
+	}
+	var n int64
+	if e != nil {
+		var valid bool
+		if n, valid = parseInt(e.str); !valid {
+			return errNotInteger
+		}
+	} else {
+		e = &entry{}
+		db.entries[key] = e
+	}
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return errOverflow
+	}
+	n += delta
+	e.str = []byte(strconv.FormatInt(n, 10))
+	s.touch(db, key)
+	return n
+}
+
+func cmdIncr(delta int64) func(s *Server, c *client, args [][]byte) interface{} {
+	return func(s *Server, c *client, args [][]byte) interface{} {
+		return s.incrBy(c, string(args[0]), delta)
+	}
+}
+
+func cmdIncrBy(sign int64) func(s *Server, c *client, args [][]byte) interface{} {
+	return func(s *Server, c *client, args [][]byte) interface{} {
+		delta, valid := parseInt(args[1])
+		if !valid || (sign < 0 && delta == math.MinInt64) {
+			return errNotInteger
+		}
+		return s.incrBy(c, string(args[0]), sign*delta)
+	}
+}
+
+func cmdIncrByFloat(s *Server, c *client, args [][]byte) interface{} {
+	delta, valid := parseFloat(args[1])
+	if !valid {
+		return errNotFloat
+	}
+	db, key := s.db(c), string(args[0])
+	e, errReply := db.str(key, s.now())
+	if errReply != nil {
+		return errReply
+	}
+	var f float64
+	if e != nil {
+		if f, valid = parseFloat(e.str); !valid {
+			return errNotFloat
+		}
+	} else {
+		e = &entry{}
+		db.entries[key] = e
+	}
+	e.str = formatFloat(f + delta)
+	s.touch(db, key)
+	return e.str
+}
+
+func cmdAppend(s *Server, c *client, args [][]byte) interface{} {
+	db, key := s.db(c), string(args[0])
+	e, errReply := db.str(key, s.now())
+	if errReply != nil {
+		return errReply
+	}
+	if e == nil {
+		e = &entry{}
+		db.entries[key] = e
+	}
+	e.str = append(append([]byte{}, e.str...), args[1]...)
+	s.touch(db, key)
+	return len(e.str)
+}
+
+func cmdStrlen(s *Server, c *client, args [][]byte) interface{} {
+	e, errReply := s.db(c).str(string(args[0]), s.now())
+	if errReply != nil {
+		return errReply
+	}
+	if e == nil {
+		return 0
+	}
+	return len(e.str)
+}
+
+// hset sets the field value pairs of args[1:], it returns the number of new fields.
+func (s *Server) hset(c *client, args [][]byte) (int, interface{}) {
+	if len(args)%2 != 1 {
+		return 0, errorReply("ERR wrong number of arguments for 'hset' command")
+	}
+	db, key := s.db(c), string(args[0])
+	e, errReply := db.hash(key, s.now(), true)
+	if errReply != nil {
+		return 0, errReply
+	}
+	n := 0
+	for i := 1; i < len(args); i += 2 {
+		field := string(args[i])
+		if _, exists := e.hash[field]; !exists {
+			n++
+		}
+		e.hash[field] = args[i+1]
+	}
+	s.touch(db, key)
+	return n, nil
+}
+
+func cmdHSet(s *Server, c *client, args [][]byte) interface{} {
+	n, errReply := s.hset(c, args)
+	if errReply != nil {
+		return errReply
+	}
+	return n
+}
+
+func cmdHMSet(s *Server, c *client, args [][]byte) interface{} {
+	if _, errReply := s.hset(c, args); errReply != nil {
+		return errReply
+	}
+	return ok
+}
+
+func cmdHSetNX(s *Server, c *client, args [][]byte) interface{} {
+	db, key := s.db(c), string(args[0])
+	e, errReply := db.hash(key, s.now(), true)
+	if errReply != nil {
+		return errReply
+	}
+	if _, exists := e.hash[string(args[1])]; exists {
+		return 0
+	}
+	e.hash[string(args[1])] = args[2]
+	s.touch(db, key)
+	return 1
+}
+
+func cmdHGet(s *Server, c *client, args [][]byte) interface{} {
+	e, errReply := s.db(c).hash(string(args[0]), s.now(), false)
+	if errReply != nil || e == nil {
+		return errReply
+	}
+	if value, exists := e.hash[string(args[1])]; exists {
+		return value
+	}
+	return nil
+}
+
+func cmdHMGet(s *Server, c *client, args [][]byte) interface{} {
+	e, errReply := s.db(c).hash(string(args[0]), s.now(), false)
+	if errReply != nil {
+		return errReply
+	}
+	values := make([]interface{}, len(args)-1)
+	if e != nil {
+		for i, field := range args[1:] {
+			if value, exists := e.hash[string(field)]; exists {
+				values[i] = value
+			}
+		}
+	}
+	return values
+}
+
+// sortedFields gets the fields of a hash entry in order, so that the replies are deterministic.
+func sortedFields(e *entry) []string {
+	if e == nil {
+		return nil
+	}
+	fields := make([]string, 0, len(e.hash))
+	for field := range e.hash {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func cmdHGetAll(s *Server, c *client, args [][]byte) interface{} {
+	e, errReply := s.db(c).hash(string(args[0]), s.now(), false)
+	if errReply != nil {
+		return errReply
+	}
+	values := []interface{}{}
+	for _, field := range sortedFields(e) {
+		values = append(values, field, e.hash[field])
+	}
+	return values
+}
+
+func cmdHDel(s *Server, c *client, args [][]byte) interface{} {
+	db, key := s.db(c), string(args[0])
+	e, errReply := db.hash(key, s.now(), false)
+	if errReply != nil {
+		return errReply
+	}
+	if e == nil {
+		return 0
+	}
+	n := 0
+	for _, field := range args[1:] {
+		if _, exists := e.hash[string(field)]; exists {
+			delete(e.hash, string(field))
+			n++
+		}
+	}
+	if len(e.hash) == 0 {
+		delete(db.entries, key)
+	}
+	if n > 0 {
+		s.touch(db, key)
+	}
+	return n
+}
+
+func cmdHExists(s *Server, c *client, args [][]byte) interface{} {
+	e, errReply := s.db(c).hash(string(args[0]), s.now(), false)
+	if errReply != nil {
+		return errReply
+	}
+	if e != nil {
+		if _, exists := e.hash[string(args[1])]; exists {
+			return 1
+		}
+	}
+	return 0
+}
+
+func cmdHLen(s *Server, c *client, args [][]byte) interface{} {
+	e, errReply := s.db(c).hash(string(args[0]), s.now(), false)
+	if errReply != nil {
+		return errReply
+	}
+	if e == nil {
+		return 0
+	}
+	return len(e.hash)
+}
+
+func cmdHKeys(s *Server, c *client, args [][]byte) interface{} {
+	e, errReply := s.db(c).hash(string(args[0]), s.now(), false)
+	if errReply != nil {
+		return errReply
+	}
+	return bulks(sortedFields(e))
+}
+
+func cmdHVals(s *Server, c *client, args [][]byte) interface{} {
+	e, errReply := s.db(c).hash(string(args[0]), s.now(), false)
+	if errReply != nil {
+		return errReply
+	}
+	values := []interface{}{}
+	for _, field := range sortedFields(e) {
+		values = append(values, e.hash[field])
+	}
+	return values
+}
+
+func cmdHIncrBy(s *Server, c *client, args [][]byte) interface{} {
+	delta, valid := parseInt(args[2])
+	if !valid {
+		return errNotInteger
+	}
+	db, key, field := s.db(c), string(args[0]), string(args[1])
+	e, errReply := db.hash(key, s.now(), true)
+	if errReply != nil {
+		return errReply
+	}
+	var n int64
+	if value, exists := e.hash[field]; exists {
+		if n, valid = parseInt(value); !valid {
+			return errHashInteger
+		}
+	}
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return errOverflow
+	}
+	n += delta
+	e.hash[field] = []byte(strconv.FormatInt(n, 10))
+	s.touch(db, key)
+	return n
+}
+
+func cmdHIncrByFloat(s *Server, c *client, args [][]byte) interface{} {
+	delta, valid := parseFloat(args[2])
+	if !valid {
+		return errNotFloat
+	}
+	db, key, field := s.db(c), string(args[0]), string(args[1])
+	e, errReply := db.hash(key, s.now(), true)
+	if errReply != nil {
+		return errReply
+	}
+	var f float64
+	if value, exists := e.hash[field]; exists {
+		if f, valid = parseFloat(value); !valid {
+			return errHashFloat
+		}
+	}
+	e.hash[field] = formatFloat(f + delta)
+	s.touch(db, key)
+	return e.hash[field]
+}
+
+// subscribe subscribes the client to the channels or patterns in args.
+func (s *Server) subscribe(c *client, kind string, own map[string]bool, all map[string]map[*client]bool, args [][]byte) interface{} {
+	var r replies
+	for _, arg := range args {
+		name := string(arg)
+		if !own[name] {
+			own[name] = true
+			if all[name] == nil {
+				all[name] = make(map[*client]bool)
+			}
+			all[name][c] = true
+		}
+		r = append(r, []interface{}{kind, name, c.subscriptions()})
+	}
+	return r
+}
+
+// unsubscribe unsubscribes the client from the channels or patterns in args, from all of them if args is empty.
+func (s *Server) unsubscribe(c *client, kind string, own map[string]bool, all map[string]map[*client]bool, args [][]byte) interface{} {
+	var names []string
+	for _, arg := range args {
+		names = append(names, string(arg))
+	}
+	if len(args) == 0 {
+		for name := range own {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return []interface{}{kind, nil, c.subscriptions()}
+		}
+	}
+
+	var r replies
+	for _, name := range names {
+		delete(own, name)
+		if subscribers := all[name]; subscribers != nil {
+			delete(subscribers, c)
+			if len(subscribers) == 0 {
+				delete(all, name)
+			}
+		}
+		r = append(r, []interface{}{kind, name, c.subscriptions()})
+	}
+	return r
+}
+
+// unsubscribeAll removes the subscriptions of a client which is gone.
+func (s *Server) unsubscribeAll(c *client) {
+	s.unsubscribe(c, "unsubscribe", c.channels, s.channels, nil)
+	s.unsubscribe(c, "punsubscribe", c.patterns, s.patterns, nil)
+}
+
+func cmdSubscribe(s *Server, c *client, args [][]byte) interface{} {
+	return s.subscribe(c, "subscribe", c.channels, s.channels, args)
+}
+
+func cmdUnsubscribe(s *Server, c *client, args [][]byte) interface{} {
+	return s.unsubscribe(c, "unsubscribe", c.channels, s.channels, args)
+}
+
+func cmdPSubscribe(s *Server, c *client, args [][]byte) interface{} {
+	return s.subscribe(c, "psubscribe", c.patterns, s.patterns, args)
+}
+
+func cmdPUnsubscribe(s *Server, c *client, args [][]byte) interface{} {
+	return s.unsubscribe(c, "punsubscribe", c.patterns, s.patterns, args)
+}
+
+func cmdPublish(s *Server, c *client, args [][]byte) interface{} {
+	channel, message := string(args[0]), args[1]
+	n := 0
+	for subscriber := range s.channels[channel] {
+		subscriber.send([]interface{}{"message", channel, message})
+		n++
+	}
+	for pattern, subscribers := range s.patterns {
+		if !glob.Match(pattern, channel) {
+			continue
+		}
+		for subscriber := range subscribers {
+			subscriber.send([]interface{}{"pmessage", pattern, channel, message})
+			n++
+		}
+	}
+	return n
+}
+
+func cmdPubSub(s *Server, c *client, args [][]byte) interface{} {
+	switch strings.ToUpper(string(args[0])) {
+	case "CHANNELS":
+		pattern := "*"
+		if len(args) > 2 {
+			return errSyntax
+		} else if len(args) == 2 {
+			pattern = string(args[1])
+		}
+		var channels []string
+		for channel := range s.channels {
+			if glob.Match(pattern, channel) {
+				channels = append(channels, channel)
+			}
+		}
+		sort.Strings(channels)
+		return bulks(channels)
+	case "NUMSUB":
+		values := []interface{}{}
+		for _, arg := range args[1:] {
+			values = append(values, arg, len(s.channels[string(arg)]))
+		}
+		return values
+	case "NUMPAT":
+		n := 0
+		for _, subscribers := range s.patterns {
+			n += len(subscribers)
+		}
+		return n
+	}
+	return errorReply(fmt.Sprintf("ERR unknown subcommand '%s'", args[0]))
+}
+
+func cmdMulti(s *Server, c *client, args [][]byte) interface{} {
+	if c.multi {
+		return errorReply("ERR MULTI calls can not be nested")
+	}
+	c.multi = true
+	return ok
+}
+
+// endTransaction clears the state of the transaction and the watched keys.
+func (c *client) endTransaction() {
+	c.multi = false
+	c.dirty = false
+	c.queued = nil
+	c.watched = nil
+}
+
+func cmdExec(s *Server, c *client, args [][]byte) interface{} {
+	if !c.multi {
+		return errorReply("ERR EXEC without MULTI")
+	}
+	queued, dirty, watched := c.queued, c.dirty, c.watched
+	c.endTransaction()
+	if dirty {
+		return errorReply("EXECABORT Transaction discarded because of previous errors.")
+	}
+	for key, version := range watched {
+		if s.dbs[key.db].versions[key.key] != version {
+			return nullArray{}
+		}
+	}
+
+	results := make([]interface{}, len(queued))
+	for i, args := range queued {
+		results[i] = s.run(c, args)
+	}
+	return results
+}
+
+func cmdDiscard(s *Server, c *client, args [][]byte) interface{} {
+	if !c.multi {
+		return errorReply("ERR DISCARD without MULTI")
+	}
+	c.endTransaction()
+	return ok
+}
+
+func cmdWatch(s *Server, c *client, args [][]byte) interface{} {
+	if c.multi {
+		return errorReply("ERR WATCH inside MULTI is not allowed")
+	}
+	if c.watched == nil {
+		c.watched = make(map[watchedKey]uint64)
+	}
+	db := s.db(c)
+	for _, arg := range args {
+		key := watchedKey{c.db, string(arg)}
+		if _, exists := c.watched[key]; !exists {
+			c.watched[key] = db.versions[key.key]
+		}
+	}
+	return ok
+}
+
+func cmdUnwatch(s *Server, c *client, args [][]byte) interface{} {
+	c.watched = nil
+	return ok
+}
@@ -0,0 +1,93 @@
+package cachetest
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// entry is the value of a key, either a string or a hash.
+type entry struct {
+	str      []byte
+	hash     map[string][]byte
+	expireAt time.Time
+}
+
+// kind gets the type of the entry as reported by TYPE.
+func (e *entry) kind() string {
+	if e.hash != nil {
+		return "hash"
+	}
+	return "string"
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+// db is a database selected by SELECT.
+type db struct {
+	entries map[string]*entry
+	// versions are bumped whenever a key is modified, for WATCH.
+	versions map[string]uint64
+}
+
+func newDB() *db {
+	return &db{entries: make(map[string]*entry), versions: make(map[string]uint64)}
+}
+
+// get gets the entry of key, nil if it doesn't exist, an expired key is removed.
+func (d *db) get(key string, now time.Time) *entry {
+	e, ok := d.entries[key]
+	if !ok {
+		return nil
+	}
+	if e.expired(now) {
+		delete(d.entries, key)
+		return nil
+	}
+	return e
+}
+
+// str gets the string entry of key, it returns an error reply if key holds another type.
+func (d *db) str(key string, now time.Time) (*entry, interface{}) {
+	e := d.get(key, now)
+	if e != nil && e.hash != nil {
+		return nil, errWrongType
+	}
+	return e, nil
+}
+
+// hash gets the hash entry of key, it's created if create is true and key doesn't exist.
+// It returns an error reply if key holds another type.
+func (d *db) hash(key string, now time.Time, create bool) (*entry, interface{}) {
+	e := d.get(key, now)
+	if e == nil {
+		if !create {
+			return nil, nil
+		}
+		e = &entry{hash: make(map[string][]byte)}
+		d.entries[key] = e
+	}
+	if e.hash == nil {
+		return nil, errWrongType
+	}
+	return e, nil
+}
+
+// keys gets the keys which haven't expired.
+func (d *db) keys(now time.Time) []string {
+	keys := make([]string, 0, len(d.entries))
+	for key, e := range d.entries {
+		if !e.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// scanPosition maps a key to a positive scan cursor, the keys are scanned in the order of their positions.
+func scanPosition(key string) uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return uint64(h.Sum32()) + 1
+}
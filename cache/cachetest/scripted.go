@@ -0,0 +1,104 @@
+package cachetest
+
+import (
+	"bufio"
+	"net"
+	"sync"
+)
+
+// ScriptedServer is a RESP server whose commands are all served by a handler, for the tests which need nodes
+// behaving in a specific way, e.g. the nodes of a cluster redirecting the keys or the sentinels.
+type ScriptedServer struct {
+	listener net.Listener
+	handler  func(c *Conn, args []string)
+	wg       sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[*Conn]bool
+}
+
+// NewScriptedServer starts a scripted server on a random loopback port, handler is called with the commands of
+// every connection in the order they are received and replies with Conn.Reply.
+func NewScriptedServer(handler func(c *Conn, args []string)) (*ScriptedServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &ScriptedServer{listener: listener, handler: handler, conns: make(map[*Conn]bool)}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr gets the address the server listens on.
+func (s *ScriptedServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and closes the connections of its clients.
+func (s *ScriptedServer) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *ScriptedServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &Conn{conn: conn, w: bufio.NewWriter(conn)}
+		s.mu.Lock()
+		s.conns[c] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serveConn(c)
+	}
+}
+
+func (s *ScriptedServer) serveConn(c *Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.conn.Close()
+	}()
+
+	r := bufio.NewReader(c.conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		strs := make([]string, len(args))
+		for i, arg := range args {
+			strs[i] = string(arg)
+		}
+		s.handler(c, strs)
+	}
+}
+
+// Conn is a client connection to a scripted server.
+type Conn struct {
+	mu   sync.Mutex
+	conn net.Conn
+	w    *bufio.Writer
+}
+
+// Reply writes a reply to the client, it can be called from other goroutines to push messages, e.g. for pub/sub.
+func (c *Conn) Reply(reply interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeReply(c.w, reply)
+	c.w.Flush()
+}
@@ -0,0 +1,373 @@
+// Package cachetest provides an in-process redis server, so that the code using the cache package can be tested without redis.
+//
+// The server speaks RESP2 on a loopback port and implements the commands RedisCacher uses:
+// strings, hashes, key expiration, SCAN, MGET, SELECT, FLUSHDB, transactions and pub/sub.
+// Time can be moved forward with FastForward to expire the keys without waiting.
+// A ScriptedServer serves every command with a handler instead, to fake e.g. cluster nodes or sentinels.
+package cachetest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// databases is the number of databases, as with the default redis configuration.
+const databases = 16
+
+// Server is an in-process redis server.
+type Server struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	// mu serializes the commands like the single thread of redis.
+	mu       sync.Mutex
+	dbs      [databases]*db
+	offset   time.Duration
+	password string
+	version  uint64
+	clients  map[*client]bool
+	channels map[string]map[*client]bool
+	patterns map[string]map[*client]bool
+}
+
+// NewServer starts a server on a random loopback port.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		listener: listener,
+		clients:  make(map[*client]bool),
+		channels: make(map[string]map[*client]bool),
+		patterns: make(map[string]map[*client]bool),
+	}
+	for i := range s.dbs {
+		s.dbs[i] = newDB()
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr gets the address the server listens on, e.g. to pass it to cache.NewRedisCacher.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and closes the connections of its clients.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for c := range s.clients {
+		c.conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// RequirePassword makes the clients authenticate with AUTH, an empty password disables the authentication.
+func (s *Server) RequirePassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// FastForward moves the clock of the server forward, the keys whose ttl has elapsed expire at once.
+func (s *Server) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset += d
+}
+
+// Now gets the time of the server, the real time moved forward by FastForward.
+func (s *Server) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now()
+}
+
+// FlushAll removes the keys of every database.
+func (s *Server) FlushAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, db := range s.dbs {
+		s.flush(db)
+	}
+}
+
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+// touch marks a key as modified for the clients watching it.
+func (s *Server) touch(db *db, key string) {
+	s.version++
+	db.versions[key] = s.version
+}
+
+// flush removes all the keys of db.
+func (s *Server) flush(db *db) {
+	for key := range db.entries {
+		s.touch(db, key)
+	}
+	db.entries = make(map[string]*entry)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := newClient(conn)
+		s.mu.Lock()
+		s.clients[c] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serveClient(c)
+	}
+}
+
+func (s *Server) serveClient(c *client) {
+	defer s.wg.Done()
+	written := make(chan struct{})
+	go func() {
+		c.writeLoop()
+		close(written)
+	}()
+	defer func() {
+		s.mu.Lock()
+		s.unsubscribeAll(c)
+		delete(s.clients, c)
+		s.mu.Unlock()
+		c.close()
+		<-written
+		c.conn.Close()
+	}()
+
+	r := bufio.NewReader(c.conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if _, ok := err.(protocolError); ok {
+				c.send(errorReply("ERR Protocol error: " + err.Error()))
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		s.mu.Lock()
+		c.send(s.run(c, args))
+		quit := c.quit
+		s.mu.Unlock()
+		if quit {
+			return
+		}
+	}
+}
+
+// client is a connection to the server and its state.
+type client struct {
+	conn net.Conn
+
+	// out queues the replies and the pub/sub messages, so that they are written in the order of the commands.
+	outMu  sync.Mutex
+	cond   *sync.Cond
+	out    []interface{}
+	closed bool
+
+	// The state below is guarded by Server.mu.
+	name          string
+	db            int
+	authenticated bool
+	quit          bool
+	multi         bool
+	dirty         bool
+	queued        [][][]byte
+	watched       map[watchedKey]uint64
+	channels      map[string]bool
+	patterns      map[string]bool
+}
+
+// watchedKey is a key watched by a client.
+type watchedKey struct {
+	db  int
+	key string
+}
+
+func newClient(conn net.Conn) *client {
+	c := &client{
+		conn:     conn,
+		channels: make(map[string]bool),
+		patterns: make(map[string]bool),
+	}
+	c.cond = sync.NewCond(&c.outMu)
+	return c
+}
+
+// subscriptions gets the number of channels and patterns the client is subscribed to.
+func (c *client) subscriptions() int {
+	return len(c.channels) + len(c.patterns)
+}
+
+// send queues a reply to write to the client.
+func (c *client) send(reply interface{}) {
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+	if !c.closed {
+		c.out = append(c.out, reply)
+		c.cond.Signal()
+	}
+}
+
+// close stops the writeLoop once the queued replies are written.
+func (c *client) close() {
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+	c.closed = true
+	c.cond.Signal()
+}
+
+// writeLoop writes the queued replies until the client is closed.
+func (c *client) writeLoop() {
+	w := bufio.NewWriter(c.conn)
+	for {
+		c.outMu.Lock()
+		for len(c.out) == 0 && !c.closed {
+			c.cond.Wait()
+		}
+		out := c.out
+		c.out = nil
+		closed := c.closed
+		c.outMu.Unlock()
+
+		for _, reply := range out {
+			writeReply(w, reply)
+		}
+		if err := w.Flush(); err != nil || closed {
+			return
+		}
+	}
+}
+
+// Status is a simple string reply like +OK, the scripted servers reply it along with nil, []byte, string, []string,
+// int, int64, []interface{} and errors.
+type Status string
+
+// The other replies of the server.
+type (
+	// errorReply is an error reply like -ERR syntax error.
+	errorReply string
+	// nullArray is the reply of an aborted transaction.
+	nullArray struct{}
+	// replies are several replies to one command, e.g. to SUBSCRIBE with several channels.
+	replies []interface{}
+)
+
+var ok = Status("OK")
+
+func writeReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case Status:
+		fmt.Fprintf(w, "+%s\r\n", v)
+	case errorReply:
+		fmt.Fprintf(w, "-%s\r\n", v)
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []byte:
+		fmt.Fprintf(w, "$%d\r\n", len(v))
+		w.Write(v)
+		w.WriteString("\r\n")
+	case []string:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, value := range v {
+			writeReply(w, value)
+		}
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, value := range v {
+			writeReply(w, value)
+		}
+	case error:
+		fmt.Fprintf(w, "-%s\r\n", v.Error())
+	case nullArray:
+		w.WriteString("*-1\r\n")
+	case replies:
+		for _, value := range v {
+			writeReply(w, value)
+		}
+	default:
+		panic(fmt.Sprintf("cachetest: unsupported reply %T", reply))
+	}
+}
+
+// protocolError is an invalid request, the connection is closed after replying it.
+type protocolError string
+
+func (e protocolError) Error() string {
+	return string(e)
+}
+
+// readCommand reads a command sent as an array of bulk strings, or inline as words separated by spaces.
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		var args [][]byte
+		for _, word := range strings.Fields(line) {
+			args = append(args, []byte(word))
+		}
+		return args, nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > 1024*1024 {
+		return nil, protocolError("invalid multibulk length")
+	}
+	args := make([][]byte, n)
+	for i := range args {
+		if line, err = readLine(r); err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, protocolError(fmt.Sprintf("expected '$', got '%.1s'", line))
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > 512*1024*1024 {
+			return nil, protocolError("invalid bulk length")
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = b[:size]
+	}
+	return args, nil
+}
+
+// readLine reads a line without its \r\n.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
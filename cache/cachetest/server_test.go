@@ -0,0 +1,251 @@
+package cachetest
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cache"
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestServer(t *testing.T) {
+	Convey("Test The In-Process Server\n", t, func() {
+		server, err := NewServer()
+		So(err, ShouldBeNil)
+		defer server.Close()
+
+		cacher, err := cache.NewRedisCacherWithOptions(cache.RedisOptions{Addr: server.Addr(), DB: 3})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+
+		Convey("RedisCacher Should Work Against The Server", func() {
+			So(cacher.Set("key1", "value1"), ShouldBeNil)
+			So(cacher.SetJSON("key2", map[string]int{"a": 1}), ShouldBeNil)
+			value, err := redis.String(cacher.Get("key1"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "value1")
+			b, err := cacher.GetJSON("key2")
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"a":1}`)
+
+			values, err := redis.Strings(cacher.MultipleGet("key1", "notExist"))
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, []string{"value1", ""})
+
+			So(cacher.HSet("hash", "field", "value"), ShouldBeNil)
+			So(cacher.HINCRBY("hash", "counter", 2), ShouldBeNil)
+			all, err := redis.StringMap(cacher.HGetAll("hash"))
+			So(err, ShouldBeNil)
+			So(all, ShouldResemble, map[string]string{"counter": "2", "field": "value"})
+			_, err = cacher.Get("hash")
+			So(err, ShouldNotBeNil)
+
+			keys, err := cacher.DelKeys("key1", "notExist")
+			So(err, ShouldBeNil)
+			So(keys, ShouldEqual, 1)
+			size, err := redis.Int(cacher.GetDBSize())
+			So(err, ShouldBeNil)
+			So(size, ShouldEqual, 2)
+
+			So(cacher.Flush(), ShouldBeNil)
+			size, _ = redis.Int(cacher.GetDBSize())
+			So(size, ShouldEqual, 0)
+		})
+
+		Convey("Databases Should Be Separated", func() {
+			other, err := cache.NewRedisCacherWithOptions(cache.RedisOptions{Addr: server.Addr(), DB: 4})
+			So(err, ShouldBeNil)
+			defer other.ClosePool()
+			So(cacher.Set("key", "value"), ShouldBeNil)
+			reply, err := other.Get("key")
+			So(err, ShouldBeNil)
+			So(reply, ShouldBeNil)
+
+			_, err = cache.NewRedisCacherWithOptions(cache.RedisOptions{Addr: server.Addr(), DB: 16})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Scan Should Visit Every Key Once", func() {
+			items := make(map[string]interface{})
+			for i := 0; i < 100; i++ {
+				items[string(rune('a'+i%26))+string(rune('0'+i/26))] = i
+			}
+			So(cacher.MultipleSet(items, 0), ShouldBeNil)
+			So(cacher.HSet("hash", "field", "value"), ShouldBeNil)
+
+			seen := make(map[string]int)
+			err := cacher.ScanEach(context.Background(), cache.ScanOptions{Count: 7}, func(key string) error {
+				seen[key]++
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(len(seen), ShouldEqual, 101)
+			for _, n := range seen {
+				So(n, ShouldEqual, 1)
+			}
+
+			var hashes []string
+			err = cacher.ScanEach(context.Background(), cache.ScanOptions{Match: "h*", Type: "hash"}, func(key string) error {
+				hashes = append(hashes, key)
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(hashes, ShouldResemble, []string{"hash"})
+		})
+
+		Convey("Keys Should Expire When The Time Is Moved Forward", func() {
+			So(cacher.Set("key1", "value1", 10), ShouldBeNil)
+			So(cacher.SetValue("key2", "value2", time.Minute), ShouldBeNil)
+			So(cacher.Set("key3", "value3"), ShouldBeNil)
+			ttl, err := cacher.TTL("key1")
+			So(err, ShouldBeNil)
+			So(ttl, ShouldEqual, 10)
+
+			server.FastForward(11 * time.Second)
+			reply, err := cacher.Get("key1")
+			So(err, ShouldBeNil)
+			So(reply, ShouldBeNil)
+			ttl, _ = cacher.TTL("key1")
+			So(ttl, ShouldEqual, -2)
+			ttl, _ = cacher.TTL("key2")
+			So(ttl, ShouldEqual, 49)
+			ttl, _ = cacher.TTL("key3")
+			So(ttl, ShouldEqual, -1)
+
+			server.FastForward(time.Minute)
+			size, _ := redis.Int(cacher.GetDBSize())
+			So(size, ShouldEqual, 1)
+		})
+
+		Convey("Messages Should Be Published To The Subscribers", func() {
+			subscriber := cacher.NewSubscriber(100 * time.Millisecond)
+			defer subscriber.Close()
+			So(subscriber.Subscribe("news"), ShouldBeNil)
+			So(subscriber.PSubscribe("events.*"), ShouldBeNil)
+
+			for i := 0; i < 100; i++ {
+				if n, _ := cacher.Publish("news", "ping"); n > 0 {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			n, err := cacher.Publish("events.login", "alice")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+
+			var message cache.Message
+			for message.Channel != "events.login" {
+				select {
+				case message = <-subscriber.Messages():
+				case <-time.After(time.Second):
+					t.Fatal("no message received")
+				}
+			}
+			So(message, ShouldResemble, cache.Message{Channel: "events.login", Pattern: "events.*", Data: []byte("alice")})
+		})
+
+		Convey("Transactions Should Be Aborted When A Watched Key Changes", func() {
+			So(cacher.Set("counter", 1), ShouldBeNil)
+			err := cacher.Watch(context.Background(), func(tx *cache.Tx) error {
+				n, err := redis.Int(tx.Do("GET", "counter"))
+				tx.Queue("SET", "counter", n+1)
+				return err
+			}, "counter")
+			So(err, ShouldBeNil)
+			n, _ := cacher.GetInt64("counter")
+			So(n, ShouldEqual, 2)
+
+			err = cacher.Watch(context.Background(), func(tx *cache.Tx) error {
+				cacher.Set("counter", 0)
+				tx.Queue("SET", "counter", -1)
+				return nil
+			}, "counter")
+			So(err, ShouldEqual, cache.ErrTxConflict)
+		})
+
+		Convey("Clients Should Authenticate When A Password Is Required", func() {
+			server.RequirePassword("secret")
+			_, err := cache.NewRedisCacherWithOptions(cache.RedisOptions{Addr: server.Addr()})
+			So(err, ShouldNotBeNil)
+			_, err = cache.NewRedisCacherWithOptions(cache.RedisOptions{Addr: server.Addr(), Password: "wrong"})
+			So(err, ShouldNotBeNil)
+			authenticated, err := cache.NewRedisCacherWithOptions(cache.RedisOptions{Addr: server.Addr(), Password: "secret"})
+			So(err, ShouldBeNil)
+			authenticated.ClosePool()
+		})
+
+		Convey("Errors Should Be Replied Like Redis", func() {
+			conn, err := redis.Dial("tcp", server.Addr())
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			_, err = conn.Do("NOPE")
+			So(err, ShouldResemble, redis.Error("ERR unknown command 'NOPE'"))
+			_, err = conn.Do("GET")
+			So(err, ShouldResemble, redis.Error("ERR wrong number of arguments for 'get' command"))
+			_, err = conn.Do("SET", "key", "value", "EX", 0)
+			So(err, ShouldNotBeNil)
+			conn.Do("SET", "key", "value")
+			_, err = conn.Do("INCR", "key")
+			So(err, ShouldResemble, redis.Error("ERR value is not an integer or out of range"))
+			_, err = conn.Do("HGET", "key", "field")
+			So(err, ShouldResemble, redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"))
+
+			conn.Send("MULTI")
+			conn.Send("SET", "key", "other")
+			conn.Send("NOPE")
+			_, err = conn.Do("EXEC")
+			So(err, ShouldNotBeNil)
+			value, _ := redis.String(conn.Do("GET", "key"))
+			So(value, ShouldEqual, "value")
+		})
+
+		Convey("Inline Commands Should Be Supported", func() {
+			conn, err := net.Dial("tcp", server.Addr())
+			So(err, ShouldBeNil)
+			defer conn.Close()
+			conn.Write([]byte("PING\r\nECHO hello\r\n"))
+			r := bufio.NewReader(conn)
+			line, _ := r.ReadString('\n')
+			So(line, ShouldEqual, "+PONG\r\n")
+			line, _ = r.ReadString('\n')
+			So(line, ShouldEqual, "$5\r\n")
+			line, _ = r.ReadString('\n')
+			So(line, ShouldEqual, "hello\r\n")
+		})
+	})
+}
+
+func TestScriptedServer(t *testing.T) {
+	Convey("Test The Scripted Server\n", t, func() {
+		server, err := NewScriptedServer(func(c *Conn, args []string) {
+			switch args[0] {
+			case "PING":
+				c.Reply(Status("PONG"))
+			case "ECHO":
+				c.Reply(args[1:])
+			default:
+				c.Reply(redis.Error("ERR unknown command"))
+			}
+		})
+		So(err, ShouldBeNil)
+		defer server.Close()
+
+		conn, err := redis.Dial("tcp", server.Addr())
+		So(err, ShouldBeNil)
+		defer conn.Close()
+
+		reply, err := redis.String(conn.Do("PING"))
+		So(err, ShouldBeNil)
+		So(reply, ShouldEqual, "PONG")
+		replies, err := redis.Strings(conn.Do("ECHO", "a", "b"))
+		So(err, ShouldBeNil)
+		So(replies, ShouldResemble, []string{"a", "b"})
+		_, err = conn.Do("GET", "key")
+		So(err, ShouldResemble, redis.Error("ERR unknown command"))
+	})
+}
@@ -0,0 +1,768 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const clusterSlots = 16384
+
+var (
+	errClusterConnClosed = errors.New("cache: connection closed")
+	// errTopologyChanged is returned by a SCAN whose cursor was returned before the masters of the cluster changed.
+	errTopologyChanged = errors.New("cache: the masters of the cluster changed during the scan")
+)
+
+// keySlot gets the cluster slot of key. If the key contains a non empty {hash tag}, only the tag is hashed,
+// so that related keys can be put in the same slot.
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % clusterSlots)
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum used by redis cluster to hash keys.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// keylessCommands are sent to any node.
+var keylessCommands = map[string]bool{
+	"PING": true, "ECHO": true, "INFO": true, "TIME": true, "PUBLISH": true, "CLUSTER": true, "CLIENT": true,
+	"CONFIG": true, "COMMAND": true, "ROLE": true, "SLOWLOG": true, "RANDOMKEY": true, "WAIT": true,
+}
+
+// commandKey gets the key deciding the node a command is sent to, false if the command has no key.
+func commandKey(commandName string, args []interface{}) (string, bool) {
+	pos := 0
+	switch name := strings.ToUpper(commandName); {
+	case keylessCommands[name]:
+		return "", false
+	case name == "EVAL" || name == "EVALSHA" || name == "EVAL_RO" || name == "EVALSHA_RO":
+		if numKeys, err := argInt64(argAt(args, 1)); err != nil || numKeys == 0 {
+			return "", false
+		}
+		pos = 2
+	case name == "XREAD" || name == "XREADGROUP":
+		pos = len(args)
+		for i, arg := range args {
+			if strings.ToUpper(string(argBytes(arg))) == "STREAMS" {
+				pos = i + 1
+				break
+			}
+		}
+	case name == "XGROUP" || name == "XINFO" || name == "OBJECT" || name == "MEMORY":
+		pos = 1
+	}
+	if pos >= len(args) {
+		return "", false
+	}
+	return string(argBytes(args[pos])), true
+}
+
+func argAt(args []interface{}, i int) interface{} {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+// parseRedirect parses a "MOVED <slot> <addr>" or "ASK <slot> <addr>" error, kind is empty for other errors.
+func parseRedirect(err error) (kind string, slot int, addr string) {
+	e, ok := err.(redis.Error)
+	if !ok {
+		return "", 0, ""
+	}
+	fields := strings.Fields(string(e))
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return "", 0, ""
+	}
+	slot, convErr := strconv.Atoi(fields[1])
+	if convErr != nil {
+		return "", 0, ""
+	}
+	return fields[0], slot, fields[2]
+}
+
+// cluster keeps the map of the slots to the master nodes and a connection pool for each node.
+// The blocking commands have their own pool for each node, so that they don't hold the connections of the other commands.
+type cluster struct {
+	o          *RedisCacher
+	refreshing int32
+
+	mu            sync.RWMutex
+	slots         []string
+	masters       []string
+	pools         map[string]*redis.Pool
+	blockingPools map[string]*redis.Pool
+}
+
+func newCluster(o *RedisCacher) *cluster {
+	return &cluster{o: o, pools: make(map[string]*redis.Pool), blockingPools: make(map[string]*redis.Pool)}
+}
+
+func (c *cluster) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range c.pools {
+		p.Close()
+	}
+	for _, p := range c.blockingPools {
+		p.Close()
+	}
+	c.pools = make(map[string]*redis.Pool)
+	c.blockingPools = make(map[string]*redis.Pool)
+}
+
+// pool gets the connection pool of the node at addr, or its pool of the blocking commands.
+func (c *cluster) pool(addr string, blocking bool) *redis.Pool {
+	pools := func() map[string]*redis.Pool {
+		if blocking {
+			return c.blockingPools
+		}
+		return c.pools
+	}
+
+	c.mu.RLock()
+	p := pools()[addr]
+	c.mu.RUnlock()
+	if p != nil {
+		return p
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p = pools()[addr]; p == nil {
+		p = c.o.newPool(func() (redis.Conn, error) {
+			return c.o.dialAddr(addr)
+		})
+		pools()[addr] = p
+	}
+	return p
+}
+
+// refresh loads the slots from the known masters, or from the seed nodes.
+func (c *cluster) refresh() error {
+	c.mu.RLock()
+	addrs := append(append([]string{}, c.masters...), c.o.options.ClusterAddrs...)
+	c.mu.RUnlock()
+
+	var err error
+	for _, addr := range addrs {
+		var slots, masters []string
+		if slots, masters, err = c.fetchSlots(addr); err == nil {
+			c.mu.Lock()
+			c.slots, c.masters = slots, masters
+			c.mu.Unlock()
+			return nil
+		}
+	}
+	return err
+}
+
+// refreshAsync refreshes the slots in the background, at most one refresh runs at a time.
+func (c *cluster) refreshAsync() {
+	if atomic.CompareAndSwapInt32(&c.refreshing, 0, 1) {
+		go func() {
+			defer atomic.StoreInt32(&c.refreshing, 0)
+			c.refresh()
+		}()
+	}
+}
+
+// fetchSlots gets the master of every slot and the sorted list of masters with CLUSTER SLOTS.
+func (c *cluster) fetchSlots(addr string) ([]string, []string, error) {
+	conn := c.pool(addr, false).Get()
+	defer conn.Close()
+	values, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	slots := make([]string, clusterSlots)
+	var masters []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		entry, _ := redis.Values(value, nil)
+		if len(entry) < 3 {
+			return nil, nil, errors.New("cache: invalid CLUSTER SLOTS reply")
+		}
+		node, _ := redis.Values(entry[2], nil)
+		if len(node) < 2 {
+			return nil, nil, errors.New("cache: invalid CLUSTER SLOTS reply")
+		}
+		start, _ := redis.Int(entry[0], nil)
+		end, _ := redis.Int(entry[1], nil)
+		host, _ := redis.String(node[0], nil)
+		port, _ := redis.Int(node[1], nil)
+		// An empty host means the node we're talking to.
+		if host == "" {
+			host, _, _ = net.SplitHostPort(addr)
+		}
+		master := net.JoinHostPort(host, strconv.Itoa(port))
+		for slot := start; slot <= end && slot < clusterSlots; slot++ {
+			slots[slot] = master
+		}
+		if !seen[master] {
+			seen[master] = true
+			masters = append(masters, master)
+		}
+	}
+	if len(masters) == 0 {
+		return nil, nil, errors.New("cache: no slot is served by the cluster")
+	}
+	sort.Strings(masters)
+	return slots, masters, nil
+}
+
+// load loads the slots if they haven't been loaded yet.
+func (c *cluster) load() error {
+	c.mu.RLock()
+	loaded := c.slots != nil
+	c.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+	return c.refresh()
+}
+
+// slotAddr gets the address of the master serving slot, or of any master if slot is negative.
+func (c *cluster) slotAddr(slot int) (string, error) {
+	if err := c.load(); err != nil {
+		return "", err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if slot < 0 {
+		return c.masters[rand.Intn(len(c.masters))], nil
+	}
+	if addr := c.slots[slot]; addr != "" {
+		return addr, nil
+	}
+	return "", fmt.Errorf("cache: slot %d is not served by the cluster", slot)
+}
+
+// masterAddrs gets the addresses of the masters in a stable order.
+func (c *cluster) masterAddrs() ([]string, error) {
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.masters, nil
+}
+
+// moved records that slot has moved to addr and refreshes the other slots, as resharding usually moves several.
+func (c *cluster) moved(slot int, addr string) {
+	c.mu.Lock()
+	if c.slots != nil {
+		c.slots[slot] = addr
+	}
+	c.mu.Unlock()
+	c.refreshAsync()
+}
+
+// dial connects to any master, it's used for the connections which aren't bound to a key like pub/sub.
+func (c *cluster) dial() (redis.Conn, error) {
+	addr, err := c.slotAddr(-1)
+	if err != nil {
+		return nil, err
+	}
+	return c.o.dialAddr(addr)
+}
+
+func (c *cluster) conn(ctx context.Context) redis.Conn {
+	return &clusterConn{cluster: c, ctx: ctx, conns: make(map[string]redis.Conn)}
+}
+
+// blockingConn gets a connection to the cluster taking the connections to the nodes from their pools of the blocking commands.
+func (c *cluster) blockingConn(ctx context.Context) redis.Conn {
+	return &clusterConn{cluster: c, ctx: ctx, conns: make(map[string]redis.Conn), blocking: true}
+}
+
+type clusterCmd struct {
+	name string
+	args []interface{}
+}
+
+// clusterConn is a connection to the whole cluster, each command is sent to the node serving the slot of its key.
+// Commands with keys in several slots like MGET and DEL are split by slot, and commands like FLUSHDB and DBSIZE
+// are sent to every master. A transaction is pinned to the node of its first key, so all its keys must be in the same slot.
+// Pipelined commands are sent when their replies are received.
+type clusterConn struct {
+	cluster *cluster
+	ctx     context.Context
+	conns   map[string]redis.Conn
+	pending []clusterCmd
+	// pinned is the node of the current transaction, multi is set between MULTI and EXEC.
+	pinned string
+	multi  bool
+	err    error
+	// blocking tells to take the connections from the pools of the blocking commands.
+	blocking bool
+}
+
+func (c *clusterConn) Close() error {
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+	c.conns = nil
+	c.err = errClusterConnClosed
+	return nil
+}
+
+func (c *clusterConn) Err() error {
+	return c.err
+}
+
+func (c *clusterConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return c.DoWithTimeout(0, commandName, args...)
+}
+
+// DoWithTimeout runs the pending commands and then the command, like redigo it returns the reply of the command
+// and the first error. An empty commandName returns the replies of the pending commands.
+func (c *clusterConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	pending := c.pending
+	c.pending = nil
+
+	var err error
+	replies := make([]interface{}, 0, len(pending))
+	for _, cmd := range pending {
+		reply, e := c.exec(timeout, cmd.name, cmd.args)
+		if e != nil {
+			if _, ok := e.(redis.Error); !ok {
+				return nil, e
+			}
+			if err == nil {
+				err = e
+			}
+			reply = e
+		}
+		replies = append(replies, reply)
+	}
+	if commandName == "" {
+		return replies, err
+	}
+
+	reply, e := c.exec(timeout, commandName, args)
+	if _, ok := e.(redis.Error); e != nil && (!ok || err == nil) {
+		err = e
+	}
+	return reply, err
+}
+
+func (c *clusterConn) Send(commandName string, args ...interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.pending = append(c.pending, clusterCmd{commandName, args})
+	return nil
+}
+
+func (c *clusterConn) Flush() error {
+	return c.err
+}
+
+func (c *clusterConn) Receive() (interface{}, error) {
+	return c.ReceiveWithTimeout(0)
+}
+
+func (c *clusterConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if len(c.pending) == 0 {
+		return nil, errors.New("cache: no pending command to receive the reply of")
+	}
+	cmd := c.pending[0]
+	c.pending = c.pending[1:]
+	return c.exec(timeout, cmd.name, cmd.args)
+}
+
+// node gets a connection to the node at addr.
+func (c *clusterConn) node(addr string) (redis.Conn, error) {
+	if conn, ok := c.conns[addr]; ok {
+		return conn, nil
+	}
+	conn, err := c.cluster.pool(addr, c.blocking).GetContext(c.ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.conns[addr] = conn
+	return conn, nil
+}
+
+func doWithTimeout(conn redis.Conn, timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	if timeout > 0 {
+		return redis.DoWithTimeout(conn, timeout, commandName, args...)
+	}
+	return conn.Do(commandName, args...)
+}
+
+// exec runs a command on the right node.
+func (c *clusterConn) exec(timeout time.Duration, commandName string, args []interface{}) (interface{}, error) {
+	name := strings.ToUpper(commandName)
+	switch name {
+	case "MULTI":
+		c.multi = true
+		if c.pinned == "" {
+			// MULTI is sent once the first command tells the node.
+			return "OK", nil
+		}
+		reply, _, err := c.send(c.pinned, false, false, timeout, commandName, args)
+		return reply, err
+	case "EXEC", "DISCARD":
+		addr := c.pinned
+		c.pinned, c.multi = "", false
+		if addr == "" {
+			if name == "EXEC" {
+				return []interface{}{}, nil
+			}
+			return "OK", nil
+		}
+		reply, _, err := c.send(addr, false, false, timeout, commandName, args)
+		return reply, err
+	case "UNWATCH":
+		addr := c.pinned
+		if addr == "" {
+			return "OK", nil
+		}
+		if !c.multi {
+			c.pinned = ""
+		}
+		reply, _, err := c.send(addr, false, false, timeout, commandName, args)
+		return reply, err
+	}
+
+	if c.pinned == "" && !c.multi {
+		switch name {
+		case "MGET":
+			return c.mget(timeout, args)
+		case "MSET":
+			return c.mset(timeout, args)
+		case "DEL", "UNLINK", "EXISTS", "TOUCH":
+			return c.sum(timeout, commandName, args)
+		case "FLUSHDB", "FLUSHALL", "DBSIZE", "SCRIPT":
+			return c.broadcast(timeout, commandName, args)
+		case "SCAN":
+			return c.scan(timeout, args)
+		}
+	}
+
+	if c.pinned != "" {
+		reply, _, err := c.send(c.pinned, false, false, timeout, commandName, args)
+		return reply, err
+	}
+	slot := -1
+	if key, ok := commandKey(commandName, args); ok {
+		slot = keySlot(key)
+	}
+	addr, err := c.cluster.slotAddr(slot)
+	if err != nil {
+		return nil, err
+	}
+	if c.multi {
+		c.pinned = addr
+		if _, _, err := c.send(addr, false, false, timeout, "MULTI", nil); err != nil {
+			return nil, err
+		}
+		reply, _, err := c.send(addr, false, false, timeout, commandName, args)
+		return reply, err
+	}
+	reply, addr, err := c.send(addr, false, true, timeout, commandName, args)
+	if name == "WATCH" && err == nil {
+		c.pinned = addr
+	}
+	return reply, err
+}
+
+// send runs a command on the node at addr, prefixed by ASKING if asking is true.
+// If follow is true it follows the MOVED and ASK redirections, it returns the address of the node which replied.
+func (c *clusterConn) send(addr string, asking, follow bool, timeout time.Duration, commandName string, args []interface{}) (interface{}, string, error) {
+	for redirects := 0; ; redirects++ {
+		conn, err := c.node(addr)
+		if err != nil {
+			c.cluster.refreshAsync()
+			return nil, addr, err
+		}
+		if asking {
+			if _, err := doWithTimeout(conn, timeout, "ASKING"); err != nil {
+				return nil, addr, err
+			}
+		}
+		reply, err := doWithTimeout(conn, timeout, commandName, args...)
+		if err == nil {
+			return reply, addr, nil
+		}
+		if _, ok := err.(redis.Error); !ok {
+			// The node may be down, its slots may be served by a promoted replica.
+			c.cluster.refreshAsync()
+			return nil, addr, err
+		}
+
+		kind, slot, target := parseRedirect(err)
+		if kind == "MOVED" {
+			c.cluster.moved(slot, target)
+		}
+		if kind == "" || !follow || redirects >= c.cluster.o.options.MaxRedirects {
+			return reply, addr, err
+		}
+		addr, asking = target, kind == "ASK"
+	}
+}
+
+// slotCmd is a part of a multi-key command with the keys of one slot.
+type slotCmd struct {
+	slot  int
+	args  []interface{}
+	index []int
+	reply interface{}
+	err   error
+}
+
+// splitBySlot groups the keys of a multi-key command by slot, a key takes step arguments.
+func splitBySlot(args []interface{}, step int) []*slotCmd {
+	var cmds []*slotCmd
+	bySlot := make(map[int]*slotCmd)
+	for i := 0; i+step <= len(args); i += step {
+		slot := keySlot(string(argBytes(args[i])))
+		cmd, ok := bySlot[slot]
+		if !ok {
+			cmd = &slotCmd{slot: slot}
+			bySlot[slot] = cmd
+			cmds = append(cmds, cmd)
+		}
+		cmd.args = append(cmd.args, args[i:i+step]...)
+		cmd.index = append(cmd.index, i/step)
+	}
+	return cmds
+}
+
+// runSlots runs the commands split by slot, the commands of a node are pipelined.
+// The commands which are redirected are run again on their new node.
+func (c *clusterConn) runSlots(timeout time.Duration, commandName string, cmds []*slotCmd) error {
+	var addrs []string
+	byAddr := make(map[string][]*slotCmd)
+	for _, cmd := range cmds {
+		addr, err := c.cluster.slotAddr(cmd.slot)
+		if err != nil {
+			return err
+		}
+		if _, ok := byAddr[addr]; !ok {
+			addrs = append(addrs, addr)
+		}
+		byAddr[addr] = append(byAddr[addr], cmd)
+	}
+
+	for _, addr := range addrs {
+		conn, err := c.node(addr)
+		if err != nil {
+			c.cluster.refreshAsync()
+			return err
+		}
+		for _, cmd := range byAddr[addr] {
+			conn.Send(commandName, cmd.args...)
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+		for _, cmd := range byAddr[addr] {
+			if timeout > 0 {
+				cmd.reply, cmd.err = redis.ReceiveWithTimeout(conn, timeout)
+			} else {
+				cmd.reply, cmd.err = conn.Receive()
+			}
+			if _, ok := cmd.err.(redis.Error); cmd.err != nil && !ok {
+				c.cluster.refreshAsync()
+				return cmd.err
+			}
+		}
+	}
+
+	for _, cmd := range cmds {
+		kind, slot, target := parseRedirect(cmd.err)
+		if kind == "" {
+			continue
+		}
+		if kind == "MOVED" {
+			c.cluster.moved(slot, target)
+		}
+		cmd.reply, _, cmd.err = c.send(target, kind == "ASK", true, timeout, commandName, cmd.args)
+		if _, ok := cmd.err.(redis.Error); cmd.err != nil && !ok {
+			return cmd.err
+		}
+	}
+	for _, cmd := range cmds {
+		if cmd.err != nil {
+			return cmd.err
+		}
+	}
+	return nil
+}
+
+func (c *clusterConn) mget(timeout time.Duration, args []interface{}) (interface{}, error) {
+	cmds := splitBySlot(args, 1)
+	if err := c.runSlots(timeout, "MGET", cmds); err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(args))
+	for _, cmd := range cmds {
+		replies, err := redis.Values(cmd.reply, nil)
+		if err != nil {
+			return nil, err
+		}
+		for i, index := range cmd.index {
+			values[index] = replies[i]
+		}
+	}
+	return values, nil
+}
+
+func (c *clusterConn) mset(timeout time.Duration, args []interface{}) (interface{}, error) {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, redis.Error("ERR wrong number of arguments for 'mset' command")
+	}
+	if err := c.runSlots(timeout, "MSET", splitBySlot(args, 2)); err != nil {
+		return nil, err
+	}
+	return "OK", nil
+}
+
+// sum runs a multi-key command replying a count split by slot and sums the counts.
+func (c *clusterConn) sum(timeout time.Duration, commandName string, args []interface{}) (interface{}, error) {
+	cmds := splitBySlot(args, 1)
+	if err := c.runSlots(timeout, commandName, cmds); err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, cmd := range cmds {
+		n, err := redis.Int64(cmd.reply, nil)
+		if err != nil {
+			return nil, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// broadcast runs a command on every master, it returns the sum of the replies if they're integers.
+func (c *clusterConn) broadcast(timeout time.Duration, commandName string, args []interface{}) (interface{}, error) {
+	masters, err := c.cluster.masterAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var reply interface{}
+	var total int64
+	for _, addr := range masters {
+		if reply, _, err = c.send(addr, false, false, timeout, commandName, args); err != nil {
+			return nil, err
+		}
+		if n, ok := reply.(int64); ok {
+			total += n
+		}
+	}
+	if _, ok := reply.(int64); ok {
+		return total, nil
+	}
+	return reply, nil
+}
+
+// Bits of a cluster SCAN cursor, the cursor of the master in the high bits, then a fingerprint of the masters
+// and the index of the master scanned in the low bits.
+const (
+	scanIndexBits       = 16
+	scanFingerprintBits = 16
+	scanCursorShift     = scanIndexBits + scanFingerprintBits
+	scanMaxCursor       = 1<<(63-scanCursorShift) - 1
+)
+
+// mastersFingerprint hashes the addresses of the masters in their order, it's never 0 so that a cursor isn't 0
+// until the scan is complete.
+func mastersFingerprint(masters []string) int64 {
+	h := fnv.New32a()
+	for _, addr := range masters {
+		h.Write([]byte(addr))
+		h.Write([]byte{0})
+	}
+	return int64(h.Sum32()&(1<<scanFingerprintBits-1)) | 1
+}
+
+// scan scans the masters one after the other. The cursor holds the cursor of the master, the index of the master
+// and a fingerprint of the masters, so that the scan fails with errTopologyChanged rather than skipping nodes if they change.
+func (c *clusterConn) scan(timeout time.Duration, args []interface{}) (interface{}, error) {
+	masters, err := c.cluster.masterAddrs()
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := argInt64(argAt(args, 0))
+	if err != nil {
+		return nil, err
+	}
+	if len(masters) >= 1<<scanIndexBits {
+		return nil, errors.New("cache: too many masters to scan")
+	}
+	fingerprint := mastersFingerprint(masters)
+	var index, masterCursor int64
+	if cursor != 0 {
+		if cursor < 0 || cursor>>scanIndexBits&(1<<scanFingerprintBits-1) != fingerprint {
+			return nil, errTopologyChanged
+		}
+		index = cursor & (1<<scanIndexBits - 1)
+		masterCursor = cursor >> scanCursorShift
+		if index >= int64(len(masters)) {
+			return nil, errTopologyChanged
+		}
+	}
+
+	reply, _, err := c.send(masters[index], false, false, timeout, "SCAN", append([]interface{}{masterCursor}, args[1:]...))
+	values, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 2 {
+		return nil, errors.New("cache: invalid SCAN reply")
+	}
+	next, err := redis.Int64(values[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	if next < 0 || next > scanMaxCursor {
+		return nil, fmt.Errorf("cache: the SCAN cursor %d of %s is too large", next, masters[index])
+	}
+	if next == 0 {
+		if index++; index == int64(len(masters)) {
+			return []interface{}{[]byte("0"), values[1]}, nil
+		}
+	}
+	next = next<<scanCursorShift | fingerprint<<scanIndexBits | index
+	return []interface{}{[]byte(strconv.FormatInt(next, 10)), values[1]}, nil
+}
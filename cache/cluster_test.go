@@ -0,0 +1,317 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/WUMUXIAN/go-common-utils/cache/cachetest"
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeCluster is a cluster of fake nodes which serve a few string commands and redirect the keys of other nodes.
+type fakeCluster struct {
+	mu        sync.Mutex
+	nodes     []*cachetest.ScriptedServer
+	data      []map[string]string
+	owners    [clusterSlots]int
+	migrating map[int]int
+	// asking are the connections which have sent ASKING for their next command.
+	asking map[*cachetest.Conn]bool
+}
+
+func newFakeCluster(n int) (*fakeCluster, error) {
+	c := &fakeCluster{migrating: make(map[int]int), asking: make(map[*cachetest.Conn]bool)}
+	for i := 0; i < n; i++ {
+		node := i
+		server, err := cachetest.NewScriptedServer(func(client *cachetest.Conn, args []string) {
+			client.Reply(c.handle(node, client, args))
+		})
+		if err != nil {
+			c.close()
+			return nil, err
+		}
+		c.nodes = append(c.nodes, server)
+		c.data = append(c.data, make(map[string]string))
+	}
+	for slot := range c.owners {
+		c.owners[slot] = slot * n / clusterSlots
+	}
+	return c, nil
+}
+
+func (c *fakeCluster) close() {
+	for _, node := range c.nodes {
+		node.Close()
+	}
+}
+
+// owner gets the node serving the slot of key and the value stored for key there.
+func (c *fakeCluster) owner(key string) (int, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	node := c.owners[keySlot(key)]
+	return node, c.data[node][key]
+}
+
+// sizes gets the number of keys of every node.
+func (c *fakeCluster) sizes() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sizes := make([]int, len(c.data))
+	for i, data := range c.data {
+		sizes[i] = len(data)
+	}
+	return sizes
+}
+
+// move moves the slot of key to node, along with the key.
+func (c *fakeCluster) move(key string, node int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	slot := keySlot(key)
+	if value, ok := c.data[c.owners[slot]][key]; ok {
+		delete(c.data[c.owners[slot]], key)
+		c.data[node][key] = value
+	}
+	c.owners[slot] = node
+}
+
+// migrate moves key to node while its slot is migrating, so the owner replies ASK for it.
+func (c *fakeCluster) migrate(key string, node int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	slot := keySlot(key)
+	c.data[node][key] = c.data[c.owners[slot]][key]
+	delete(c.data[c.owners[slot]], key)
+	c.migrating[slot] = node
+}
+
+func (c *fakeCluster) slots() []interface{} {
+	var slots []interface{}
+	for start := 0; start < clusterSlots; {
+		end := start
+		for end+1 < clusterSlots && c.owners[end+1] == c.owners[start] {
+			end++
+		}
+		addr := strings.Split(c.nodes[c.owners[start]].Addr(), ":")
+		var port int
+		fmt.Sscan(addr[1], &port)
+		slots = append(slots, []interface{}{start, end, []interface{}{addr[0], port, "id"}})
+		start = end + 1
+	}
+	return slots
+}
+
+// route checks that the keys are served by node, it returns the redirection otherwise.
+func (c *fakeCluster) route(node int, client *cachetest.Conn, keys []string) interface{} {
+	asking := c.asking[client]
+	delete(c.asking, client)
+	slot := keySlot(keys[0])
+	for _, key := range keys {
+		if keySlot(key) != slot {
+			return redis.Error("CROSSSLOT Keys in request don't hash to the same slot")
+		}
+	}
+	if target, ok := c.migrating[slot]; ok {
+		if asking && target == node {
+			return nil
+		}
+		if c.owners[slot] == node {
+			if _, exists := c.data[node][keys[0]]; !exists {
+				return redis.Error(fmt.Sprintf("ASK %d %s", slot, c.nodes[target].Addr()))
+			}
+		}
+	}
+	if owner := c.owners[slot]; owner != node {
+		return redis.Error(fmt.Sprintf("MOVED %d %s", slot, c.nodes[owner].Addr()))
+	}
+	return nil
+}
+
+func (c *fakeCluster) handle(node int, client *cachetest.Conn, args []string) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data := c.data[node]
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return cachetest.Status("PONG")
+	case "ASKING":
+		c.asking[client] = true
+		return cachetest.Status("OK")
+	case "CLUSTER":
+		return c.slots()
+	case "DBSIZE":
+		return len(data)
+	case "FLUSHDB":
+		c.data[node] = make(map[string]string)
+		return cachetest.Status("OK")
+	case "SCAN":
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return []interface{}{"0", keys}
+	case "GET":
+		if redirect := c.route(node, client, args[1:2]); redirect != nil {
+			return redirect
+		}
+		if value, ok := data[args[1]]; ok {
+			return value
+		}
+		return nil
+	case "SET":
+		if redirect := c.route(node, client, args[1:2]); redirect != nil {
+			return redirect
+		}
+		data[args[1]] = args[2]
+		return cachetest.Status("OK")
+	case "MGET":
+		if redirect := c.route(node, client, args[1:]); redirect != nil {
+			return redirect
+		}
+		values := make([]interface{}, len(args)-1)
+		for i, key := range args[1:] {
+			if value, ok := data[key]; ok {
+				values[i] = value
+			}
+		}
+		return values
+	case "DEL":
+		if redirect := c.route(node, client, args[1:]); redirect != nil {
+			return redirect
+		}
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := data[key]; ok {
+				delete(data, key)
+				n++
+			}
+		}
+		return n
+	}
+	return redis.Error("ERR unknown command")
+}
+
+func TestKeySlot(t *testing.T) {
+	Convey("Test Key Slots\n", t, func() {
+		So(crc16("123456789"), ShouldEqual, 0x31C3)
+		So(keySlot("foo"), ShouldEqual, 12182)
+		So(keySlot("{user1000}.following"), ShouldEqual, keySlot("{user1000}.followers"))
+		So(keySlot("{user1000}.following"), ShouldEqual, keySlot("user1000"))
+		So(keySlot("foo{}{bar}"), ShouldEqual, int(crc16("foo{}{bar}")%clusterSlots))
+		So(keySlot("foo{{bar}}zap"), ShouldEqual, keySlot("{bar"))
+	})
+}
+
+func TestCluster(t *testing.T) {
+	Convey("Test Cluster Mode\n", t, func() {
+		fake, err := newFakeCluster(3)
+		So(err, ShouldBeNil)
+		defer fake.close()
+
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{ClusterAddrs: []string{fake.nodes[1].Addr()}})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+
+		keys := make([]string, 20)
+		for i := range keys {
+			keys[i] = fmt.Sprintf("key%d", i)
+			So(cacher.Set(keys[i], i), ShouldBeNil)
+		}
+
+		Convey("Keys Should Be Stored On The Node Of Their Slot", func() {
+			for i, key := range keys {
+				value, err := redis.Int(cacher.Get(key))
+				So(err, ShouldBeNil)
+				So(value, ShouldEqual, i)
+				_, stored := fake.owner(key)
+				So(stored, ShouldEqual, fmt.Sprint(i))
+			}
+			for _, size := range fake.sizes() {
+				So(size, ShouldBeGreaterThan, 0)
+			}
+		})
+
+		Convey("Multi-Key Commands Should Be Split By Slot", func() {
+			values, err := redis.Ints(cacher.MultipleGet(append(keys, "notExist")...))
+			So(err, ShouldBeNil)
+			So(len(values), ShouldEqual, 21)
+			for i := range keys {
+				So(values[i], ShouldEqual, i)
+			}
+			So(values[20], ShouldEqual, 0)
+
+			n, err := redis.Int(cacher.DoContext(context.Background(), "DEL", "key0", "key1", "key2", "notExist"))
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 3)
+		})
+
+		Convey("Commands On The Whole Database Should Run On Every Master", func() {
+			size, err := redis.Int(cacher.GetDBSize())
+			So(err, ShouldBeNil)
+			So(size, ShouldEqual, 20)
+
+			var scanned []string
+			cursor := 0
+			for {
+				var batch []string
+				cursor, batch, err = cacher.Scan(cursor, 100, "*")
+				So(err, ShouldBeNil)
+				scanned = append(scanned, batch...)
+				if cursor == 0 {
+					break
+				}
+			}
+			So(len(scanned), ShouldEqual, 20)
+
+			// A cursor returned before the masters change isn't valid anymore.
+			cursor, _, err = cacher.Scan(0, 100, "*")
+			So(err, ShouldBeNil)
+			So(cursor, ShouldNotEqual, 0)
+			cacher.cluster.mu.Lock()
+			masters := cacher.cluster.masters
+			cacher.cluster.masters = []string{masters[1], masters[0], masters[2]}
+			cacher.cluster.mu.Unlock()
+			_, _, err = cacher.Scan(cursor, 100, "*")
+			So(err, ShouldEqual, errTopologyChanged)
+			cacher.cluster.mu.Lock()
+			cacher.cluster.masters = masters
+			cacher.cluster.mu.Unlock()
+
+			So(cacher.Flush(), ShouldBeNil)
+			size, _ = redis.Int(cacher.GetDBSize())
+			So(size, ShouldEqual, 0)
+		})
+
+		Convey("MOVED Should Be Followed And Update The Slots", func() {
+			owner, _ := fake.owner("key0")
+			fake.move("key0", (owner+1)%3)
+			value, err := redis.Int(cacher.Get("key0"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 0)
+
+			addr, _ := cacher.cluster.slotAddr(keySlot("key0"))
+			So(addr, ShouldEqual, fake.nodes[(owner+1)%3].Addr())
+		})
+
+		Convey("ASK Should Be Followed Without Updating The Slots", func() {
+			owner, _ := fake.owner("key0")
+			fake.migrate("key0", (owner+1)%3)
+			value, err := redis.Int(cacher.Get("key0"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 0)
+			values, err := redis.Ints(cacher.MultipleGet("key0", "key1"))
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, []int{0, 1})
+
+			addr, _ := cacher.cluster.slotAddr(keySlot("key0"))
+			So(addr, ShouldEqual, fake.nodes[owner].Addr())
+		})
+	})
+}
@@ -1,7 +1,15 @@
-// Package cache offers common cache capbilities, current the only supported backend is redis
+// Package cache offers common cache capbilities, the supported backends are redis and an in-memory LRU cache.
 package cache
 
-import "encoding/gob"
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"strconv"
+
+	"github.com/garyburd/redigo/redis"
+)
 
 // Cacher defines the interface for all typs of cacher. e.g. redis, memcached and etc.
 type Cacher interface {
@@ -11,18 +19,98 @@ type Cacher interface {
 	Scan(cursor int, count int, pattern string) (nextCursor int, keys []string, err error)
 	Expire(key string, expiration int) error
 	TTL(key string) (int, error)
-	SetGob(key string, value interface{}, expiration ...interface{})
+	SetGob(key string, value interface{}, expiration ...interface{}) error
 	GetGob(key string) (interface{}, error)
-	SetJSON(key string, value interface{}, expiration ...interface{})
+	SetJSON(key string, value interface{}, expiration ...interface{}) error
 	GetJSON(key string) (jsonBytes []byte, err error)
 	HSet(hash, key string, value interface{}, expiration ...interface{}) error
 	HGet(hash, key string) (interface{}, error)
 	HINCRBY(hash, key string, value interface{}) error
 }
 
+// ContextCacher defines the context aware version of Cacher.
+// The operations return ctx.Err() once the context is canceled or its deadline is exceeded.
+type ContextCacher interface {
+	SetContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error
+	GetContext(ctx context.Context, key string) (interface{}, error)
+	DelContext(ctx context.Context, key string) error
+	ScanContext(ctx context.Context, cursor int, count int, pattern string) (nextCursor int, keys []string, err error)
+	ExpireContext(ctx context.Context, key string, expiration int) error
+	TTLContext(ctx context.Context, key string) (int, error)
+	SetGobContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error
+	GetGobContext(ctx context.Context, key string) (interface{}, error)
+	SetJSONContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error
+	GetJSONContext(ctx context.Context, key string) (jsonBytes []byte, err error)
+	HSetContext(ctx context.Context, hash, key string, value interface{}, expiration ...interface{}) error
+	HGetContext(ctx context.Context, hash, key string) (interface{}, error)
+	HINCRBYContext(ctx context.Context, hash, key string, value interface{}) error
+}
+
+var (
+	_ Cacher        = (*RedisCacher)(nil)
+	_ Cacher        = (*MemoryCacher)(nil)
+	_ ContextCacher = (*RedisCacher)(nil)
+	_ ContextCacher = (*MemoryCacher)(nil)
+)
+
 // GobRegister registers models with gob.
 func GobRegister(models ...interface{}) {
 	for _, model := range models {
 		gob.Register(model)
 	}
 }
+
+// encodeGob wraps the value in a map so that interface values survive the round trip.
+func encodeGob(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	enc := gob.NewEncoder(&buffer)
+	err := enc.Encode(map[interface{}]interface{}{"value": value})
+	return buffer.Bytes(), err
+}
+
+// decodeGob decodes a value encoded by encodeGob.
+func decodeGob(b []byte) (interface{}, error) {
+	value := make(map[interface{}]interface{})
+	buffer := bytes.NewBuffer(b)
+	dec := gob.NewDecoder(buffer)
+	err := dec.Decode(&value)
+	return value["value"], err
+}
+
+// argBytes converts a value to bytes the same way redigo writes a command argument.
+func argBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		b := make([]byte, len(v))
+		copy(b, v)
+		return b
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(nil, v, 10)
+	case float64:
+		return strconv.AppendFloat(nil, v, 'g', -1, 64)
+	case bool:
+		if v {
+			return []byte("1")
+		}
+		return []byte("0")
+	case nil:
+		return []byte{}
+	case redis.Argument:
+		return argBytes(v.RedisArg())
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+// argInt64 parses a command argument as an integer the same way redis does.
+func argInt64(value interface{}) (int64, error) {
+	n, err := strconv.ParseInt(string(argBytes(value)), 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	return n, nil
+}
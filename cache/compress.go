@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is a compression algorithm of the values.
+type Compression byte
+
+// The compression algorithms, their value is stored in the header of the compressed values.
+const (
+	NoCompression Compression = iota
+	Gzip
+	Zstd
+	Snappy
+)
+
+func (c Compression) String() string {
+	switch c {
+	case NoCompression:
+		return "none"
+	case Gzip:
+		return "gzip"
+	case Zstd:
+		return "zstd"
+	case Snappy:
+		return "snappy"
+	}
+	return fmt.Sprintf("Compression(%d)", byte(c))
+}
+
+// compressionMarker starts the header of a compressed value, followed by the algorithm.
+// Neither json nor gob values start with a zero byte, so the values written before the compression was enabled are still read.
+const compressionMarker = 0x00
+
+// CompressionOptions defines how the values written by SetJSON, SetGob and SetValue are compressed.
+type CompressionOptions struct {
+	// Algorithm is the compression algorithm, NoCompression disables the compression.
+	Algorithm Compression
+	// MinSize is the size from which a value is compressed, defaults to 1024 bytes.
+	MinSize int
+}
+
+func (options *CompressionOptions) setDefaults() {
+	if options.MinSize <= 0 {
+		options.MinSize = 1024
+	}
+}
+
+// CompressionInfo describes a value which has been compressed.
+type CompressionInfo struct {
+	Algorithm Compression
+	// Size is the size of the value, StoredSize the size written to redis including the header.
+	// A value which doesn't get smaller is stored uncompressed, then StoredSize is Size.
+	Size       int
+	StoredSize int
+}
+
+// Ratio gets the stored size over the size of the value.
+func (info *CompressionInfo) Ratio() float64 {
+	if info.Size == 0 {
+		return 1
+	}
+	return float64(info.StoredSize) / float64(info.Size)
+}
+
+// CompressionHook is an optional interface of the hooks, AfterCompress is called whenever a value is compressed.
+type CompressionHook interface {
+	AfterCompress(ctx context.Context, info *CompressionInfo)
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+// zstdCodec gets the shared zstd encoder and decoder, they are safe for concurrent use.
+func zstdCodec() (*zstd.Encoder, *zstd.Decoder) {
+	zstdOnce.Do(func() {
+		zstdEncoder, _ = zstd.NewWriter(nil)
+		zstdDecoder, _ = zstd.NewReader(nil)
+	})
+	return zstdEncoder, zstdDecoder
+}
+
+// compress compresses a value with the configured algorithm if it's large enough, and reports it to the hooks.
+func (o *RedisCacher) compress(ctx context.Context, b []byte) ([]byte, error) {
+	options := o.options.Compression
+	if options.Algorithm == NoCompression || len(b) < options.MinSize {
+		return b, nil
+	}
+
+	header := []byte{compressionMarker, byte(options.Algorithm)}
+	var compressed []byte
+	switch options.Algorithm {
+	case Gzip:
+		var buf bytes.Buffer
+		buf.Write(header)
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		compressed = buf.Bytes()
+	case Zstd:
+		encoder, _ := zstdCodec()
+		compressed = encoder.EncodeAll(b, header)
+	case Snappy:
+		compressed = append(header, snappy.Encode(nil, b)...)
+	default:
+		return nil, fmt.Errorf("cache: unknown compression %v", options.Algorithm)
+	}
+
+	info := &CompressionInfo{Algorithm: options.Algorithm, Size: len(b), StoredSize: len(compressed)}
+	if len(compressed) >= len(b) {
+		compressed, info.StoredSize = b, len(b)
+	}
+	for _, hook := range o.options.Hooks {
+		if hook, ok := hook.(CompressionHook); ok {
+			hook.AfterCompress(ctx, info)
+		}
+	}
+	return compressed, nil
+}
+
+// decompress decompresses a value written by compress, the values without the header are returned as is.
+func decompress(b []byte) ([]byte, error) {
+	if len(b) < 2 || b[0] != compressionMarker {
+		return b, nil
+	}
+
+	switch Compression(b[1]) {
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(b[2:]))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	case Zstd:
+		_, decoder := zstdCodec()
+		return decoder.DecodeAll(b[2:], nil)
+	case Snappy:
+		return snappy.Decode(nil, b[2:])
+	}
+	return b, nil
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type document struct {
+	Title string
+	Body  string
+}
+
+func TestCompression(t *testing.T) {
+	GobRegister(&TestValues{})
+	Convey("Test The Compression Of Large Values\n", t, func() {
+		plain, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer plain.ClosePool()
+		defer plain.Flush()
+
+		large := document{Title: "large", Body: strings.Repeat("lorem ipsum dolor sit amet ", 200)}
+		small := document{Title: "small", Body: "lorem ipsum"}
+
+		for _, algorithm := range []Compression{Gzip, Zstd, Snappy} {
+			algorithm := algorithm
+			Convey("Large Values Should Be Compressed With "+algorithm.String(), func() {
+				metrics := NewMetrics()
+				cacher, err := NewRedisCacherWithOptions(RedisOptions{
+					Addr:        "127.0.0.1:6379",
+					DB:          2,
+					Compression: CompressionOptions{Algorithm: algorithm},
+					Hooks:       []Hook{metrics},
+				})
+				So(err, ShouldBeNil)
+				defer cacher.ClosePool()
+
+				So(cacher.SetJSON("large", large), ShouldBeNil)
+				So(cacher.SetJSON("small", small), ShouldBeNil)
+				So(cacher.SetGob("gob", &TestValues{A: large.Body, B: 1, C: 2}), ShouldBeNil)
+				So(cacher.SetValue("value", large, 0), ShouldBeNil)
+
+				raw, err := plain.GetBytes("large")
+				So(err, ShouldBeNil)
+				So(raw[:2], ShouldResemble, []byte{compressionMarker, byte(algorithm)})
+				So(len(raw), ShouldBeLessThan, len(large.Body)/4)
+				raw, _ = plain.GetBytes("small")
+				So(raw[0], ShouldEqual, '{')
+
+				var decoded document
+				b, err := cacher.GetJSON("large")
+				So(err, ShouldBeNil)
+				So(b[0], ShouldEqual, '{')
+				So(cacher.GetInto("value", &decoded), ShouldBeNil)
+				So(decoded, ShouldResemble, large)
+				value, err := cacher.GetGob("gob")
+				So(err, ShouldBeNil)
+				So(value, ShouldResemble, &TestValues{A: large.Body, B: 1, C: 2})
+
+				values, err := plain.MultipleGetJSON("large", "small", "notExist")
+				So(err, ShouldBeNil)
+				So(values[0][0], ShouldEqual, '{')
+				So(values[1][0], ShouldEqual, '{')
+				So(values[2], ShouldBeNil)
+
+				var buf bytes.Buffer
+				metrics.WriteTo(&buf)
+				So(buf.String(), ShouldContainSubstring, `cache_compressed_values_total{algorithm="`+algorithm.String()+`"} 3`)
+				So(buf.String(), ShouldContainSubstring, "cache_compression_output_bytes_total")
+			})
+		}
+
+		Convey("Uncompressed Values Should Still Be Read", func() {
+			So(plain.SetJSON("large", large), ShouldBeNil)
+			cacher, err := NewRedisCacherWithOptions(RedisOptions{
+				Addr:        "127.0.0.1:6379",
+				DB:          2,
+				Compression: CompressionOptions{Algorithm: Zstd},
+			})
+			So(err, ShouldBeNil)
+			defer cacher.ClosePool()
+
+			var decoded document
+			b, err := cacher.GetJSON("large")
+			So(err, ShouldBeNil)
+			So(string(b), ShouldContainSubstring, `"Title":"large"`)
+			missed, err := cacher.MultipleGetJSONInto(map[string]document{"x": decoded}, "large")
+			So(err, ShouldBeNil)
+			So(missed, ShouldBeEmpty)
+		})
+
+		Convey("Incompressible Values Should Be Stored As Is", func() {
+			cacher, err := NewRedisCacherWithOptions(RedisOptions{
+				Addr:        "127.0.0.1:6379",
+				DB:          2,
+				Compression: CompressionOptions{Algorithm: Gzip, MinSize: 1},
+			})
+			So(err, ShouldBeNil)
+			defer cacher.ClosePool()
+
+			So(cacher.SetJSON("tiny", 1), ShouldBeNil)
+			value, err := redis.String(plain.Get("tiny"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "1")
+		})
+
+		Convey("The Tiered Cacher Should Decompress The Values", func() {
+			cacher, err := NewRedisCacherWithOptions(RedisOptions{
+				Addr:        "127.0.0.1:6379",
+				DB:          2,
+				Compression: CompressionOptions{Algorithm: Snappy},
+			})
+			So(err, ShouldBeNil)
+			defer cacher.ClosePool()
+			tiered := NewTieredCacher(cacher, TieredOptions{})
+			defer tiered.Close()
+
+			So(tiered.SetJSON("large", large), ShouldBeNil)
+			raw, _ := plain.GetBytes("large")
+			So(raw[0], ShouldEqual, compressionMarker)
+			for i := 0; i < 2; i++ {
+				b, err := tiered.GetJSON("large")
+				So(err, ShouldBeNil)
+				So(b[0], ShouldEqual, '{')
+			}
+		})
+	})
+}
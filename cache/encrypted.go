@@ -0,0 +1,288 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/WUMUXIAN/go-common-utils/cryptowrapper"
+	"github.com/garyburd/redigo/redis"
+)
+
+var (
+	errNoEncryptionKey    = errors.New("cache: at least one encryption key is required")
+	errNotEncrypted       = errors.New("cache: the value isn't encrypted")
+	errEncryptedIncrement = errors.New("cache: encrypted values can't be incremented")
+)
+
+// encryptionVersion starts the header of an encrypted value, followed by the ID of the key.
+const encryptionVersion = 0x01
+
+// EncryptionKey is an AES key of an EncryptedCacher, the ID is stored with the values it encrypts.
+type EncryptionKey struct {
+	ID byte
+	// Key is 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+	Key []byte
+}
+
+// EncryptedCacher is a view of a cacher which encrypts the values with AES-GCM before they are stored.
+// The name of the key, and of the field for hashes, is authenticated with the value, so that a value copied to another key can't be read.
+// The keys, TTLs and expirations are left as is.
+type EncryptedCacher struct {
+	cacher  ContextCacher
+	current EncryptionKey
+	keys    map[byte][]byte
+}
+
+var (
+	_ Cacher        = (*EncryptedCacher)(nil)
+	_ ContextCacher = (*EncryptedCacher)(nil)
+)
+
+// NewEncryptedCacher creates an encrypted view of cacher.
+// The values are encrypted with the first key, and decrypted with the key they were encrypted with.
+// To rotate the keys put a new key first and keep the old ones until their values have expired or been rewritten.
+func NewEncryptedCacher(cacher Cacher, keys ...EncryptionKey) (*EncryptedCacher, error) {
+	if len(keys) == 0 {
+		return nil, errNoEncryptionKey
+	}
+	e := &EncryptedCacher{current: keys[0], keys: make(map[byte][]byte, len(keys))}
+	for _, key := range keys {
+		switch len(key.Key) {
+		case 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("cache: invalid size %d of the encryption key %d", len(key.Key), key.ID)
+		}
+		if _, ok := e.keys[key.ID]; ok {
+			return nil, fmt.Errorf("cache: duplicate encryption key %d", key.ID)
+		}
+		e.keys[key.ID] = key.Key
+	}
+
+	var ok bool
+	if e.cacher, ok = cacher.(ContextCacher); !ok {
+		e.cacher = noContextCacher{cacher}
+	}
+	return e, nil
+}
+
+// additionalData gets the data authenticated with a value, the header followed by the length prefixed names.
+func additionalData(header []byte, names ...string) []byte {
+	data := append([]byte(nil), header...)
+	for _, name := range names {
+		var n [binary.MaxVarintLen64]byte
+		data = append(data, n[:binary.PutUvarint(n[:], uint64(len(name)))]...)
+		data = append(data, name...)
+	}
+	return data
+}
+
+// seal encrypts a value stored under names with the current key.
+func (e *EncryptedCacher) seal(b []byte, names ...string) ([]byte, error) {
+	header := []byte{encryptionVersion, e.current.ID}
+	sealed, err := cryptowrapper.AESGCMEncrypt(e.current.Key, b, additionalData(header, names...))
+	if err != nil {
+		return nil, err
+	}
+	return append(header, sealed...), nil
+}
+
+// open decrypts a value written by seal.
+func (e *EncryptedCacher) open(reply interface{}, names ...string) ([]byte, error) {
+	b, err := redis.Bytes(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 2 || b[0] != encryptionVersion {
+		return nil, errNotEncrypted
+	}
+	key, ok := e.keys[b[1]]
+	if !ok {
+		return nil, fmt.Errorf("cache: unknown encryption key %d", b[1])
+	}
+	b, err = cryptowrapper.AESGCMDecrypt(key, b[2:], additionalData(b[:2], names...))
+	if b == nil && err == nil {
+		b = []byte{}
+	}
+	return b, err
+}
+
+// Set sets a key value pair, value is encrypted.
+func (e *EncryptedCacher) Set(key string, value interface{}, expiration ...interface{}) error {
+	return e.SetContext(context.Background(), key, value, expiration...)
+}
+
+// SetContext sets a key value pair with context, value is encrypted.
+func (e *EncryptedCacher) SetContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	b, err := e.seal(argBytes(value), key)
+	if err != nil {
+		return err
+	}
+	return e.cacher.SetContext(ctx, key, b, expiration...)
+}
+
+// Get gets the decrypted bytes from key, nil if the key doesn't exist.
+func (e *EncryptedCacher) Get(key string) (interface{}, error) {
+	return e.GetContext(context.Background(), key)
+}
+
+// GetContext gets the decrypted bytes from key with context.
+func (e *EncryptedCacher) GetContext(ctx context.Context, key string) (interface{}, error) {
+	reply, err := e.cacher.GetContext(ctx, key)
+	if reply == nil || err != nil {
+		return nil, err
+	}
+	b, err := e.open(reply, key)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Del deletes a key.
+func (e *EncryptedCacher) Del(key string) {
+	e.DelContext(context.Background(), key)
+}
+
+// DelContext deletes a key with context.
+func (e *EncryptedCacher) DelContext(ctx context.Context, key string) error {
+	return e.cacher.DelContext(ctx, key)
+}
+
+// Scan through the keys with given cursor, pattern and count.
+func (e *EncryptedCacher) Scan(cursor int, count int, pattern string) (nextCursor int, keys []string, err error) {
+	return e.ScanContext(context.Background(), cursor, count, pattern)
+}
+
+// ScanContext scans through the keys with context.
+func (e *EncryptedCacher) ScanContext(ctx context.Context, cursor int, count int, pattern string) (nextCursor int, keys []string, err error) {
+	return e.cacher.ScanContext(ctx, cursor, count, pattern)
+}
+
+// Expire sets a expiration time for key.
+func (e *EncryptedCacher) Expire(key string, expiration int) error {
+	return e.ExpireContext(context.Background(), key, expiration)
+}
+
+// ExpireContext sets a expiration time for key with context.
+func (e *EncryptedCacher) ExpireContext(ctx context.Context, key string, expiration int) error {
+	return e.cacher.ExpireContext(ctx, key, expiration)
+}
+
+// TTL gets the remaining seconds for key.
+func (e *EncryptedCacher) TTL(key string) (int, error) {
+	return e.TTLContext(context.Background(), key)
+}
+
+// TTLContext gets the remaining seconds for key with context.
+func (e *EncryptedCacher) TTLContext(ctx context.Context, key string) (int, error) {
+	return e.cacher.TTLContext(ctx, key)
+}
+
+// SetGob sets a key value pair, value will be gob encoded then encrypted.
+func (e *EncryptedCacher) SetGob(key string, value interface{}, expiration ...interface{}) error {
+	return e.SetGobContext(context.Background(), key, value, expiration...)
+}
+
+// SetGobContext sets a key value pair with context, value will be gob encoded then encrypted.
+func (e *EncryptedCacher) SetGobContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	b, err := encodeGob(value)
+	if err != nil {
+		return err
+	}
+	return e.SetContext(ctx, key, b, expiration...)
+}
+
+// GetGob gets a gob encoded value from key.
+func (e *EncryptedCacher) GetGob(key string) (interface{}, error) {
+	return e.GetGobContext(context.Background(), key)
+}
+
+// GetGobContext gets a gob encoded value from key with context, it returns redis.ErrNil if the key doesn't exist.
+func (e *EncryptedCacher) GetGobContext(ctx context.Context, key string) (interface{}, error) {
+	b, err := e.getBytes(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return decodeGob(b)
+}
+
+// SetJSON sets a key value pair, value will be json encoded then encrypted.
+func (e *EncryptedCacher) SetJSON(key string, value interface{}, expiration ...interface{}) error {
+	return e.SetJSONContext(context.Background(), key, value, expiration...)
+}
+
+// SetJSONContext sets a key value pair with context, value will be json encoded then encrypted.
+func (e *EncryptedCacher) SetJSONContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return e.SetContext(ctx, key, b, expiration...)
+}
+
+// GetJSON gets the json bytes from key.
+func (e *EncryptedCacher) GetJSON(key string) (jsonBytes []byte, err error) {
+	return e.GetJSONContext(context.Background(), key)
+}
+
+// GetJSONContext gets the json bytes from key with context, it returns redis.ErrNil if the key doesn't exist.
+func (e *EncryptedCacher) GetJSONContext(ctx context.Context, key string) (jsonBytes []byte, err error) {
+	return e.getBytes(ctx, key)
+}
+
+// getBytes gets the decrypted bytes from key, redis.ErrNil if the key doesn't exist.
+func (e *EncryptedCacher) getBytes(ctx context.Context, key string) ([]byte, error) {
+	reply, err := e.cacher.GetContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, redis.ErrNil
+	}
+	return e.open(reply, key)
+}
+
+// HSet sets a value for hash set by key, value is encrypted.
+func (e *EncryptedCacher) HSet(hash, key string, value interface{}, expiration ...interface{}) error {
+	return e.HSetContext(context.Background(), hash, key, value, expiration...)
+}
+
+// HSetContext sets a value for hash set by key with context, value is encrypted.
+func (e *EncryptedCacher) HSetContext(ctx context.Context, hash, key string, value interface{}, expiration ...interface{}) error {
+	b, err := e.seal(argBytes(value), hash, key)
+	if err != nil {
+		return err
+	}
+	return e.cacher.HSetContext(ctx, hash, key, b, expiration...)
+}
+
+// HGet gets the decrypted bytes for hash set by key, nil if the field doesn't exist.
+func (e *EncryptedCacher) HGet(hash, key string) (interface{}, error) {
+	return e.HGetContext(context.Background(), hash, key)
+}
+
+// HGetContext gets the decrypted bytes for hash set by key with context.
+func (e *EncryptedCacher) HGetContext(ctx context.Context, hash, key string) (interface{}, error) {
+	reply, err := e.cacher.HGetContext(ctx, hash, key)
+	if reply == nil || err != nil {
+		return nil, err
+	}
+	b, err := e.open(reply, hash, key)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// HINCRBY isn't supported, the encrypted values can't be incremented.
+func (e *EncryptedCacher) HINCRBY(hash, key string, value interface{}) error {
+	return e.HINCRBYContext(context.Background(), hash, key, value)
+}
+
+// HINCRBYContext isn't supported, the encrypted values can't be incremented.
+func (e *EncryptedCacher) HINCRBYContext(ctx context.Context, hash, key string, value interface{}) error {
+	return errEncryptedIncrement
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEncryptedCacher(t *testing.T) {
+	GobRegister(&TestValues{})
+
+	oldKey := EncryptionKey{ID: 1, Key: []byte("Hello I am a 16 ")}
+	newKey := EncryptionKey{ID: 2, Key: []byte("Hello I am a 32 byte key........")}
+
+	Convey("Test The Encrypted Cacher\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		encrypted, err := NewEncryptedCacher(cacher, oldKey)
+		So(err, ShouldBeNil)
+
+		Convey("Values Should Be Encrypted At Rest", func() {
+			So(encrypted.Set("email", "alice@example.com", 60), ShouldBeNil)
+			So(encrypted.SetJSON("profile", map[string]string{"name": "alice"}), ShouldBeNil)
+			So(encrypted.SetGob("gob", &TestValues{"A", 1, int64(1)}), ShouldBeNil)
+			So(encrypted.HSet("users", "1", "alice"), ShouldBeNil)
+
+			raw, err := cacher.GetBytes("email")
+			So(err, ShouldBeNil)
+			So(raw[:2], ShouldResemble, []byte{encryptionVersion, 1})
+			So(bytes.Contains(raw, []byte("alice")), ShouldBeFalse)
+			raw, _ = redis.Bytes(cacher.HGet("users", "1"))
+			So(bytes.Contains(raw, []byte("alice")), ShouldBeFalse)
+			ttl, err := encrypted.TTL("email")
+			So(err, ShouldBeNil)
+			So(ttl, ShouldBeGreaterThan, 0)
+
+			value, err := redis.String(encrypted.Get("email"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "alice@example.com")
+			b, err := encrypted.GetJSON("profile")
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"name":"alice"}`)
+			gob, err := encrypted.GetGob("gob")
+			So(err, ShouldBeNil)
+			So(gob, ShouldResemble, &TestValues{"A", 1, int64(1)})
+			value, err = redis.String(encrypted.HGet("users", "1"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "alice")
+			So(encrypted.HINCRBY("users", "2", 1), ShouldEqual, errEncryptedIncrement)
+		})
+
+		Convey("Missing And Empty Values Should Be Told Apart", func() {
+			reply, err := encrypted.Get("notExist")
+			So(err, ShouldBeNil)
+			So(reply, ShouldBeNil)
+			reply, err = encrypted.HGet("users", "notExist")
+			So(err, ShouldBeNil)
+			So(reply, ShouldBeNil)
+			_, err = encrypted.GetJSON("notExist")
+			So(err, ShouldEqual, redis.ErrNil)
+
+			So(encrypted.Set("empty", ""), ShouldBeNil)
+			reply, err = encrypted.Get("empty")
+			So(err, ShouldBeNil)
+			So(reply, ShouldResemble, []byte{})
+		})
+
+		Convey("Values Moved To Another Key Should Not Be Decrypted", func() {
+			So(encrypted.Set("user:1:email", "alice@example.com"), ShouldBeNil)
+			So(encrypted.HSet("users", "1", "alice"), ShouldBeNil)
+			raw, _ := cacher.GetBytes("user:1:email")
+			So(cacher.Set("user:2:email", raw), ShouldBeNil)
+			_, err := encrypted.Get("user:2:email")
+			So(err, ShouldNotBeNil)
+
+			raw, _ = redis.Bytes(cacher.HGet("users", "1"))
+			So(cacher.HSet("users", "2", raw), ShouldBeNil)
+			_, err = encrypted.HGet("users", "2")
+			So(err, ShouldNotBeNil)
+
+			So(cacher.Set("plain", "alice@example.com"), ShouldBeNil)
+			_, err = encrypted.Get("plain")
+			So(err, ShouldEqual, errNotEncrypted)
+		})
+
+		Convey("Keys Should Be Rotated", func() {
+			So(encrypted.Set("old", "value1"), ShouldBeNil)
+
+			rotated, err := NewEncryptedCacher(cacher, newKey, oldKey)
+			So(err, ShouldBeNil)
+			So(rotated.Set("new", "value2"), ShouldBeNil)
+			raw, _ := cacher.GetBytes("new")
+			So(raw[1], ShouldEqual, 2)
+
+			value, err := redis.String(rotated.Get("old"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "value1")
+			value, err = redis.String(rotated.Get("new"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "value2")
+
+			_, err = encrypted.Get("new")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Invalid Keys Should Be Rejected", func() {
+			_, err := NewEncryptedCacher(cacher)
+			So(err, ShouldEqual, errNoEncryptionKey)
+			_, err = NewEncryptedCacher(cacher, EncryptionKey{ID: 1, Key: []byte("short")})
+			So(err, ShouldNotBeNil)
+			_, err = NewEncryptedCacher(cacher, oldKey, EncryptionKey{ID: 1, Key: newKey.Key})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("The Memory Cacher Should Be Encrypted Too", func() {
+			memory, err := NewEncryptedCacher(NewMemoryCacher(100, 0), newKey)
+			So(err, ShouldBeNil)
+			So(memory.SetJSON("profile", map[string]string{"name": "alice"}), ShouldBeNil)
+			b, err := memory.GetJSON("profile")
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"name":"alice"}`)
+		})
+	})
+}
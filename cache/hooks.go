@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// CommandInfo describes a command sent to redis for the hooks.
+type CommandInfo struct {
+	// Name is the upper cased name of the command.
+	Name string
+	// Key is the first key of the command, empty if the command has no key.
+	Key  string
+	Args []interface{}
+
+	// The following fields are set once the command has completed.
+	Duration time.Duration
+	Err      error
+	// Read tells whether the command looks up a value, Hit tells whether the value was found.
+	Read bool
+	Hit  bool
+}
+
+// Hook is invoked before and after every command sent to redis.
+// Pipelined commands are reported when their replies are received.
+// The hooks must be safe for concurrent use.
+type Hook interface {
+	// BeforeCommand is called before the command is sent, the returned context is passed to AfterCommand,
+	// so a hook can e.g. start a span.
+	BeforeCommand(ctx context.Context, cmd *CommandInfo) context.Context
+	// AfterCommand is called once the command has completed.
+	AfterCommand(ctx context.Context, cmd *CommandInfo)
+}
+
+// readCommands are the commands whose result is a hit if the reply isn't nil.
+var readCommands = map[string]bool{
+	"GET": true, "GETEX": true, "GETDEL": true, "HGET": true, "ZSCORE": true, "LINDEX": true,
+}
+
+// hookedCommand is a command which has been reported to BeforeCommand.
+type hookedCommand struct {
+	info  *CommandInfo
+	ctxs  []context.Context
+	start time.Time
+}
+
+// hookConn is a connection which reports its commands to the hooks.
+type hookConn struct {
+	redis.Conn
+	ctx     context.Context
+	hooks   []Hook
+	pending []*hookedCommand
+}
+
+func (c *hookConn) before(commandName string, args []interface{}) *hookedCommand {
+	info := &CommandInfo{Name: strings.ToUpper(commandName), Args: args}
+	info.Key, _ = commandKey(commandName, args)
+	info.Read = readCommands[info.Name]
+	cmd := &hookedCommand{info: info, ctxs: make([]context.Context, len(c.hooks))}
+	for i, hook := range c.hooks {
+		cmd.ctxs[i] = hook.BeforeCommand(c.ctx, info)
+	}
+	cmd.start = time.Now()
+	return cmd
+}
+
+func (c *hookConn) after(cmd *hookedCommand, reply interface{}, err error) {
+	cmd.info.Duration = time.Since(cmd.start)
+	cmd.info.Err = err
+	cmd.info.Hit = cmd.info.Read && err == nil && reply != nil
+	for i, hook := range c.hooks {
+		hook.AfterCommand(cmd.ctxs[i], cmd.info)
+	}
+}
+
+// flushPending reports the pending commands which complete along with a Do.
+// Their replies are not known, only the error of the Do.
+func (c *hookConn) flushPending(err error) {
+	for _, cmd := range c.pending {
+		c.after(cmd, nil, err)
+	}
+	c.pending = nil
+}
+
+func (c *hookConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return c.DoWithTimeout(0, commandName, args...)
+}
+
+func (c *hookConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	if commandName == "" {
+		reply, err := doWithTimeout(c.Conn, timeout, commandName, args...)
+		c.flushPending(err)
+		return reply, err
+	}
+
+	cmd := c.before(commandName, args)
+	reply, err := doWithTimeout(c.Conn, timeout, commandName, args...)
+	c.flushPending(err)
+	c.after(cmd, reply, err)
+	return reply, err
+}
+
+func (c *hookConn) Send(commandName string, args ...interface{}) error {
+	cmd := c.before(commandName, args)
+	if err := c.Conn.Send(commandName, args...); err != nil {
+		c.after(cmd, nil, err)
+		return err
+	}
+	c.pending = append(c.pending, cmd)
+	return nil
+}
+
+func (c *hookConn) Receive() (interface{}, error) {
+	return c.ReceiveWithTimeout(0)
+}
+
+func (c *hookConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	var reply interface{}
+	var err error
+	if timeout > 0 {
+		reply, err = redis.ReceiveWithTimeout(c.Conn, timeout)
+	} else {
+		reply, err = c.Conn.Receive()
+	}
+	if len(c.pending) > 0 {
+		cmd := c.pending[0]
+		c.pending = c.pending[1:]
+		c.after(cmd, reply, err)
+	}
+	return reply, err
+}
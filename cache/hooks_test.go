@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type contextKey string
+
+// recordHook records the completed commands.
+type recordHook struct {
+	mu       sync.Mutex
+	commands []CommandInfo
+	tagged   int
+}
+
+func (h *recordHook) BeforeCommand(ctx context.Context, cmd *CommandInfo) context.Context {
+	return context.WithValue(ctx, contextKey("tag"), cmd.Name)
+}
+
+func (h *recordHook) AfterCommand(ctx context.Context, cmd *CommandInfo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ctx.Value(contextKey("tag")) == cmd.Name {
+		h.tagged++
+	}
+	h.commands = append(h.commands, *cmd)
+}
+
+func (h *recordHook) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.commands = nil
+	h.tagged = 0
+}
+
+func TestHooks(t *testing.T) {
+	Convey("Test Command Hooks\n", t, func() {
+		hook := &recordHook{}
+		metrics := NewMetrics()
+		var logs bytes.Buffer
+		slowLog := NewSlowLog(0, log.New(&logs, "", 0))
+
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{
+			Addr:  "127.0.0.1:6379",
+			DB:    2,
+			Hooks: []Hook{hook, metrics, slowLog},
+		})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+		hook.reset()
+
+		Convey("Hooks Should See Every Command With Its Key", func() {
+			So(cacher.Set("testKey", "testValue"), ShouldBeNil)
+			_, err := cacher.Get("testKey")
+			So(err, ShouldBeNil)
+			_, err = cacher.Get("notExist")
+			So(err, ShouldBeNil)
+
+			So(len(hook.commands), ShouldEqual, 3)
+			So(hook.tagged, ShouldEqual, 3)
+			So(hook.commands[0].Name, ShouldEqual, "SET")
+			So(hook.commands[0].Key, ShouldEqual, "testKey")
+			So(hook.commands[0].Read, ShouldBeFalse)
+			So(hook.commands[1].Name, ShouldEqual, "GET")
+			So(hook.commands[1].Read, ShouldBeTrue)
+			So(hook.commands[1].Hit, ShouldBeTrue)
+			So(hook.commands[2].Hit, ShouldBeFalse)
+			So(hook.commands[2].Duration, ShouldBeGreaterThan, 0)
+		})
+
+		Convey("Hooks Should See Pipelined Commands And Errors", func() {
+			pipeline := cacher.Pipeline()
+			pipeline.Queue("SET", "testKey", "testValue")
+			pipeline.Queue("GET", "testKey")
+			pipeline.Queue("INCR", "testKey")
+			_, err := pipeline.Exec(context.Background())
+			So(err, ShouldNotBeNil)
+
+			names := make([]string, 0, len(hook.commands))
+			for _, cmd := range hook.commands {
+				names = append(names, cmd.Name)
+			}
+			So(names, ShouldResemble, []string{"SET", "GET", "INCR"})
+			So(hook.commands[1].Hit, ShouldBeTrue)
+			So(hook.commands[2].Err, ShouldNotBeNil)
+		})
+
+		Convey("Metrics Should Be Written In The Prometheus Format", func() {
+			cacher.Set("testKey", "testValue")
+			cacher.Get("testKey")
+			cacher.Get("notExist")
+			cacher.DoContext(context.Background(), "INCR", "testKey")
+
+			var buf bytes.Buffer
+			_, err := metrics.WriteTo(&buf)
+			So(err, ShouldBeNil)
+			text := buf.String()
+			So(text, ShouldContainSubstring, "# TYPE cache_commands_total counter\n")
+			So(text, ShouldContainSubstring, `cache_commands_total{command="GET"} 2`)
+			So(text, ShouldContainSubstring, `cache_command_errors_total{command="INCR"} 1`)
+			So(text, ShouldContainSubstring, `cache_hits_total{command="GET"} 1`)
+			So(text, ShouldContainSubstring, `cache_misses_total{command="GET"} 1`)
+			So(text, ShouldContainSubstring, `cache_command_duration_seconds_bucket{command="GET",le="+Inf"} 2`)
+			So(text, ShouldContainSubstring, `cache_command_duration_seconds_count{command="SET"} 1`)
+		})
+
+		Convey("Slow Commands Should Be Logged Above The Threshold", func() {
+			cacher.Set("testKey", "testValue")
+			So(logs.String(), ShouldContainSubstring, "cache: slow command SET testKey took")
+
+			logs.Reset()
+			slowLog.Threshold = time.Hour
+			cacher.Set("testKey", "testValue")
+			So(logs.Len(), ShouldEqual, 0)
+			slowLog.Threshold = 0
+		})
+
+		Convey("Commands Should Not Be Hooked Without Hooks", func() {
+			plain, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+			So(err, ShouldBeNil)
+			defer plain.ClosePool()
+			So(plain.Set("testKey", "testValue"), ShouldBeNil)
+			So(len(hook.commands), ShouldEqual, 0)
+			So(strings.Contains(logs.String(), "testKey"), ShouldBeFalse)
+		})
+	})
+}
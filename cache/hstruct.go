@@ -0,0 +1,374 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+var errInvalidStruct = errors.New("cache: the value must be a struct or a pointer to one")
+
+// structField is an exported field of a struct mapped to a hash field.
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	// tagged tells whether the name comes from the tag.
+	tagged bool
+}
+
+var structFieldsCache sync.Map
+
+// structFields gets the fields of a struct type, a field is named by its `redis:"name"` tag or its name,
+// and skipped with `redis:"-"`. The fields of the embedded structs without a tag are promoted.
+// Like encoding/json, the embedded structs are walked breadth first, each type once, and among the fields
+// with the same name the shallowest wins, then the tagged one, the others are ambiguous and dropped.
+// The fields of a struct embedded more than once at the same depth are ambiguous too.
+func structFields(t reflect.Type) []structField {
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.([]structField)
+	}
+
+	type embeddedStruct struct {
+		typ   reflect.Type
+		index []int
+	}
+	var fields []structField
+	visited := make(map[reflect.Type]bool)
+	// count is the number of times each type is embedded at the current depth, nextCount at the next one.
+	count, nextCount := map[reflect.Type]int{}, map[reflect.Type]int{t: 1}
+	for next := []embeddedStruct{{typ: t}}; len(next) > 0; {
+		current := next
+		next = nil
+		count, nextCount = nextCount, map[reflect.Type]int{}
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				field := e.typ.Field(i)
+				tag := field.Tag.Get("redis")
+				if tag == "-" {
+					continue
+				}
+				name, options := tag, ""
+				if comma := strings.IndexByte(tag, ','); comma >= 0 {
+					name, options = tag[:comma], tag[comma+1:]
+				}
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+
+				embedded := field.Type
+				if embedded.Kind() == reflect.Ptr {
+					embedded = embedded.Elem()
+				}
+				if field.Anonymous && name == "" && embedded.Kind() == reflect.Struct {
+					if nextCount[embedded]++; nextCount[embedded] == 1 {
+						next = append(next, embeddedStruct{typ: embedded, index: index})
+					}
+					continue
+				}
+				if field.PkgPath != "" {
+					continue
+				}
+				tagged := name != ""
+				if !tagged {
+					name = field.Name
+				}
+				mapped := structField{name: name, index: index, omitEmpty: options == "omitempty", tagged: tagged}
+				fields = append(fields, mapped)
+				if count[e.typ] > 1 {
+					// Listing the field twice makes dominantField drop it.
+					fields = append(fields, mapped)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].name != fields[j].name {
+			return fields[i].name < fields[j].name
+		}
+		if len(fields[i].index) != len(fields[j].index) {
+			return len(fields[i].index) < len(fields[j].index)
+		}
+		return fields[i].tagged && !fields[j].tagged
+	})
+	dominant := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if field, ok := dominantField(fields[i:j]); ok {
+			dominant = append(dominant, field)
+		}
+		i = j
+	}
+	fields = dominant
+	sort.Slice(fields, func(i, j int) bool {
+		for k, x := range fields[i].index {
+			if k >= len(fields[j].index) {
+				return false
+			}
+			if x != fields[j].index[k] {
+				return x < fields[j].index[k]
+			}
+		}
+		return len(fields[i].index) < len(fields[j].index)
+	})
+
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+// dominantField gets the field which wins among the fields with the same name, sorted by depth then tagged first.
+// It's false if the shallowest fields are ambiguous.
+func dominantField(fields []structField) (structField, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return structField{}, false
+	}
+	return fields[0], true
+}
+
+// structValue gets the struct v is or points to.
+func structValue(v interface{}) (reflect.Value, error) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return reflect.Value{}, errInvalidStruct
+	}
+	return value, nil
+}
+
+// isScalar tells whether a kind is written as is to a hash field, the other kinds are encoded by the serializer.
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// HSetStruct writes the exported fields of the struct v to hash with a single HSET, and expires the hash after ttl if ttl > 0.
+// The strings, numbers, booleans and byte slices are written as is, the other types, e.g. time.Time or nested structs,
+// are encoded by the serializer. The nil pointers and the empty fields tagged with omitempty are skipped.
+func (o *RedisCacher) HSetStruct(hash string, v interface{}, ttl time.Duration) error {
+	return o.HSetStructContext(context.Background(), hash, v, ttl)
+}
+
+// HSetStructContext writes the exported fields of the struct v to hash with context.
+func (o *RedisCacher) HSetStructContext(ctx context.Context, hash string, v interface{}, ttl time.Duration) error {
+	value, err := structValue(v)
+	if err != nil {
+		return err
+	}
+
+	args := redis.Args{hash}
+	for _, field := range structFields(value.Type()) {
+		fieldValue, ok := fieldByIndex(value, field.index)
+		if !ok || field.omitEmpty && isEmptyValue(fieldValue) {
+			continue
+		}
+		if fieldValue.Kind() == reflect.Ptr && fieldValue.IsNil() {
+			continue
+		}
+		if isScalar(fieldValue.Type()) {
+			args = append(args, field.name, scalarArg(fieldValue))
+			continue
+		}
+		b, err := o.options.Serializer.Marshal(fieldValue.Interface())
+		if err != nil {
+			return fmt.Errorf("cache: field %s: %v", field.name, err)
+		}
+		args = append(args, field.name, b)
+	}
+	if len(args) == 1 {
+		return nil
+	}
+
+	if ttl <= 0 {
+		_, err = o.DoContext(ctx, "HSET", args...)
+		return err
+	}
+	pipeline := o.Pipeline()
+	pipeline.Queue("HSET", args...)
+	pipeline.Queue("PEXPIRE", hash, durationMilliseconds(ttl))
+	_, err = pipeline.Exec(ctx)
+	return err
+}
+
+// HGetStruct reads hash into the struct dst points to, the fields are mapped like HSetStruct does,
+// and the fields missing from the hash are left as is. It returns ErrCacheMiss if the hash doesn't exist.
+func (o *RedisCacher) HGetStruct(hash string, dst interface{}) error {
+	return o.HGetStructContext(context.Background(), hash, dst)
+}
+
+// HGetStructContext reads hash into the struct dst points to with context.
+func (o *RedisCacher) HGetStructContext(ctx context.Context, hash string, dst interface{}) error {
+	value := reflect.ValueOf(dst)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return errInvalidStruct
+	}
+	value = value.Elem()
+
+	values, err := redis.Values(o.DoContext(ctx, "HGETALL", hash))
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return ErrCacheMiss
+	}
+	fields := make(map[string][]byte, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		name, _ := redis.String(values[i], nil)
+		fields[name], _ = redis.Bytes(values[i+1], nil)
+	}
+
+	for _, field := range structFields(value.Type()) {
+		b, ok := fields[field.name]
+		if !ok {
+			continue
+		}
+		fieldValue, ok := fieldByIndexAlloc(value, field.index)
+		if !ok {
+			continue
+		}
+		if err := o.setField(fieldValue, b); err != nil {
+			return fmt.Errorf("cache: field %s: %v", field.name, err)
+		}
+	}
+	return nil
+}
+
+// scalarArg gets the value of a scalar field with its underlying type,
+// so that e.g. a named integer is written as a number even if it has a String method.
+func scalarArg(field reflect.Value) interface{} {
+	switch field.Kind() {
+	case reflect.String:
+		return field.String()
+	case reflect.Slice:
+		return field.Bytes()
+	case reflect.Bool:
+		return field.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(field.Uint(), 10)
+	default:
+		return strconv.FormatFloat(field.Float(), 'g', -1, field.Type().Bits())
+	}
+}
+
+// setField decodes a hash field into a struct field.
+func (o *RedisCacher) setField(field reflect.Value, b []byte) error {
+	if !isScalar(field.Type()) {
+		return o.options.Serializer.Unmarshal(b, field.Addr().Interface())
+	}
+
+	s := string(b)
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Slice:
+		field.SetBytes(append([]byte(nil), b...))
+	case reflect.Bool:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(s, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(v)
+	}
+	return nil
+}
+
+// fieldByIndex gets the field at index, it returns false if it's promoted from a nil pointer to an embedded struct.
+func fieldByIndex(value reflect.Value, index []int) (reflect.Value, bool) {
+	for i, n := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(n)
+	}
+	return value, true
+}
+
+// fieldByIndexAlloc gets the field at index, allocating the nil pointers to the embedded structs on the way.
+// It returns false if the field can't be set, e.g. it's promoted from an unexported embedded pointer.
+func fieldByIndexAlloc(value reflect.Value, index []int) (reflect.Value, bool) {
+	for i, n := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				if !value.CanSet() {
+					return reflect.Value{}, false
+				}
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(n)
+	}
+	return value, value.CanSet()
+}
+
+// isEmptyValue tells whether a value is empty as defined by omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			return t.IsZero()
+		}
+	}
+	return false
+}
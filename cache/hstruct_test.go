@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type TestStatus int
+
+func (s TestStatus) String() string {
+	return "status"
+}
+
+type TestAudit struct {
+	CreatedBy string `redis:"created_by"`
+}
+
+type TestAddress struct {
+	City string
+}
+
+type TestProfile struct {
+	*TestAudit
+	ID       int64       `redis:"id"`
+	Name     string      `redis:"name"`
+	Score    float32     `redis:"score"`
+	Active   bool        `redis:"active"`
+	Status   TestStatus  `redis:"status"`
+	Avatar   []byte      `redis:"avatar"`
+	Joined   time.Time   `redis:"joined"`
+	Address  TestAddress `redis:"address"`
+	Tags     []string    `redis:"tags,omitempty"`
+	Manager  *string     `redis:"manager"`
+	Password string      `redis:"-"`
+	Nickname string
+	secret   string
+}
+
+type TestNode struct {
+	*TestNode
+	Name string
+}
+
+type TestNamed struct {
+	Name string
+	Kind string
+}
+
+type TestKind struct {
+	Kind string `redis:"kind"`
+}
+
+type TestShadowed struct {
+	TestNamed
+	TestKind
+	Name string
+}
+
+type TestPlace struct {
+	Name string
+	City string
+}
+
+type TestAmbiguous struct {
+	TestNamed
+	TestPlace
+	Town string `redis:"Kind"`
+}
+
+type TestHome struct {
+	TestPlace
+}
+
+type TestWork struct {
+	TestPlace
+}
+
+// TestTwice embeds TestPlace twice at the same depth.
+type TestTwice struct {
+	TestHome
+	TestWork
+	Zip string
+}
+
+func TestHashStruct(t *testing.T) {
+	Convey("Test The Struct To Hash Mapping\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		joined := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		profile := TestProfile{
+			TestAudit: &TestAudit{CreatedBy: "admin"},
+			ID:        1,
+			Name:      "alice",
+			Score:     0.1,
+			Active:    true,
+			Status:    2,
+			Avatar:    []byte{0, 1, 2},
+			Joined:    joined,
+			Address:   TestAddress{City: "Singapore"},
+			Password:  "secret",
+			Nickname:  "al",
+			secret:    "secret",
+		}
+
+		Convey("The Fields Should Be Written To The Hash", func() {
+			So(cacher.HSetStruct("profile:1", &profile, time.Minute), ShouldBeNil)
+			fields, err := redis.StringMap(cacher.HGetAll("profile:1"))
+			So(err, ShouldBeNil)
+			So(fields, ShouldResemble, map[string]string{
+				"created_by": "admin",
+				"id":         "1",
+				"name":       "alice",
+				"score":      "0.1",
+				"active":     "1",
+				"status":     "2",
+				"avatar":     "\x00\x01\x02",
+				"joined":     `"2020-01-02T03:04:05Z"`,
+				"address":    `{"City":"Singapore"}`,
+				"Nickname":   "al",
+			})
+			ttl, _ := cacher.TTL("profile:1")
+			So(ttl, ShouldBeBetweenOrEqual, 59, 60)
+
+			var loaded TestProfile
+			So(cacher.HGetStruct("profile:1", &loaded), ShouldBeNil)
+			expected := profile
+			expected.Password, expected.secret = "", ""
+			So(loaded, ShouldResemble, expected)
+		})
+
+		Convey("The Pointers And Omitted Fields Should Round Trip", func() {
+			manager := "bob"
+			profile.TestAudit = nil
+			profile.Manager = &manager
+			profile.Tags = []string{"a", "b"}
+			So(cacher.HSetStruct("profile:2", profile, 0), ShouldBeNil)
+			ttl, _ := cacher.TTL("profile:2")
+			So(ttl, ShouldEqual, -1)
+
+			var loaded TestProfile
+			So(cacher.HGetStruct("profile:2", &loaded), ShouldBeNil)
+			So(loaded.TestAudit, ShouldBeNil)
+			So(*loaded.Manager, ShouldEqual, "bob")
+			So(loaded.Tags, ShouldResemble, []string{"a", "b"})
+		})
+
+		Convey("Missing Hashes And Invalid Values Should Be Reported", func() {
+			var loaded TestProfile
+			So(cacher.HGetStruct("notExist", &loaded), ShouldEqual, ErrCacheMiss)
+			So(cacher.HGetStruct("notExist", loaded), ShouldEqual, errInvalidStruct)
+			So(cacher.HSetStruct("profile", 1, 0), ShouldEqual, errInvalidStruct)
+
+			So(cacher.HSet("profile:3", "id", "abc"), ShouldBeNil)
+			So(cacher.HGetStruct("profile:3", &loaded), ShouldNotBeNil)
+		})
+
+		Convey("The Expiration Of HSet Should Apply To The Hash", func() {
+			So(cacher.HSet("hash", "field", "value", 10), ShouldBeNil)
+			ttl, _ := cacher.TTL("hash")
+			So(ttl, ShouldBeBetweenOrEqual, 9, 10)
+		})
+
+		Convey("Self Embedding Structs Should Be Walked Once", func() {
+			So(structFields(reflect.TypeOf(TestNode{})), ShouldResemble, []structField{{name: "Name", index: []int{1}}})
+			So(cacher.HSetStruct("node", &TestNode{TestNode: &TestNode{Name: "parent"}, Name: "child"}, 0), ShouldBeNil)
+			var loaded TestNode
+			So(cacher.HGetStruct("node", &loaded), ShouldBeNil)
+			So(loaded.Name, ShouldEqual, "child")
+		})
+
+		Convey("Duplicate Names Should Be Resolved By Depth", func() {
+			So(structFields(reflect.TypeOf(TestShadowed{})), ShouldResemble, []structField{
+				{name: "Kind", index: []int{0, 1}},
+				{name: "kind", index: []int{1, 0}, tagged: true},
+				{name: "Name", index: []int{2}},
+			})
+			So(structFields(reflect.TypeOf(TestAmbiguous{})), ShouldResemble, []structField{
+				{name: "City", index: []int{1, 1}},
+				{name: "Kind", index: []int{2}, tagged: true},
+			})
+			So(structFields(reflect.TypeOf(TestTwice{})), ShouldResemble, []structField{{name: "Zip", index: []int{2}}})
+		})
+	})
+}
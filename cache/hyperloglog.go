@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// PFAdd adds elements to the HyperLogLog key, it returns true if the estimated cardinality has changed.
+func (o *RedisCacher) PFAdd(key string, elements ...interface{}) (bool, error) {
+	return o.PFAddContext(context.Background(), key, elements...)
+}
+
+// PFAddContext adds elements to the HyperLogLog key with context.
+func (o *RedisCacher) PFAddContext(ctx context.Context, key string, elements ...interface{}) (bool, error) {
+	return redis.Bool(o.DoContext(ctx, "PFADD", redis.Args{key}.Add(elements...)...))
+}
+
+// PFCount gets the estimated number of unique elements in the HyperLogLog keys, the union of them if there are several,
+// with a standard error of 0.81%.
+func (o *RedisCacher) PFCount(keys ...string) (int, error) {
+	return o.PFCountContext(context.Background(), keys...)
+}
+
+// PFCountContext gets the estimated number of unique elements in the HyperLogLog keys with context.
+func (o *RedisCacher) PFCountContext(ctx context.Context, keys ...string) (int, error) {
+	return redis.Int(o.DoContext(ctx, "PFCOUNT", redis.Args{}.AddFlat(keys)...))
+}
+
+// PFMerge merges the HyperLogLog keys into destination, e.g. the daily counts of unique visitors into a weekly one.
+func (o *RedisCacher) PFMerge(destination string, keys ...string) error {
+	return o.PFMergeContext(context.Background(), destination, keys...)
+}
+
+// PFMergeContext merges the HyperLogLog keys into destination with context.
+func (o *RedisCacher) PFMergeContext(ctx context.Context, destination string, keys ...string) error {
+	_, err := o.DoContext(ctx, "PFMERGE", redis.Args{destination}.AddFlat(keys)...)
+	return err
+}
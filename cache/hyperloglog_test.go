@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	Convey("Test The HyperLogLog Commands\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		changed, err := cacher.PFAdd("visitors:monday", "alice", "bob")
+		So(err, ShouldBeNil)
+		So(changed, ShouldBeTrue)
+		changed, _ = cacher.PFAdd("visitors:monday", "alice")
+		So(changed, ShouldBeFalse)
+		for i := 0; i < 1000; i++ {
+			cacher.PFAdd("visitors:tuesday", fmt.Sprintf("visitor%d", i))
+		}
+
+		count, err := cacher.PFCount("visitors:monday")
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+		count, _ = cacher.PFCount("visitors:tuesday")
+		So(count, ShouldBeBetween, 970, 1030)
+		count, _ = cacher.PFCount("visitors:monday", "visitors:tuesday")
+		So(count, ShouldBeBetween, 970, 1030)
+
+		So(cacher.PFMerge("visitors:week", "visitors:monday", "visitors:tuesday"), ShouldBeNil)
+		week, _ := cacher.PFCount("visitors:week")
+		So(week, ShouldEqual, count)
+		count, _ = cacher.PFCount("notExist")
+		So(count, ShouldEqual, 0)
+	})
+}
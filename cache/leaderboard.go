@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// LeaderboardEntry is a member of a leaderboard with its score and rank, the first rank is 1.
+type LeaderboardEntry struct {
+	Member string
+	Score  float64
+	Rank   int
+}
+
+// Leaderboard ranks members by their scores in a sorted set, the highest score first.
+// Members with the same score are ranked in reverse lexicographical order.
+type Leaderboard struct {
+	cacher *RedisCacher
+	key    string
+}
+
+// NewLeaderboard creates a leaderboard stored in the sorted set key.
+func (o *RedisCacher) NewLeaderboard(key string) *Leaderboard {
+	return &Leaderboard{cacher: o, key: key}
+}
+
+// SetScore sets the score of member.
+func (l *Leaderboard) SetScore(ctx context.Context, member string, score float64) error {
+	_, err := l.cacher.ZAddContext(ctx, l.key, ZAddOptions{}, Z{Member: member, Score: score})
+	return err
+}
+
+// SetBestScore sets the score of member only if it's higher than the current one.
+func (l *Leaderboard) SetBestScore(ctx context.Context, member string, score float64) error {
+	_, err := l.cacher.ZAddContext(ctx, l.key, ZAddOptions{GT: true}, Z{Member: member, Score: score})
+	return err
+}
+
+// Incr adds delta to the score of member and returns the new score.
+func (l *Leaderboard) Incr(ctx context.Context, member string, delta float64) (float64, error) {
+	return l.cacher.ZIncrByContext(ctx, l.key, delta, member)
+}
+
+// Remove removes members from the leaderboard.
+func (l *Leaderboard) Remove(ctx context.Context, members ...string) error {
+	_, err := l.cacher.ZRemContext(ctx, l.key, members...)
+	return err
+}
+
+// Len gets the number of members in the leaderboard.
+func (l *Leaderboard) Len(ctx context.Context) (int, error) {
+	return l.cacher.ZCardContext(ctx, l.key)
+}
+
+// Get gets the score and rank of member, it returns redis.ErrNil if the member isn't in the leaderboard.
+func (l *Leaderboard) Get(ctx context.Context, member string) (*LeaderboardEntry, error) {
+	pipeline := l.cacher.Pipeline()
+	rank := pipeline.Queue("ZREVRANK", l.key, member)
+	score := pipeline.Queue("ZSCORE", l.key, member)
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return nil, err
+	}
+	entry := &LeaderboardEntry{Member: member}
+	var err error
+	if entry.Rank, err = redis.Int(rank.Reply, nil); err != nil {
+		return nil, err
+	}
+	entry.Rank++
+	if entry.Score, err = redis.Float64(score.Reply, nil); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Page gets the entries of a page, the first page is 1.
+func (l *Leaderboard) Page(ctx context.Context, page, pageSize int) ([]LeaderboardEntry, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, nil
+	}
+	return l.rangeEntries(ctx, (page-1)*pageSize, page*pageSize-1)
+}
+
+// Around gets the entry of member with up to n entries ranked above and n entries ranked below it.
+// It returns redis.ErrNil if the member isn't in the leaderboard.
+func (l *Leaderboard) Around(ctx context.Context, member string, n int) ([]LeaderboardEntry, error) {
+	if n < 0 {
+		return nil, errors.New("cache: the number of entries around a member can't be negative")
+	}
+	rank, err := l.cacher.ZRevRankContext(ctx, l.key, member)
+	if err != nil {
+		return nil, err
+	}
+	start := rank - n
+	if start < 0 {
+		start = 0
+	}
+	return l.rangeEntries(ctx, start, rank+n)
+}
+
+// rangeEntries gets the entries from index start to stop inclusive.
+func (l *Leaderboard) rangeEntries(ctx context.Context, start, stop int) ([]LeaderboardEntry, error) {
+	members, err := l.cacher.ZRevRangeContext(ctx, l.key, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]LeaderboardEntry, len(members))
+	for i, member := range members {
+		entries[i] = LeaderboardEntry{Member: member.Member, Score: member.Score, Rank: start + i + 1}
+	}
+	return entries, nil
+}
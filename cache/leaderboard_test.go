@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLeaderboard(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Test Leaderboard\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		leaderboard := cacher.NewLeaderboard("leaderboard")
+		// player0 has the score 0 and player9 has the best score 90.
+		for i := 0; i < 10; i++ {
+			So(leaderboard.SetScore(ctx, fmt.Sprintf("player%d", i), float64(i*10)), ShouldBeNil)
+		}
+
+		Convey("Get Should Return The Rank And Score", func() {
+			entry, err := leaderboard.Get(ctx, "player9")
+			So(err, ShouldBeNil)
+			So(*entry, ShouldResemble, LeaderboardEntry{Member: "player9", Score: 90, Rank: 1})
+			_, err = leaderboard.Get(ctx, "notExist")
+			So(err, ShouldEqual, redis.ErrNil)
+			n, _ := leaderboard.Len(ctx)
+			So(n, ShouldEqual, 10)
+		})
+
+		Convey("Scores Should Be Updated", func() {
+			score, err := leaderboard.Incr(ctx, "player0", 95)
+			So(err, ShouldBeNil)
+			So(score, ShouldEqual, 95)
+			So(leaderboard.SetBestScore(ctx, "player8", 10), ShouldBeNil)
+			So(leaderboard.SetBestScore(ctx, "player7", 100), ShouldBeNil)
+
+			entries, _ := leaderboard.Page(ctx, 1, 3)
+			So(entries, ShouldResemble, []LeaderboardEntry{
+				{Member: "player7", Score: 100, Rank: 1},
+				{Member: "player0", Score: 95, Rank: 2},
+				{Member: "player9", Score: 90, Rank: 3},
+			})
+			So(leaderboard.Remove(ctx, "player7"), ShouldBeNil)
+			entry, _ := leaderboard.Get(ctx, "player0")
+			So(entry.Rank, ShouldEqual, 1)
+		})
+
+		Convey("Page Should Return The Entries Of The Page", func() {
+			entries, err := leaderboard.Page(ctx, 2, 4)
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 4)
+			So(entries[0], ShouldResemble, LeaderboardEntry{Member: "player5", Score: 50, Rank: 5})
+			entries, _ = leaderboard.Page(ctx, 3, 4)
+			So(len(entries), ShouldEqual, 2)
+			So(entries[1].Rank, ShouldEqual, 10)
+			entries, _ = leaderboard.Page(ctx, 4, 4)
+			So(len(entries), ShouldEqual, 0)
+		})
+
+		Convey("Around Should Return The Neighbours Of The Member", func() {
+			entries, err := leaderboard.Around(ctx, "player5", 2)
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 5)
+			So(entries[0].Member, ShouldEqual, "player7")
+			So(entries[2], ShouldResemble, LeaderboardEntry{Member: "player5", Score: 50, Rank: 5})
+			So(entries[4].Member, ShouldEqual, "player3")
+
+			entries, _ = leaderboard.Around(ctx, "player9", 2)
+			So(len(entries), ShouldEqual, 3)
+			So(entries[0].Rank, ShouldEqual, 1)
+
+			_, err = leaderboard.Around(ctx, "notExist", 2)
+			So(err, ShouldEqual, redis.ErrNil)
+
+			_, err = leaderboard.Around(ctx, "player5", -1)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+var errClosed = errors.New("cache: the cacher is closed")
+
+// LPush inserts values at the head of the list key and returns its length.
+func (o *RedisCacher) LPush(key string, values ...interface{}) (int, error) {
+	return o.LPushContext(context.Background(), key, values...)
+}
+
+// LPushContext inserts values at the head of the list key with context.
+func (o *RedisCacher) LPushContext(ctx context.Context, key string, values ...interface{}) (int, error) {
+	return redis.Int(o.DoContext(ctx, "LPUSH", redis.Args{key}.Add(values...)...))
+}
+
+// RPush inserts values at the tail of the list key and returns its length.
+func (o *RedisCacher) RPush(key string, values ...interface{}) (int, error) {
+	return o.RPushContext(context.Background(), key, values...)
+}
+
+// RPushContext inserts values at the tail of the list key with context.
+func (o *RedisCacher) RPushContext(ctx context.Context, key string, values ...interface{}) (int, error) {
+	return redis.Int(o.DoContext(ctx, "RPUSH", redis.Args{key}.Add(values...)...))
+}
+
+// LLen gets the length of the list key.
+func (o *RedisCacher) LLen(key string) (int, error) {
+	return o.LLenContext(context.Background(), key)
+}
+
+// LLenContext gets the length of the list key with context.
+func (o *RedisCacher) LLenContext(ctx context.Context, key string) (int, error) {
+	return redis.Int(o.DoContext(ctx, "LLEN", key))
+}
+
+// LRange gets the values of the list key from start to stop included, negative indexes count from the tail.
+func (o *RedisCacher) LRange(key string, start, stop int) ([][]byte, error) {
+	return o.LRangeContext(context.Background(), key, start, stop)
+}
+
+// LRangeContext gets the values of the list key from start to stop included with context.
+func (o *RedisCacher) LRangeContext(ctx context.Context, key string, start, stop int) ([][]byte, error) {
+	return redis.ByteSlices(o.DoContext(ctx, "LRANGE", key, start, stop))
+}
+
+// LRem removes count occurrences of value from the list key and returns how many were removed,
+// from the head if count > 0, from the tail if count < 0 and all of them if count is 0.
+func (o *RedisCacher) LRem(key string, count int, value interface{}) (int, error) {
+	return o.LRemContext(context.Background(), key, count, value)
+}
+
+// LRemContext removes count occurrences of value from the list key with context.
+func (o *RedisCacher) LRemContext(ctx context.Context, key string, count int, value interface{}) (int, error) {
+	return redis.Int(o.DoContext(ctx, "LREM", key, count, value))
+}
+
+// LMove pops a value from the side "LEFT" or "RIGHT" of source and pushes it to the side of destination, atomically.
+// It returns redis.ErrNil if source is empty.
+func (o *RedisCacher) LMove(source, destination, sourceSide, destinationSide string) ([]byte, error) {
+	return o.LMoveContext(context.Background(), source, destination, sourceSide, destinationSide)
+}
+
+// LMoveContext pops a value from source and pushes it to destination with context.
+func (o *RedisCacher) LMoveContext(ctx context.Context, source, destination, sourceSide, destinationSide string) ([]byte, error) {
+	return redis.Bytes(o.DoContext(ctx, "LMOVE", source, destination, sourceSide, destinationSide))
+}
+
+// BRPop pops a value from the tail of the first non empty list of keys, waiting up to timeout for one, 0 waits forever.
+// It returns the list and the value, or redis.ErrNil if the timeout expires.
+func (o *RedisCacher) BRPop(timeout time.Duration, keys ...string) (key string, value []byte, err error) {
+	return o.BRPopContext(context.Background(), timeout, keys...)
+}
+
+// BRPopContext pops a value from the tail of the first non empty list of keys with context.
+// A value popped after the context is done is lost, use BLMove to keep it.
+func (o *RedisCacher) BRPopContext(ctx context.Context, timeout time.Duration, keys ...string) (key string, value []byte, err error) {
+	values, err := redis.Values(o.doBlocking(ctx, timeout, "BRPOP", redis.Args{}.AddFlat(keys).Add(blockingSeconds(timeout))...))
+	if err != nil {
+		return "", nil, err
+	}
+	_, err = redis.Scan(values, &key, &value)
+	return key, value, err
+}
+
+// BLMove is the blocking version of LMove, it waits up to timeout for source to get a value, 0 waits forever.
+// It returns redis.ErrNil if the timeout expires.
+func (o *RedisCacher) BLMove(source, destination, sourceSide, destinationSide string, timeout time.Duration) ([]byte, error) {
+	return o.BLMoveContext(context.Background(), source, destination, sourceSide, destinationSide, timeout)
+}
+
+// BLMoveContext is the blocking version of LMoveContext.
+// A value moved after the context is done stays in destination.
+func (o *RedisCacher) BLMoveContext(ctx context.Context, source, destination, sourceSide, destinationSide string, timeout time.Duration) ([]byte, error) {
+	return redis.Bytes(o.doBlocking(ctx, timeout, "BLMOVE", source, destination, sourceSide, destinationSide, blockingSeconds(timeout)))
+}
+
+// blockingSeconds formats the timeout of a blocking command, redis takes it in seconds.
+func blockingSeconds(timeout time.Duration) string {
+	return strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64)
+}
+
+// blockingPool gets the pool of the blocking commands, so that they don't hold the connections of the other commands.
+// It's nil once the cacher is closed.
+func (o *RedisCacher) blockingPool() *redis.Pool {
+	o.blockingOnce.Do(func() {
+		o.blocking = o.newPool(o.dial)
+	})
+	return o.blocking
+}
+
+// doBlocking sends a command which blocks up to timeout on a connection of its own, taken from the pools of the blocking commands
+// of the nodes in cluster mode.
+// The read timeout of the connection is the timeout of the command plus ReadTimeout, or a second if there's none,
+// so that the reply isn't cut by the ReadTimeout of the other commands.
+func (o *RedisCacher) doBlocking(ctx context.Context, timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var conn redis.Conn
+	if o.cluster != nil {
+		conn = o.cluster.blockingConn(ctx)
+	} else if pool := o.blockingPool(); pool != nil {
+		var err error
+		if conn, err = pool.GetContext(ctx); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, errClosed
+	}
+	conn = o.hook(ctx, conn)
+
+	readTimeout := time.Duration(0)
+	if timeout > 0 {
+		readTimeout = o.options.ReadTimeout
+		if readTimeout <= 0 {
+			readTimeout = time.Second
+		}
+		readTimeout += timeout
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if until := time.Until(deadline); readTimeout == 0 || until < readTimeout {
+			readTimeout = until
+		}
+	}
+
+	return runConn(ctx, conn, func(conn redis.Conn) (interface{}, error) {
+		return redis.DoWithTimeout(conn, readTimeout, commandName, args...)
+	})
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestList(t *testing.T) {
+	Convey("Test The List Commands\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{
+			Addr:        "127.0.0.1:6379",
+			DB:          2,
+			MaxActive:   1,
+			ReadTimeout: 50 * time.Millisecond,
+		})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		Convey("Values Should Be Pushed, Read And Removed", func() {
+			n, err := cacher.LPush("list", "b", "a")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			n, err = cacher.RPush("list", "c", "a")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 4)
+			values, err := cacher.LRange("list", 0, -1)
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("a")})
+
+			n, err = cacher.LRem("list", -1, "a")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			n, err = cacher.LLen("list")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 3)
+
+			value, err := cacher.LMove("list", "other", "RIGHT", "LEFT")
+			So(err, ShouldBeNil)
+			So(string(value), ShouldEqual, "c")
+			_, err = cacher.LMove("notExist", "other", "RIGHT", "LEFT")
+			So(err, ShouldEqual, redis.ErrNil)
+		})
+
+		Convey("Blocking Pops Should Outlast The Read Timeout Without Holding The Pool", func() {
+			start := time.Now()
+			_, _, err := cacher.BRPop(200*time.Millisecond, "empty1", "empty2")
+			So(err, ShouldEqual, redis.ErrNil)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 200*time.Millisecond)
+
+			done := make(chan []byte, 1)
+			go func() {
+				value, _ := cacher.BLMove("jobs", "processing", "RIGHT", "LEFT", time.Second)
+				done <- value
+			}()
+			time.Sleep(50 * time.Millisecond)
+			_, err = cacher.LPush("jobs", "job1")
+			So(err, ShouldBeNil)
+			So(string(<-done), ShouldEqual, "job1")
+			values, _ := cacher.LRange("processing", 0, -1)
+			So(values, ShouldResemble, [][]byte{[]byte("job1")})
+
+			cacher.RPush("jobs", "job2")
+			key, value, err := cacher.BRPop(0, "empty", "jobs")
+			So(err, ShouldBeNil)
+			So(key, ShouldEqual, "jobs")
+			So(string(value), ShouldEqual, "job2")
+		})
+
+		Convey("Blocking Pops Should Return When The Context Is Done", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, err := cacher.BLMoveContext(ctx, "empty", "processing", "RIGHT", "LEFT", 0)
+			So(err, ShouldResemble, context.DeadlineExceeded)
+		})
+	})
+}
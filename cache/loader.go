@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// ErrNotFound can be returned by a LoaderFunc to tell that the value doesn't exist.
+// ReadThrough caches this result for NegativeTTL and keeps returning ErrNotFound meanwhile.
+var ErrNotFound = errors.New("cache: not found")
+
+// LoaderFunc loads the value for a key when it's not cached.
+type LoaderFunc func(ctx context.Context) (interface{}, error)
+
+// ReadThrough implements the read-through pattern on top of a cacher.
+// Concurrent misses for the same key in the same process are collapsed into one call of the loader.
+// Values are stored gob encoded like SetGob does, so custom types must be registered with GobRegister.
+type ReadThrough struct {
+	cacher ContextCacher
+	group  flightGroup
+
+	// Beta enables probabilistic early refresh (XFetch) when it's greater than 0.
+	// A hit is refreshed before it expires with a probability that grows as the expiry approaches
+	// and as the loader gets slower, 1 is a good default and greater values refresh earlier.
+	Beta float64
+	// NegativeTTL is how long an ErrNotFound result of the loader is cached, 0 disables negative caching.
+	NegativeTTL time.Duration
+	// LoadTimeout bounds a shared load, whose context isn't canceled with the callers' ones,
+	// so that a hanging loader doesn't block the key forever. It's a minute by default, 0 disables it.
+	LoadTimeout time.Duration
+}
+
+// loadedValue is the cached form of a loaded value.
+type loadedValue struct {
+	Value    interface{}
+	NotFound bool
+	// Delta is how long the loader took and Expiry is when the value expires, both in nanoseconds.
+	Delta  int64
+	Expiry int64
+}
+
+// NewReadThrough creates a read-through cache on top of cacher.
+func NewReadThrough(cacher ContextCacher) *ReadThrough {
+	return &ReadThrough{cacher: cacher, LoadTimeout: time.Minute}
+}
+
+// GetOrLoad gets the value of key, calling loader and caching its result for ttl when the key isn't cached.
+// The ttl is rounded up to whole seconds.
+func (o *ReadThrough) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	entry, err := o.get(ctx, key)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	// The loader is still the source of truth when the cache isn't reachable.
+	if err == nil && entry != nil && !o.shouldRefresh(entry) {
+		if entry.NotFound {
+			return nil, ErrNotFound
+		}
+		return entry.Value, nil
+	}
+
+	// The shared load doesn't stop when the caller which started it gives up, the other callers may still wait for it.
+	return o.group.do(ctx, key, func() (interface{}, error) {
+		loadCtx := context.Context(detachedContext{ctx})
+		if o.LoadTimeout > 0 {
+			var cancel context.CancelFunc
+			loadCtx, cancel = context.WithTimeout(loadCtx, o.LoadTimeout)
+			defer cancel()
+		}
+		return o.load(loadCtx, key, ttl, loader)
+	})
+}
+
+func (o *ReadThrough) get(ctx context.Context, key string) (*loadedValue, error) {
+	b, err := redis.Bytes(o.cacher.GetContext(ctx, key))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entry loadedValue
+	if err = (GobSerializer{}).Unmarshal(b, &entry); err != nil {
+		// Treat values which aren't written by ReadThrough as misses, they'll be overwritten.
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// shouldRefresh implements XFetch: refresh when now - delta * beta * ln(rand()) >= expiry.
+func (o *ReadThrough) shouldRefresh(entry *loadedValue) bool {
+	if o.Beta <= 0 || entry.NotFound || entry.Expiry == 0 {
+		return false
+	}
+	gap := -float64(entry.Delta) * o.Beta * math.Log(rand.Float64())
+	return float64(time.Now().UnixNano())+gap >= float64(entry.Expiry)
+}
+
+func (o *ReadThrough) load(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	start := time.Now()
+	value, err := loader(ctx)
+	delta := time.Since(start)
+
+	if err == ErrNotFound {
+		if o.NegativeTTL > 0 {
+			o.set(ctx, key, &loadedValue{NotFound: true}, o.NegativeTTL)
+		}
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Failing to cache the value doesn't fail the call, the loaded value is still good.
+	// A value which doesn't expire has no expiry, so that it's never refreshed early.
+	entry := &loadedValue{Value: value, Delta: int64(delta)}
+	if ttl > 0 {
+		entry.Expiry = time.Now().Add(ttl).UnixNano()
+	}
+	o.set(ctx, key, entry, ttl)
+	return value, nil
+}
+
+func (o *ReadThrough) set(ctx context.Context, key string, entry *loadedValue, ttl time.Duration) error {
+	b, err := (GobSerializer{}).Marshal(entry)
+	if err != nil {
+		return err
+	}
+	seconds := int64(math.Ceil(ttl.Seconds()))
+	if seconds <= 0 {
+		return o.cacher.SetContext(ctx, key, b)
+	}
+	return o.cacher.SetContext(ctx, key, b, seconds)
+}
+
+// flightGroup collapses concurrent calls with the same key into one.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+	// panicked is the value fn panicked with, if it did.
+	panicked interface{}
+}
+
+// do calls fn unless a call for key is in flight, in which case it waits for that call's result.
+// fn runs in its own goroutine and each caller, the one which started it included, gives up with ctx.Err() when its context is done.
+// If fn panics the waiting callers get an error, and the panic is raised again in the caller which started it.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		call = &flightCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go g.call(key, call, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if !ok && call.panicked != nil {
+			panic(call.panicked)
+		}
+		return call.value, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (g *flightGroup) call(key string, call *flightCall, fn func() (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.panicked = r
+			call.err = fmt.Errorf("cache: the loader panicked: %v", r)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	call.value, call.err = fn()
+}
+
+// detachedContext keeps the values of its parent but is never canceled.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
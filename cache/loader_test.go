@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadThrough(t *testing.T) {
+	GobRegister(&TestValues{})
+	ctx := context.Background()
+
+	Convey("Test The Read Through Cache\n", t, func() {
+		memory := NewMemoryCacher(0, 0)
+		readThrough := NewReadThrough(memory)
+		var calls int32
+
+		Convey("Misses Should Be Loaded And Cached", func() {
+			loader := func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				return &TestValues{"A", 1, int64(1)}, nil
+			}
+			value, err := readThrough.GetOrLoad(ctx, "testKey", time.Minute, loader)
+			So(err, ShouldBeNil)
+			So(value, ShouldResemble, &TestValues{"A", 1, int64(1)})
+
+			value, err = readThrough.GetOrLoad(ctx, "testKey", time.Minute, loader)
+			So(err, ShouldBeNil)
+			So(value, ShouldResemble, &TestValues{"A", 1, int64(1)})
+			So(calls, ShouldEqual, 1)
+
+			ttl, _ := memory.TTL("testKey")
+			So(ttl, ShouldEqual, 60)
+		})
+
+		Convey("Concurrent Misses Should Be Loaded Once", func() {
+			loader := func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(50 * time.Millisecond)
+				return "value", nil
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					value, err := readThrough.GetOrLoad(ctx, "testKey", time.Minute, loader)
+					if err != nil || value != "value" {
+						t.Error("unexpected result", value, err)
+					}
+				}()
+			}
+			wg.Wait()
+			So(calls, ShouldEqual, 1)
+		})
+
+		Convey("Not Found Should Be Cached Negatively", func() {
+			readThrough.NegativeTTL = time.Minute
+			loader := func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				return nil, ErrNotFound
+			}
+			_, err := readThrough.GetOrLoad(ctx, "testKey", time.Minute, loader)
+			So(err, ShouldEqual, ErrNotFound)
+			_, err = readThrough.GetOrLoad(ctx, "testKey", time.Minute, loader)
+			So(err, ShouldEqual, ErrNotFound)
+			So(calls, ShouldEqual, 1)
+		})
+
+		Convey("Loader Errors Should Not Be Cached", func() {
+			loadErr := errors.New("load failed")
+			loader := func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				return nil, loadErr
+			}
+			_, err := readThrough.GetOrLoad(ctx, "testKey", time.Minute, loader)
+			So(err, ShouldEqual, loadErr)
+			_, err = readThrough.GetOrLoad(ctx, "testKey", time.Minute, loader)
+			So(err, ShouldEqual, loadErr)
+			So(calls, ShouldEqual, 2)
+		})
+
+		Convey("Early Refresh Should Reload Hot Keys Before They Expire", func() {
+			loader := func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(time.Millisecond)
+				return "value", nil
+			}
+			readThrough.GetOrLoad(ctx, "testKey", time.Minute, loader)
+			readThrough.Beta = 1e9
+			value, err := readThrough.GetOrLoad(ctx, "testKey", time.Minute, loader)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "value")
+			So(calls, ShouldEqual, 2)
+
+			readThrough.Beta = 1e-9
+			readThrough.GetOrLoad(ctx, "testKey", time.Minute, loader)
+			So(calls, ShouldEqual, 2)
+		})
+
+		Convey("Canceled Context Should Fail", func() {
+			canceledCtx, cancel := context.WithCancel(ctx)
+			cancel()
+			_, err := readThrough.GetOrLoad(canceledCtx, "testKey", time.Minute, func(ctx context.Context) (interface{}, error) {
+				return "value", nil
+			})
+			So(err, ShouldEqual, context.Canceled)
+		})
+
+		Convey("The Shared Load Should Survive The Cancellation Of The First Caller", func() {
+			started := make(chan struct{})
+			loader := func(ctx context.Context) (interface{}, error) {
+				close(started)
+				time.Sleep(50 * time.Millisecond)
+				return "value", ctx.Err()
+			}
+			firstCtx, cancel := context.WithCancel(ctx)
+			firstErr := make(chan error)
+			go func() {
+				_, err := readThrough.GetOrLoad(firstCtx, "testKey", time.Minute, loader)
+				firstErr <- err
+			}()
+			<-started
+			cancel()
+			So(<-firstErr, ShouldEqual, context.Canceled)
+
+			value, err := readThrough.GetOrLoad(ctx, "testKey", time.Minute, loader)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "value")
+		})
+
+		Convey("A Hanging Loader Should Be Bounded By LoadTimeout", func() {
+			readThrough.LoadTimeout = 50 * time.Millisecond
+			_, err := readThrough.GetOrLoad(ctx, "testKey", time.Minute, func(ctx context.Context) (interface{}, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			})
+			So(err, ShouldEqual, context.DeadlineExceeded)
+
+			value, err := readThrough.GetOrLoad(ctx, "testKey", time.Minute, func(ctx context.Context) (interface{}, error) {
+				return "value", nil
+			})
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "value")
+		})
+
+		Convey("A Panicking Loader Should Fail The Waiting Callers", func() {
+			release := make(chan struct{})
+			loader := func(ctx context.Context) (interface{}, error) {
+				<-release
+				panic("boom")
+			}
+			leaderPanic := make(chan interface{})
+			go func() {
+				defer func() { leaderPanic <- recover() }()
+				readThrough.GetOrLoad(ctx, "testKey", time.Minute, loader)
+			}()
+			time.Sleep(20 * time.Millisecond)
+			waiterErr := make(chan error)
+			go func() {
+				_, err := readThrough.GetOrLoad(ctx, "testKey", time.Minute, loader)
+				waiterErr <- err
+			}()
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			So(<-leaderPanic, ShouldEqual, "boom")
+			err := <-waiterErr
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "boom")
+		})
+
+		Convey("Values Without Expiration Should Not Be Refreshed Early", func() {
+			readThrough.Beta = 1e9
+			loader := func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				return "value", nil
+			}
+			readThrough.GetOrLoad(ctx, "testKey", 0, loader)
+			readThrough.GetOrLoad(ctx, "testKey", 0, loader)
+			So(calls, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,274 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cryptowrapper"
+	"github.com/garyburd/redigo/redis"
+)
+
+var (
+	// ErrLockNotObtained is returned when the lock is held by someone else.
+	ErrLockNotObtained = errors.New("cache: lock not obtained")
+	// ErrLockNotHeld is returned when releasing or extending a lock which has expired or is held by someone else.
+	ErrLockNotHeld = errors.New("cache: lock not held")
+)
+
+var (
+	unlockScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+	extendScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// LockOptions defines the options of a distributed lock.
+type LockOptions struct {
+	// TTL is the lease of the lock, defaults to 10 seconds.
+	TTL time.Duration
+	// RetryDelay is the initial delay between attempts of Lock, it doubles after each attempt up to MaxRetryDelay.
+	// They default to 50 milliseconds and 1 second.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// AutoRenew extends the lease every TTL/3 until the lock is released.
+	AutoRenew bool
+}
+
+func (options *LockOptions) setDefaults() {
+	if options.TTL <= 0 {
+		options.TTL = 10 * time.Second
+	}
+	if options.RetryDelay <= 0 {
+		options.RetryDelay = 50 * time.Millisecond
+	}
+	if options.MaxRetryDelay <= 0 {
+		options.MaxRetryDelay = time.Second
+	}
+}
+
+// Mutex is a distributed lock stored in redis.
+// The lock is acquired with SET NX PX and a random token, only the holder of the token can extend or release it.
+// With more than one redis instance it implements the Redlock algorithm and the lock is held when a majority acquired it.
+type Mutex struct {
+	name    string
+	cachers []*RedisCacher
+	quorum  int
+	options LockOptions
+
+	mu    sync.Mutex
+	token string
+	stop  chan struct{}
+	lost  chan struct{}
+}
+
+// NewMutex creates a distributed lock with given name on this redis.
+func (o *RedisCacher) NewMutex(name string, options LockOptions) *Mutex {
+	return NewRedlock(name, []*RedisCacher{o}, options)
+}
+
+// NewRedlock creates a distributed lock with given name on several independent redis instances.
+func NewRedlock(name string, cachers []*RedisCacher, options LockOptions) *Mutex {
+	options.setDefaults()
+	return &Mutex{
+		name:    name,
+		cachers: cachers,
+		quorum:  len(cachers)/2 + 1,
+		options: options,
+	}
+}
+
+// TryLock tries to acquire the lock once, it returns false if the lock is held by someone else.
+func (m *Mutex) TryLock(ctx context.Context) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.token != "" {
+		return false, ErrLockNotObtained
+	}
+
+	token := cryptowrapper.GenUUID()
+	start := time.Now()
+	acquired, err := m.each(ctx, func(ctx context.Context, cacher *RedisCacher) (bool, error) {
+		reply, err := cacher.DoContext(ctx, "SET", m.name, token, "NX", "PX", durationMilliseconds(m.options.TTL))
+		return reply != nil, err
+	})
+
+	// The lock is only valid if it's still within its lease after the time spent on acquiring it.
+	drift := m.options.TTL/100 + 2*time.Millisecond
+	if acquired >= m.quorum && time.Since(start)+drift < m.options.TTL {
+		m.token = token
+		m.lost = make(chan struct{})
+		if m.options.AutoRenew {
+			m.stop = make(chan struct{})
+			go m.renew(token, m.stop, m.lost)
+		}
+		return true, nil
+	}
+
+	// Release the instances we managed to lock.
+	m.each(context.Background(), func(ctx context.Context, cacher *RedisCacher) (bool, error) {
+		return m.release(ctx, cacher, token)
+	})
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return false, ctxErr
+	}
+	if acquired == 0 && err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// Lock acquires the lock, retrying with exponential backoff until the context is done.
+// It returns ErrLockNotObtained if the lock can't be acquired in time.
+func (m *Mutex) Lock(ctx context.Context) error {
+	delay := m.options.RetryDelay
+	for {
+		ok, err := m.TryLock(ctx)
+		if ok {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ErrLockNotObtained
+		}
+		if err != nil {
+			return err
+		}
+
+		// Add some jitter so that the contenders don't retry at the same time.
+		wait := delay/2 + time.Duration(rand.Int63n(int64(delay)/2+1))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ErrLockNotObtained
+		}
+		if delay *= 2; delay > m.options.MaxRetryDelay {
+			delay = m.options.MaxRetryDelay
+		}
+	}
+}
+
+// LockWithTimeout acquires the lock, giving up with ErrLockNotObtained after timeout.
+func (m *Mutex) LockWithTimeout(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return m.Lock(ctx)
+}
+
+// Extend resets the lease of the lock to ttl, it returns ErrLockNotHeld if the lock has been lost.
+func (m *Mutex) Extend(ctx context.Context, ttl time.Duration) error {
+	m.mu.Lock()
+	token := m.token
+	m.mu.Unlock()
+	if token == "" {
+		return ErrLockNotHeld
+	}
+	return m.extend(ctx, token, ttl)
+}
+
+func (m *Mutex) extend(ctx context.Context, token string, ttl time.Duration) error {
+	extended, err := m.each(ctx, func(ctx context.Context, cacher *RedisCacher) (bool, error) {
+		n, err := redis.Int(cacher.RunScript(ctx, extendScript, m.name, token, durationMilliseconds(ttl)))
+		return n == 1, err
+	})
+	if extended >= m.quorum {
+		return nil
+	}
+	if extended == 0 && err != nil {
+		return err
+	}
+	return ErrLockNotHeld
+}
+
+// Unlock releases the lock, it returns ErrLockNotHeld if the lock has expired before.
+func (m *Mutex) Unlock(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.token == "" {
+		return ErrLockNotHeld
+	}
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+	token := m.token
+	m.token = ""
+
+	released, err := m.each(ctx, func(ctx context.Context, cacher *RedisCacher) (bool, error) {
+		return m.release(ctx, cacher, token)
+	})
+	if released >= m.quorum {
+		return nil
+	}
+	if released == 0 && err != nil {
+		return err
+	}
+	return ErrLockNotHeld
+}
+
+// Lost returns a channel which is closed when the auto renewal finds that the lock has been lost.
+func (m *Mutex) Lost() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lost
+}
+
+func (m *Mutex) release(ctx context.Context, cacher *RedisCacher, token string) (bool, error) {
+	n, err := redis.Int(cacher.RunScript(ctx, unlockScript, m.name, token))
+	return n == 1, err
+}
+
+// renew extends the lease until stop is closed, lost is closed if the lock can't be extended anymore.
+func (m *Mutex) renew(token string, stop, lost chan struct{}) {
+	ticker := time.NewTicker(m.options.TTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), m.options.TTL/3)
+			err := m.extend(ctx, token, m.options.TTL)
+			cancel()
+			if err == ErrLockNotHeld {
+				close(lost)
+				return
+			}
+		}
+	}
+}
+
+// each runs fn on all the instances in parallel and returns how many of them succeeded with the last error.
+func (m *Mutex) each(ctx context.Context, fn func(ctx context.Context, cacher *RedisCacher) (bool, error)) (int, error) {
+	type result struct {
+		ok  bool
+		err error
+	}
+	results := make(chan result, len(m.cachers))
+	for _, cacher := range m.cachers {
+		go func(cacher *RedisCacher) {
+			ok, err := fn(ctx, cacher)
+			results <- result{ok, err}
+		}(cacher)
+	}
+
+	var succeeded int
+	var err error
+	for range m.cachers {
+		r := <-results
+		if r.ok {
+			succeeded++
+		}
+		if r.err != nil {
+			err = r.err
+		}
+	}
+	return succeeded, err
+}
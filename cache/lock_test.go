@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Test The Distributed Lock\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+
+		Convey("Only One Holder Should Get The Lock", func() {
+			mutex1 := cacher.NewMutex("testLock", LockOptions{TTL: time.Second})
+			mutex2 := cacher.NewMutex("testLock", LockOptions{TTL: time.Second})
+
+			ok, err := mutex1.TryLock(ctx)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			ok, err = mutex2.TryLock(ctx)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+
+			So(mutex2.LockWithTimeout(100*time.Millisecond), ShouldEqual, ErrLockNotObtained)
+			So(mutex2.Unlock(ctx), ShouldEqual, ErrLockNotHeld)
+
+			So(mutex1.Extend(ctx, 5*time.Second), ShouldBeNil)
+			ttl, _ := cacher.TTL("testLock")
+			So(ttl, ShouldBeBetween, 3, 6)
+
+			So(mutex1.Unlock(ctx), ShouldBeNil)
+			So(mutex1.Unlock(ctx), ShouldEqual, ErrLockNotHeld)
+			So(mutex2.LockWithTimeout(100*time.Millisecond), ShouldBeNil)
+			So(mutex2.Unlock(ctx), ShouldBeNil)
+		})
+
+		Convey("Waiting For The Lock Should Succeed After It's Released", func() {
+			mutex1 := cacher.NewMutex("testLock", LockOptions{TTL: time.Second})
+			mutex2 := cacher.NewMutex("testLock", LockOptions{TTL: time.Second, RetryDelay: 10 * time.Millisecond})
+			So(mutex1.Lock(ctx), ShouldBeNil)
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				mutex1.Unlock(ctx)
+			}()
+			So(mutex2.LockWithTimeout(time.Second), ShouldBeNil)
+			So(mutex2.Unlock(ctx), ShouldBeNil)
+		})
+
+		Convey("Auto Renewal Should Keep The Lock Alive", func() {
+			mutex1 := cacher.NewMutex("testLock", LockOptions{TTL: 300 * time.Millisecond, AutoRenew: true})
+			mutex2 := cacher.NewMutex("testLock", LockOptions{TTL: 300 * time.Millisecond})
+			So(mutex1.Lock(ctx), ShouldBeNil)
+			time.Sleep(700 * time.Millisecond)
+			ok, _ := mutex2.TryLock(ctx)
+			So(ok, ShouldBeFalse)
+
+			// Someone deletes the lock, the renewal should notice.
+			cacher.Del("testLock")
+			select {
+			case <-mutex1.Lost():
+			case <-time.After(time.Second):
+				t.Error("lost lock is not reported")
+			}
+			So(mutex1.Unlock(ctx), ShouldEqual, ErrLockNotHeld)
+		})
+
+		Convey("Redlock Should Need A Majority Of Instances", func() {
+			cacher3, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 3})
+			So(err, ShouldBeNil)
+			defer cacher3.ClosePool()
+			cacher4, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 4})
+			So(err, ShouldBeNil)
+			defer cacher4.ClosePool()
+			cachers := []*RedisCacher{cacher, cacher3, cacher4}
+
+			mutex1 := NewRedlock("testLock", cachers, LockOptions{TTL: time.Second})
+			mutex2 := NewRedlock("testLock", cachers, LockOptions{TTL: time.Second})
+
+			// One instance is taken by someone else, a majority is still available.
+			So(cacher4.Set("testLock", "someone"), ShouldBeNil)
+			ok, err := mutex1.TryLock(ctx)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			ok, _ = mutex2.TryLock(ctx)
+			So(ok, ShouldBeFalse)
+			So(mutex1.Unlock(ctx), ShouldBeNil)
+
+			// Two instances are taken, the lock can't be acquired and the acquired instance is released.
+			So(cacher3.Set("testLock", "someone"), ShouldBeNil)
+			ok, _ = mutex1.TryLock(ctx)
+			So(ok, ShouldBeFalse)
+			value, _ := cacher.Get("testLock")
+			So(value, ShouldBeNil)
+
+			cacher3.Del("testLock")
+			cacher4.Del("testLock")
+		})
+	})
+}
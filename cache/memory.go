@@ -0,0 +1,617 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cache/internal/glob"
+	"github.com/garyburd/redigo/redis"
+)
+
+var (
+	errWrongType   = redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errNotInteger  = redis.Error("ERR value is not an integer or out of range")
+	errSyntaxError = redis.Error("ERR syntax error")
+)
+
+// MemoryCacher is an in-memory implementation of cacher.
+// It keeps at most maxEntries keys and evicts the least recently used key when it's full,
+// expired keys are removed lazily when accessed and periodically by a background janitor.
+// Values are stored as bytes the same way redis stores them, so the results can be converted with the redis helpers.
+type MemoryCacher struct {
+	// Serializer is used by the typed API like SetValue and GetInto, defaults to JSONSerializer.
+	Serializer Serializer
+
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	hash      map[string][]byte
+	expiresAt time.Time
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// NewMemoryCacher creates a new in-memory cacher.
+// maxEntries <= 0 means there is no size bound, cleanupInterval <= 0 disables the background janitor.
+func NewMemoryCacher(maxEntries int, cleanupInterval time.Duration) *MemoryCacher {
+	o := &MemoryCacher{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+		stop:       make(chan struct{}),
+	}
+	if cleanupInterval > 0 {
+		go o.janitor(cleanupInterval)
+	}
+	return o
+}
+
+// Close stops the background janitor.
+func (o *MemoryCacher) Close() {
+	o.stopOnce.Do(func() {
+		close(o.stop)
+	})
+}
+
+func (o *MemoryCacher) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			o.DeleteExpired()
+		case <-o.stop:
+			return
+		}
+	}
+}
+
+// DeleteExpired removes all the expired keys.
+func (o *MemoryCacher) DeleteExpired() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := o.now()
+	for _, element := range o.items {
+		if element.Value.(*memoryEntry).expired(now) {
+			o.removeElement(element)
+		}
+	}
+}
+
+// lookup returns the live entry for key and marks it as recently used, the caller must hold the lock.
+func (o *MemoryCacher) lookup(key string) *memoryEntry {
+	element, ok := o.items[key]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if entry.expired(o.now()) {
+		o.removeElement(element)
+		return nil
+	}
+	o.ll.MoveToFront(element)
+	return entry
+}
+
+// store adds a new entry for key replacing the existing one, the caller must hold the lock.
+func (o *MemoryCacher) store(entry *memoryEntry) {
+	if element, ok := o.items[entry.key]; ok {
+		element.Value = entry
+		o.ll.MoveToFront(element)
+		return
+	}
+	o.items[entry.key] = o.ll.PushFront(entry)
+	if o.maxEntries > 0 && o.ll.Len() > o.maxEntries {
+		o.removeElement(o.ll.Back())
+	}
+}
+
+func (o *MemoryCacher) removeElement(element *list.Element) {
+	o.ll.Remove(element)
+	delete(o.items, element.Value.(*memoryEntry).key)
+}
+
+// expire sets the expiration of entry, a non-positive expiration deletes the key like redis does.
+func (o *MemoryCacher) expire(entry *memoryEntry, ttl time.Duration) {
+	if ttl <= 0 {
+		o.removeElement(o.items[entry.key])
+		return
+	}
+	entry.expiresAt = o.now().Add(ttl)
+}
+
+// setBytes sets key to value which expires after ttl.
+func (o *MemoryCacher) setBytes(key string, value []byte, ttl time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry := &memoryEntry{key: key, value: value}
+	o.store(entry)
+	o.expire(entry, ttl)
+}
+
+// hsetBytes sets a field of hash to value, a new hash expires after ttl and an existing one keeps its expiration.
+func (o *MemoryCacher) hsetBytes(hash, key string, value []byte, ttl time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry, err := o.hashEntry(hash, true)
+	if err != nil {
+		return
+	}
+	if entry.expiresAt.IsZero() {
+		o.expire(entry, ttl)
+	}
+	entry.hash[key] = value
+}
+
+// Set a key value pair, the value can be string, int64 and etc.
+func (o *MemoryCacher) Set(key string, value interface{}, expiration ...interface{}) error {
+	var seconds int64
+	var err error
+	if expiration != nil {
+		if seconds, err = argInt64(expiration[0]); err != nil {
+			return err
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry := &memoryEntry{key: key, value: argBytes(value)}
+	o.store(entry)
+	if expiration != nil {
+		o.expire(entry, time.Duration(seconds)*time.Second)
+	}
+	return nil
+}
+
+// Get a value from key, it returns nil if the key doesn't exist.
+func (o *MemoryCacher) Get(key string) (interface{}, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry := o.lookup(key)
+	if entry == nil {
+		return nil, nil
+	}
+	if entry.hash != nil {
+		return nil, errWrongType
+	}
+	return argBytes(entry.value), nil
+}
+
+// SetValue sets a key value pair, the value is encoded by the serializer.
+// A ttl <= 0 means the key doesn't expire.
+func (o *MemoryCacher) SetValue(key string, value interface{}, ttl time.Duration) error {
+	b, err := serializerOrDefault(o.Serializer).Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry := &memoryEntry{key: key, value: b}
+	o.store(entry)
+	if ttl > 0 {
+		o.expire(entry, ttl)
+	}
+	return nil
+}
+
+// GetInto gets a value set by SetValue and decodes it into dst, which must be a pointer.
+// It returns ErrCacheMiss if the key doesn't exist.
+func (o *MemoryCacher) GetInto(key string, dst interface{}) error {
+	b, err := o.GetBytes(key)
+	if err == redis.ErrNil {
+		return ErrCacheMiss
+	} else if err != nil {
+		return err
+	}
+	return serializerOrDefault(o.Serializer).Unmarshal(b, dst)
+}
+
+// GetString gets a string value from key
+func (o *MemoryCacher) GetString(key string) (string, error) {
+	return redis.String(o.Get(key))
+}
+
+// GetBytes gets a []byte value from key
+func (o *MemoryCacher) GetBytes(key string) ([]byte, error) {
+	return redis.Bytes(o.Get(key))
+}
+
+// GetInt64 gets a int64 value from key
+func (o *MemoryCacher) GetInt64(key string) (int64, error) {
+	return redis.Int64(o.Get(key))
+}
+
+// GetFloat64 gets a float64 value from key
+func (o *MemoryCacher) GetFloat64(key string) (float64, error) {
+	return redis.Float64(o.Get(key))
+}
+
+// Del a key
+func (o *MemoryCacher) Del(key string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if element, ok := o.items[key]; ok {
+		o.removeElement(element)
+	}
+}
+
+// Expire sets a expiration time for key
+func (o *MemoryCacher) Expire(key string, expiration int) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if entry := o.lookup(key); entry != nil {
+		o.expire(entry, time.Duration(expiration)*time.Second)
+	}
+	return nil
+}
+
+// TTL gets the remaining seconds for key, -2 if the key doesn't exist and -1 if the key has no expiration.
+func (o *MemoryCacher) TTL(key string) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry := o.lookup(key)
+	if entry == nil {
+		return -2, nil
+	}
+	if entry.expiresAt.IsZero() {
+		return -1, nil
+	}
+	remaining := entry.expiresAt.Sub(o.now())
+	return int((remaining + 500*time.Millisecond) / time.Second), nil
+}
+
+// Scan through the keys with given cursor, pattern and count.
+// Keys are visited in the order of their hashes and the cursor is the next hash to visit,
+// so a key that exists during the whole iteration is always returned, as with redis.
+func (o *MemoryCacher) Scan(cursor int, count int, pattern string) (nextCursor int, keys []string, err error) {
+	if count <= 0 || cursor < 0 {
+		return 0, nil, errSyntaxError
+	}
+
+	type hashedKey struct {
+		position int
+		key      string
+	}
+
+	o.mu.Lock()
+	now := o.now()
+	candidates := make([]hashedKey, 0, len(o.items))
+	for key, element := range o.items {
+		if element.Value.(*memoryEntry).expired(now) {
+			continue
+		}
+		position := scanPosition(key)
+		if position >= cursor {
+			candidates = append(candidates, hashedKey{position, key})
+		}
+	}
+	o.mu.Unlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].position == candidates[j].position {
+			return candidates[i].key < candidates[j].key
+		}
+		return candidates[i].position < candidates[j].position
+	})
+
+	keys = []string{}
+	for i, candidate := range candidates {
+		// Never split keys sharing the same position between two pages.
+		if i >= count && candidate.position != candidates[i-1].position {
+			return candidate.position, keys, nil
+		}
+		if matchPattern(pattern, candidate.key) {
+			keys = append(keys, candidate.key)
+		}
+	}
+	return 0, keys, nil
+}
+
+// scanPosition maps a key to a positive scan cursor.
+func scanPosition(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32()) + 1
+}
+
+// SetGob sets a key value pair, value will be gob encoded
+func (o *MemoryCacher) SetGob(key string, value interface{}, expiration ...interface{}) error {
+	b, err := encodeGob(value)
+	if err == nil {
+		err = o.Set(key, b, expiration...)
+	}
+	return err
+}
+
+// GetGob gets a gob encoded value from key
+func (o *MemoryCacher) GetGob(key string) (interface{}, error) {
+	b, err := o.GetBytes(key)
+	if err != nil {
+		return nil, err
+	}
+	return decodeGob(b)
+}
+
+// SetJSON sets a key value pair, the value is a json
+func (o *MemoryCacher) SetJSON(key string, value interface{}, expiration ...interface{}) error {
+	str, err := json.Marshal(value)
+	if err == nil {
+		err = o.Set(key, str, expiration...)
+	}
+	return err
+}
+
+// GetJSON gets a json value from key
+func (o *MemoryCacher) GetJSON(key string) (jsonBytes []byte, err error) {
+	return o.GetBytes(key)
+}
+
+// hashEntry returns the hash stored at key, creating it if create is true, the caller must hold the lock.
+func (o *MemoryCacher) hashEntry(hash string, create bool) (*memoryEntry, error) {
+	entry := o.lookup(hash)
+	if entry == nil {
+		if !create {
+			return nil, nil
+		}
+		entry = &memoryEntry{key: hash, hash: make(map[string][]byte)}
+		o.store(entry)
+	}
+	if entry.hash == nil {
+		return nil, errWrongType
+	}
+	return entry, nil
+}
+
+// HSet sets a key:value in hash set, the expiration applies to the whole hash.
+func (o *MemoryCacher) HSet(hash, key string, value interface{}, expiration ...interface{}) error {
+	var seconds int64
+	var err error
+	if expiration != nil {
+		if seconds, err = argInt64(expiration[0]); err != nil {
+			return err
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry, err := o.hashEntry(hash, true)
+	if err != nil {
+		return err
+	}
+	entry.hash[key] = argBytes(value)
+	if expiration != nil {
+		o.expire(entry, time.Duration(seconds)*time.Second)
+	}
+	return nil
+}
+
+// HGet gets a value from hash set
+func (o *MemoryCacher) HGet(hash, key string) (interface{}, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry, err := o.hashEntry(hash, false)
+	if entry == nil || err != nil {
+		return nil, err
+	}
+	value, ok := entry.hash[key]
+	if !ok {
+		return nil, nil
+	}
+	return argBytes(value), nil
+}
+
+// HGetAll gets all key:values for give hash, in the same flattened form redis returns.
+func (o *MemoryCacher) HGetAll(hash string) (interface{}, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry, err := o.hashEntry(hash, false)
+	if err != nil {
+		return nil, err
+	}
+	values := []interface{}{}
+	if entry != nil {
+		for key, value := range entry.hash {
+			values = append(values, []byte(key), argBytes(value))
+		}
+	}
+	return values, nil
+}
+
+// HDel deletes a given key in a hash
+func (o *MemoryCacher) HDel(hash, key string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry, err := o.hashEntry(hash, false)
+	if entry == nil || err != nil {
+		return err
+	}
+	delete(entry.hash, key)
+	if len(entry.hash) == 0 {
+		o.removeElement(o.items[hash])
+	}
+	return nil
+}
+
+// HINCRBY increments a value for hash set by key.
+func (o *MemoryCacher) HINCRBY(hash, key string, value interface{}) error {
+	increment, err := argInt64(value)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry, err := o.hashEntry(hash, true)
+	if err != nil {
+		return err
+	}
+	var current int64
+	if b, ok := entry.hash[key]; ok {
+		if current, err = strconv.ParseInt(string(b), 10, 64); err != nil {
+			return redis.Error("ERR hash value is not an integer")
+		}
+	}
+	entry.hash[key] = strconv.AppendInt(nil, current+increment, 10)
+	return nil
+}
+
+// Flush removes all keys.
+func (o *MemoryCacher) Flush() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.ll.Init()
+	o.items = make(map[string]*list.Element)
+	return nil
+}
+
+// Len gets the number of keys, including the expired keys that haven't been removed yet.
+func (o *MemoryCacher) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.ll.Len()
+}
+
+// matchPattern reports whether str matches the glob-style pattern used by the redis KEYS and SCAN commands.
+func matchPattern(pattern, str string) bool {
+	return glob.Match(pattern, str)
+}
+
+// SetContext sets a key value pair with context.
+func (o *MemoryCacher) SetContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return o.Set(key, value, expiration...)
+}
+
+// GetContext gets a value from key with context.
+func (o *MemoryCacher) GetContext(ctx context.Context, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return o.Get(key)
+}
+
+// DelContext deletes a key with context.
+func (o *MemoryCacher) DelContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	o.Del(key)
+	return nil
+}
+
+// ScanContext scans through the keys with given cursor, pattern and count with context.
+func (o *MemoryCacher) ScanContext(ctx context.Context, cursor int, count int, pattern string) (nextCursor int, keys []string, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	return o.Scan(cursor, count, pattern)
+}
+
+// ExpireContext sets a expiration time for key with context.
+func (o *MemoryCacher) ExpireContext(ctx context.Context, key string, expiration int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return o.Expire(key, expiration)
+}
+
+// TTLContext gets the remaining seconds for key with context.
+func (o *MemoryCacher) TTLContext(ctx context.Context, key string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return o.TTL(key)
+}
+
+// SetGobContext sets a key value pair with context, value will be gob encoded
+func (o *MemoryCacher) SetGobContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return o.SetGob(key, value, expiration...)
+}
+
+// GetGobContext gets a gob encoded value from key with context.
+func (o *MemoryCacher) GetGobContext(ctx context.Context, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return o.GetGob(key)
+}
+
+// SetJSONContext sets a key value pair with context, the value is a json
+func (o *MemoryCacher) SetJSONContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return o.SetJSON(key, value, expiration...)
+}
+
+// GetJSONContext gets a json value from key with context.
+func (o *MemoryCacher) GetJSONContext(ctx context.Context, key string) (jsonBytes []byte, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	return o.GetJSON(key)
+}
+
+// HSetContext sets a key:value in hash set with context.
+func (o *MemoryCacher) HSetContext(ctx context.Context, hash, key string, value interface{}, expiration ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return o.HSet(hash, key, value, expiration...)
+}
+
+// HGetContext gets a value from hash set with context.
+func (o *MemoryCacher) HGetContext(ctx context.Context, hash, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return o.HGet(hash, key)
+}
+
+// SetValueContext sets a key value pair with context, the value is encoded by the serializer.
+func (o *MemoryCacher) SetValueContext(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return o.SetValue(key, value, ttl)
+}
+
+// GetIntoContext gets a value set by SetValue with context and decodes it into dst.
+func (o *MemoryCacher) GetIntoContext(ctx context.Context, key string, dst interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return o.GetInto(key, dst)
+}
+
+// HINCRBYContext increments a value for hash set by key with context.
+func (o *MemoryCacher) HINCRBYContext(ctx context.Context, hash, key string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return o.HINCRBY(hash, key, value)
+}
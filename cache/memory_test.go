@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMemory(t *testing.T) {
+	GobRegister(&TestValues{})
+
+	Convey("Test The Memory Cacher\n", t, func() {
+		memory := NewMemoryCacher(3, 0)
+		defer memory.Close()
+		now := time.Now()
+		memory.now = func() time.Time { return now }
+
+		Convey("Set And Get Values Should Be OK", func() {
+			So(memory.Set("testKey1", "testValue"), ShouldBeNil)
+			So(memory.Set("testKey2", 1), ShouldBeNil)
+			So(memory.Set("testKey3", 1.5), ShouldBeNil)
+
+			value1, err := memory.GetString("testKey1")
+			So(err, ShouldBeNil)
+			So(value1, ShouldEqual, "testValue")
+			value2, err := memory.GetInt64("testKey2")
+			So(err, ShouldBeNil)
+			So(value2, ShouldEqual, int64(1))
+			value3, err := memory.GetFloat64("testKey3")
+			So(err, ShouldBeNil)
+			So(value3, ShouldEqual, float64(1.5))
+
+			value, err := memory.Get("notExist")
+			So(err, ShouldBeNil)
+			So(value, ShouldBeNil)
+			_, err = memory.GetString("notExist")
+			So(err, ShouldEqual, redis.ErrNil)
+
+			memory.Del("testKey1")
+			value, err = memory.Get("testKey1")
+			So(err, ShouldBeNil)
+			So(value, ShouldBeNil)
+		})
+
+		Convey("Gob And JSON Values Should Be OK", func() {
+			So(memory.SetGob("testKey4", &TestValues{"A", 1, int64(1)}), ShouldBeNil)
+			value, err := memory.GetGob("testKey4")
+			So(err, ShouldBeNil)
+			testValues, ok := value.(*TestValues)
+			So(ok, ShouldBeTrue)
+			So(*testValues, ShouldResemble, TestValues{"A", 1, int64(1)})
+
+			So(memory.SetJSON("testKey5", &TestValues{"A", 1, int64(1)}), ShouldBeNil)
+			jsonBytes, err := memory.GetJSON("testKey5")
+			So(err, ShouldBeNil)
+			So(string(jsonBytes), ShouldEqual, `{"A":"A","B":1,"C":1}`)
+
+			_, err = memory.GetGob("notExist")
+			So(err, ShouldEqual, redis.ErrNil)
+		})
+
+		Convey("Expiration Should Be OK", func() {
+			So(memory.Set("testKey1", "testValue", 10), ShouldBeNil)
+			ttl, err := memory.TTL("testKey1")
+			So(err, ShouldBeNil)
+			So(ttl, ShouldEqual, 10)
+
+			So(memory.Set("testKey2", "testValue"), ShouldBeNil)
+			ttl, _ = memory.TTL("testKey2")
+			So(ttl, ShouldEqual, -1)
+			ttl, _ = memory.TTL("notExist")
+			So(ttl, ShouldEqual, -2)
+
+			So(memory.Expire("testKey2", 20), ShouldBeNil)
+			ttl, _ = memory.TTL("testKey2")
+			So(ttl, ShouldEqual, 20)
+
+			now = now.Add(10 * time.Second)
+			value, _ := memory.Get("testKey1")
+			So(value, ShouldBeNil)
+			ttl, _ = memory.TTL("testKey2")
+			So(ttl, ShouldEqual, 10)
+
+			now = now.Add(10 * time.Second)
+			memory.DeleteExpired()
+			So(memory.Len(), ShouldEqual, 0)
+
+			So(memory.Set("testKey3", "testValue", "abc"), ShouldNotBeNil)
+		})
+
+		Convey("Least Recently Used Keys Should Be Evicted", func() {
+			memory.Set("testKey1", 1)
+			memory.Set("testKey2", 2)
+			memory.Set("testKey3", 3)
+			memory.Get("testKey1")
+			memory.Set("testKey4", 4)
+
+			So(memory.Len(), ShouldEqual, 3)
+			value, _ := memory.Get("testKey2")
+			So(value, ShouldBeNil)
+			value, _ = memory.Get("testKey1")
+			So(value, ShouldNotBeNil)
+		})
+
+		Convey("Hash Set Should Be OK", func() {
+			So(memory.HSet("hash1", "key1", 1), ShouldBeNil)
+			So(memory.HINCRBY("hash1", "key1", 2), ShouldBeNil)
+			value, err := redis.Int64(memory.HGet("hash1", "key1"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, int64(3))
+
+			values, err := redis.StringMap(memory.HGetAll("hash1"))
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, map[string]string{"key1": "3"})
+
+			So(memory.HSet("hash1", "key2", "a", 10), ShouldBeNil)
+			ttl, _ := memory.TTL("hash1")
+			So(ttl, ShouldEqual, 10)
+			So(memory.HINCRBY("hash1", "key2", 1), ShouldNotBeNil)
+
+			So(memory.HDel("hash1", "key1"), ShouldBeNil)
+			hashValue, err := memory.HGet("hash1", "key1")
+			So(err, ShouldBeNil)
+			So(hashValue, ShouldBeNil)
+
+			_, err = memory.Get("hash1")
+			So(err, ShouldEqual, errWrongType)
+			memory.Set("testKey1", 1)
+			So(memory.HSet("testKey1", "key1", 1), ShouldEqual, errWrongType)
+		})
+
+		Convey("Canceled Context Should Fail", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			So(memory.SetContext(ctx, "testKey1", "testValue"), ShouldBeNil)
+			cancel()
+			_, err := memory.GetContext(ctx, "testKey1")
+			So(err, ShouldEqual, context.Canceled)
+			So(memory.SetContext(ctx, "testKey1", "testValue"), ShouldEqual, context.Canceled)
+		})
+
+		Convey("Scanning The Keys Should Be OK", func() {
+			memory = NewMemoryCacher(0, time.Millisecond)
+			defer memory.Close()
+			for _, key := range []string{"testKey1", "testKey2", "testKey3", "testKey4", "testKey5", "other"} {
+				memory.Set(key, 1)
+			}
+
+			cursor, keys, err := memory.Scan(0, 2, "testKey*")
+			So(err, ShouldBeNil)
+			allKeys := keys
+			for cursor != 0 {
+				cursor, keys, err = memory.Scan(cursor, 2, "testKey*")
+				So(err, ShouldBeNil)
+				allKeys = append(allKeys, keys...)
+			}
+			sort.Strings(allKeys)
+			So(allKeys, ShouldResemble, []string{"testKey1", "testKey2", "testKey3", "testKey4", "testKey5"})
+
+			_, keys, err = memory.Scan(0, 100, "testKey[1-2]")
+			So(err, ShouldBeNil)
+			sort.Strings(keys)
+			So(keys, ShouldResemble, []string{"testKey1", "testKey2"})
+
+			_, _, err = memory.Scan(0, -100, "testKey*")
+			So(err, ShouldNotBeNil)
+
+			memory.Set("testKey6", 1, 1)
+			time.Sleep(1100 * time.Millisecond)
+			So(memory.Len(), ShouldEqual, 6)
+			So(memory.Flush(), ShouldBeNil)
+			So(memory.Len(), ShouldEqual, 0)
+		})
+	})
+
+	Convey("Test The Glob Pattern Matching\n", t, func() {
+		So(matchPattern("*", "anything"), ShouldBeTrue)
+		So(matchPattern("h?llo", "hello"), ShouldBeTrue)
+		So(matchPattern("h?llo", "hllo"), ShouldBeFalse)
+		So(matchPattern("h*llo", "heeeello"), ShouldBeTrue)
+		So(matchPattern("h[ae]llo", "hallo"), ShouldBeTrue)
+		So(matchPattern("h[ae]llo", "hillo"), ShouldBeFalse)
+		So(matchPattern("h[^e]llo", "hallo"), ShouldBeTrue)
+		So(matchPattern("h[^e]llo", "hello"), ShouldBeFalse)
+		So(matchPattern("h[a-b]llo", "hbllo"), ShouldBeTrue)
+		So(matchPattern(`h\*llo`, "h*llo"), ShouldBeTrue)
+		So(matchPattern(`h\*llo`, "hello"), ShouldBeFalse)
+		So(matchPattern("user:*:name", "user:1/2:name"), ShouldBeTrue)
+	})
+}
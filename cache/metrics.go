@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the duration histograms of Metrics.
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// commandMetrics are the metrics of a command.
+type commandMetrics struct {
+	count   uint64
+	errors  uint64
+	hits    uint64
+	misses  uint64
+	buckets []uint64
+	sum     float64
+}
+
+// compressionMetrics are the metrics of a compression algorithm.
+type compressionMetrics struct {
+	values     uint64
+	size       uint64
+	storedSize uint64
+}
+
+// Metrics is a Hook which counts the commands, their errors, the hits and misses of the reads,
+// and keeps a histogram of their durations.
+// It also counts the bytes before and after compression, their ratio is the compression ratio.
+// It's exposed in the Prometheus text format by WriteTo and ServeHTTP.
+type Metrics struct {
+	buckets []float64
+
+	mu          sync.Mutex
+	commands    map[string]*commandMetrics
+	compression map[Compression]*compressionMetrics
+}
+
+// NewMetrics creates the metrics with the given histogram buckets in seconds, DefaultBuckets if none.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{
+		buckets:     buckets,
+		commands:    make(map[string]*commandMetrics),
+		compression: make(map[Compression]*compressionMetrics),
+	}
+}
+
+// BeforeCommand implements Hook.
+func (m *Metrics) BeforeCommand(ctx context.Context, cmd *CommandInfo) context.Context {
+	return ctx
+}
+
+// AfterCommand implements Hook.
+func (m *Metrics) AfterCommand(ctx context.Context, cmd *CommandInfo) {
+	seconds := cmd.Duration.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	metrics, ok := m.commands[cmd.Name]
+	if !ok {
+		metrics = &commandMetrics{buckets: make([]uint64, len(m.buckets))}
+		m.commands[cmd.Name] = metrics
+	}
+	metrics.count++
+	metrics.sum += seconds
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			metrics.buckets[i]++
+		}
+	}
+	if cmd.Err != nil {
+		metrics.errors++
+	} else if cmd.Read {
+		if cmd.Hit {
+			metrics.hits++
+		} else {
+			metrics.misses++
+		}
+	}
+}
+
+// AfterCompress implements CompressionHook.
+func (m *Metrics) AfterCompress(ctx context.Context, info *CompressionInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	metrics, ok := m.compression[info.Algorithm]
+	if !ok {
+		metrics = &compressionMetrics{}
+		m.compression[info.Algorithm] = metrics
+	}
+	metrics.values++
+	metrics.size += uint64(info.Size)
+	metrics.storedSize += uint64(info.StoredSize)
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	m.mu.Lock()
+	names := make([]string, 0, len(m.commands))
+	for name := range m.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	counter := func(metric, help string, value func(*commandMetrics) uint64) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s counter\n", metric, help, metric)
+		for _, name := range names {
+			fmt.Fprintf(&buf, "%s{command=%q} %d\n", metric, name, value(m.commands[name]))
+		}
+	}
+	counter("cache_commands_total", "Number of commands sent to redis.", func(c *commandMetrics) uint64 { return c.count })
+	counter("cache_command_errors_total", "Number of commands which failed.", func(c *commandMetrics) uint64 { return c.errors })
+	counter("cache_hits_total", "Number of reads which found a value.", func(c *commandMetrics) uint64 { return c.hits })
+	counter("cache_misses_total", "Number of reads which found no value.", func(c *commandMetrics) uint64 { return c.misses })
+
+	metric := "cache_command_duration_seconds"
+	fmt.Fprintf(&buf, "# HELP %s Duration of the commands.\n# TYPE %s histogram\n", metric, metric)
+	for _, name := range names {
+		c := m.commands[name]
+		for i, bound := range m.buckets {
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			fmt.Fprintf(&buf, "%s_bucket{command=%q,le=%q} %d\n", metric, name, le, c.buckets[i])
+		}
+		fmt.Fprintf(&buf, "%s_bucket{command=%q,le=\"+Inf\"} %d\n", metric, name, c.count)
+		fmt.Fprintf(&buf, "%s_sum{command=%q} %s\n", metric, name, strconv.FormatFloat(c.sum, 'g', -1, 64))
+		fmt.Fprintf(&buf, "%s_count{command=%q} %d\n", metric, name, c.count)
+	}
+
+	if len(m.compression) > 0 {
+		algorithms := make([]Compression, 0, len(m.compression))
+		for algorithm := range m.compression {
+			algorithms = append(algorithms, algorithm)
+		}
+		sort.Slice(algorithms, func(i, j int) bool { return algorithms[i] < algorithms[j] })
+
+		compressionCounter := func(metric, help string, value func(*compressionMetrics) uint64) {
+			fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s counter\n", metric, help, metric)
+			for _, algorithm := range algorithms {
+				fmt.Fprintf(&buf, "%s{algorithm=%q} %d\n", metric, algorithm, value(m.compression[algorithm]))
+			}
+		}
+		compressionCounter("cache_compressed_values_total", "Number of values compressed.", func(c *compressionMetrics) uint64 { return c.values })
+		compressionCounter("cache_compression_input_bytes_total", "Size of the values before compression.", func(c *compressionMetrics) uint64 { return c.size })
+		compressionCounter("cache_compression_output_bytes_total", "Size of the values stored after compression.", func(c *compressionMetrics) uint64 { return c.storedSize })
+	}
+	m.mu.Unlock()
+
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP serves the metrics so that they can be scraped by Prometheus.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
@@ -0,0 +1,412 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+var errNotVersioned = errors.New("cache: the namespace isn't versioned")
+
+// NamespaceOptions defines the options of a namespace.
+type NamespaceOptions struct {
+	// Versioned adds the generation of the namespace to the prefix of the keys,
+	// so that Invalidate drops every key of the namespace at once without scanning.
+	// The keys of the previous generations are no longer reachable and are left to expire.
+	Versioned bool
+	// GenerationsHash is the hash storing the generation of every namespace, defaults to "namespace:generations".
+	// It must not expire or be evicted, otherwise the generations start over.
+	GenerationsHash string
+	// RefreshInterval is how long the generation is cached locally, 0 reads it before every command.
+	// A generation bumped by another process is seen at most RefreshInterval later.
+	RefreshInterval time.Duration
+}
+
+func (options *NamespaceOptions) setDefaults() {
+	if options.GenerationsHash == "" {
+		options.GenerationsHash = "namespace:generations"
+	}
+}
+
+// Namespace is a view of a cacher which prefixes every key with "<name>:", or "<name>:<generation>:" if versioned.
+// Scan matches the pattern within the namespace and returns the keys without the prefix.
+type Namespace struct {
+	cacher  ContextCacher
+	name    string
+	options NamespaceOptions
+
+	mu         sync.Mutex
+	generation int64
+	fetched    time.Time
+}
+
+var (
+	_ Cacher        = (*Namespace)(nil)
+	_ ContextCacher = (*Namespace)(nil)
+)
+
+// NewNamespace creates a namespace named name over cacher.
+func NewNamespace(cacher Cacher, name string, options NamespaceOptions) *Namespace {
+	options.setDefaults()
+	contextCacher, ok := cacher.(ContextCacher)
+	if !ok {
+		contextCacher = noContextCacher{cacher}
+	}
+	return &Namespace{cacher: contextCacher, name: name, options: options}
+}
+
+// prefix gets the prefix of the keys of the current generation.
+func (n *Namespace) prefix(ctx context.Context) (string, error) {
+	if !n.options.Versioned {
+		return n.name + ":", nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.fetched.IsZero() || time.Since(n.fetched) >= n.options.RefreshInterval {
+		reply, err := n.cacher.HGetContext(ctx, n.options.GenerationsHash, n.name)
+		if err != nil {
+			return "", err
+		}
+		generation := int64(0)
+		if reply != nil {
+			if generation, err = redis.Int64(reply, nil); err != nil {
+				return "", err
+			}
+		}
+		n.generation = generation
+		n.fetched = time.Now()
+	}
+	return n.name + ":" + strconv.FormatInt(n.generation, 10) + ":", nil
+}
+
+// Key gets the key key is stored at, e.g. to use it with commands the namespace doesn't wrap.
+func (n *Namespace) Key(key string) (string, error) {
+	return n.KeyContext(context.Background(), key)
+}
+
+// KeyContext gets the key key is stored at with context.
+func (n *Namespace) KeyContext(ctx context.Context, key string) (string, error) {
+	prefix, err := n.prefix(ctx)
+	return prefix + key, err
+}
+
+// Invalidate bumps the generation of a versioned namespace, so that all its keys are dropped.
+func (n *Namespace) Invalidate() error {
+	return n.InvalidateContext(context.Background())
+}
+
+// InvalidateContext bumps the generation of a versioned namespace with context.
+func (n *Namespace) InvalidateContext(ctx context.Context) error {
+	if !n.options.Versioned {
+		return errNotVersioned
+	}
+	if err := n.cacher.HINCRBYContext(ctx, n.options.GenerationsHash, n.name, 1); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.fetched = time.Time{}
+	n.mu.Unlock()
+	return nil
+}
+
+// Set sets a key value pair.
+func (n *Namespace) Set(key string, value interface{}, expiration ...interface{}) error {
+	return n.SetContext(context.Background(), key, value, expiration...)
+}
+
+// SetContext sets a key value pair with context.
+func (n *Namespace) SetContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	key, err := n.KeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	return n.cacher.SetContext(ctx, key, value, expiration...)
+}
+
+// Get gets a value from key.
+func (n *Namespace) Get(key string) (interface{}, error) {
+	return n.GetContext(context.Background(), key)
+}
+
+// GetContext gets a value from key with context.
+func (n *Namespace) GetContext(ctx context.Context, key string) (interface{}, error) {
+	key, err := n.KeyContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return n.cacher.GetContext(ctx, key)
+}
+
+// Del deletes a key.
+func (n *Namespace) Del(key string) {
+	n.DelContext(context.Background(), key)
+}
+
+// DelContext deletes a key with context.
+func (n *Namespace) DelContext(ctx context.Context, key string) error {
+	key, err := n.KeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	return n.cacher.DelContext(ctx, key)
+}
+
+// Scan through the keys of the namespace with given cursor, pattern and count.
+func (n *Namespace) Scan(cursor int, count int, pattern string) (nextCursor int, keys []string, err error) {
+	return n.ScanContext(context.Background(), cursor, count, pattern)
+}
+
+// ScanContext scans through the keys of the namespace with context.
+func (n *Namespace) ScanContext(ctx context.Context, cursor int, count int, pattern string) (nextCursor int, keys []string, err error) {
+	prefix, err := n.prefix(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	nextCursor, keys, err = n.cacher.ScanContext(ctx, cursor, count, escapePattern(prefix)+pattern)
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, prefix)
+	}
+	return nextCursor, keys, err
+}
+
+// Expire sets a expiration time for key.
+func (n *Namespace) Expire(key string, expiration int) error {
+	return n.ExpireContext(context.Background(), key, expiration)
+}
+
+// ExpireContext sets a expiration time for key with context.
+func (n *Namespace) ExpireContext(ctx context.Context, key string, expiration int) error {
+	key, err := n.KeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	return n.cacher.ExpireContext(ctx, key, expiration)
+}
+
+// TTL gets the remaining seconds for key.
+func (n *Namespace) TTL(key string) (int, error) {
+	return n.TTLContext(context.Background(), key)
+}
+
+// TTLContext gets the remaining seconds for key with context.
+func (n *Namespace) TTLContext(ctx context.Context, key string) (int, error) {
+	key, err := n.KeyContext(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return n.cacher.TTLContext(ctx, key)
+}
+
+// SetGob sets a key value pair, value will be gob encoded.
+func (n *Namespace) SetGob(key string, value interface{}, expiration ...interface{}) error {
+	return n.SetGobContext(context.Background(), key, value, expiration...)
+}
+
+// SetGobContext sets a key value pair with context, value will be gob encoded.
+func (n *Namespace) SetGobContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	key, err := n.KeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	return n.cacher.SetGobContext(ctx, key, value, expiration...)
+}
+
+// GetGob gets a gob encoded value from key.
+func (n *Namespace) GetGob(key string) (interface{}, error) {
+	return n.GetGobContext(context.Background(), key)
+}
+
+// GetGobContext gets a gob encoded value from key with context.
+func (n *Namespace) GetGobContext(ctx context.Context, key string) (interface{}, error) {
+	key, err := n.KeyContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return n.cacher.GetGobContext(ctx, key)
+}
+
+// SetJSON sets a key value pair, value will be json encoded.
+func (n *Namespace) SetJSON(key string, value interface{}, expiration ...interface{}) error {
+	return n.SetJSONContext(context.Background(), key, value, expiration...)
+}
+
+// SetJSONContext sets a key value pair with context, value will be json encoded.
+func (n *Namespace) SetJSONContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	key, err := n.KeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	return n.cacher.SetJSONContext(ctx, key, value, expiration...)
+}
+
+// GetJSON gets the json bytes from key.
+func (n *Namespace) GetJSON(key string) (jsonBytes []byte, err error) {
+	return n.GetJSONContext(context.Background(), key)
+}
+
+// GetJSONContext gets the json bytes from key with context.
+func (n *Namespace) GetJSONContext(ctx context.Context, key string) (jsonBytes []byte, err error) {
+	key, err = n.KeyContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return n.cacher.GetJSONContext(ctx, key)
+}
+
+// HSet sets a value for hash set by key.
+func (n *Namespace) HSet(hash, key string, value interface{}, expiration ...interface{}) error {
+	return n.HSetContext(context.Background(), hash, key, value, expiration...)
+}
+
+// HSetContext sets a value for hash set by key with context.
+func (n *Namespace) HSetContext(ctx context.Context, hash, key string, value interface{}, expiration ...interface{}) error {
+	hash, err := n.KeyContext(ctx, hash)
+	if err != nil {
+		return err
+	}
+	return n.cacher.HSetContext(ctx, hash, key, value, expiration...)
+}
+
+// HGet gets a value for hash set by key.
+func (n *Namespace) HGet(hash, key string) (interface{}, error) {
+	return n.HGetContext(context.Background(), hash, key)
+}
+
+// HGetContext gets a value for hash set by key with context.
+func (n *Namespace) HGetContext(ctx context.Context, hash, key string) (interface{}, error) {
+	hash, err := n.KeyContext(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return n.cacher.HGetContext(ctx, hash, key)
+}
+
+// HINCRBY increments a value for hash set by key.
+func (n *Namespace) HINCRBY(hash, key string, value interface{}) error {
+	return n.HINCRBYContext(context.Background(), hash, key, value)
+}
+
+// HINCRBYContext increments a value for hash set by key with context.
+func (n *Namespace) HINCRBYContext(ctx context.Context, hash, key string, value interface{}) error {
+	hash, err := n.KeyContext(ctx, hash)
+	if err != nil {
+		return err
+	}
+	return n.cacher.HINCRBYContext(ctx, hash, key, value)
+}
+
+// escapePattern escapes the glob characters of s so that it matches itself in a pattern.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// noContextCacher makes a Cacher usable as a ContextCacher, the context is only checked before each command.
+type noContextCacher struct {
+	Cacher
+}
+
+func (c noContextCacher) SetContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Set(key, value, expiration...)
+}
+
+func (c noContextCacher) GetContext(ctx context.Context, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Get(key)
+}
+
+func (c noContextCacher) DelContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.Del(key)
+	return nil
+}
+
+func (c noContextCacher) ScanContext(ctx context.Context, cursor int, count int, pattern string) (int, []string, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+	return c.Scan(cursor, count, pattern)
+}
+
+func (c noContextCacher) ExpireContext(ctx context.Context, key string, expiration int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Expire(key, expiration)
+}
+
+func (c noContextCacher) TTLContext(ctx context.Context, key string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.TTL(key)
+}
+
+func (c noContextCacher) SetGobContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.SetGob(key, value, expiration...)
+}
+
+func (c noContextCacher) GetGobContext(ctx context.Context, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.GetGob(key)
+}
+
+func (c noContextCacher) SetJSONContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.SetJSON(key, value, expiration...)
+}
+
+func (c noContextCacher) GetJSONContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.GetJSON(key)
+}
+
+func (c noContextCacher) HSetContext(ctx context.Context, hash, key string, value interface{}, expiration ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.HSet(hash, key, value, expiration...)
+}
+
+func (c noContextCacher) HGetContext(ctx context.Context, hash, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.HGet(hash, key)
+}
+
+func (c noContextCacher) HINCRBYContext(ctx context.Context, hash, key string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.HINCRBY(hash, key, value)
+}
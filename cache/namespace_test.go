@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// scanAll scans all the keys matching pattern.
+func scanAll(cacher Cacher, pattern string) []string {
+	var keys []string
+	cursor := 0
+	for {
+		var batch []string
+		var err error
+		cursor, batch, err = cacher.Scan(cursor, 100, pattern)
+		So(err, ShouldBeNil)
+		keys = append(keys, batch...)
+		if cursor == 0 {
+			break
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestNamespace(t *testing.T) {
+	Convey("Test Namespaces\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		Convey("Keys Should Be Prefixed With The Namespace", func() {
+			users := NewNamespace(cacher, "users", NamespaceOptions{})
+			So(users.Set("1", "alice"), ShouldBeNil)
+			So(users.HSet("profile", "name", "alice"), ShouldBeNil)
+
+			value, err := redis.String(cacher.Get("users:1"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "alice")
+			value, err = redis.String(users.Get("1"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "alice")
+			value, err = redis.String(users.HGet("profile", "name"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "alice")
+
+			users.Del("1")
+			reply, err := cacher.Get("users:1")
+			So(err, ShouldBeNil)
+			So(reply, ShouldBeNil)
+			So(users.Invalidate(), ShouldEqual, errNotVersioned)
+		})
+
+		Convey("Scan Should Stay Within The Namespace", func() {
+			glob := NewNamespace(cacher, "a*[b]", NamespaceOptions{})
+			So(glob.Set("key1", 1), ShouldBeNil)
+			So(glob.Set("key2", 2), ShouldBeNil)
+			So(glob.Set("other", 3), ShouldBeNil)
+			So(cacher.Set("aXbkey3", 4), ShouldBeNil)
+			So(cacher.Set("key4", 5), ShouldBeNil)
+
+			So(scanAll(glob, "key*"), ShouldResemble, []string{"key1", "key2"})
+			So(scanAll(glob, "*"), ShouldResemble, []string{"key1", "key2", "other"})
+		})
+
+		Convey("Invalidate Should Drop Every Key Of A Versioned Namespace", func() {
+			users := NewNamespace(cacher, "users", NamespaceOptions{Versioned: true})
+			other := NewNamespace(cacher, "users", NamespaceOptions{Versioned: true, RefreshInterval: time.Hour})
+			So(users.Set("1", "alice"), ShouldBeNil)
+			So(users.Set("2", "bob"), ShouldBeNil)
+			key, err := users.Key("1")
+			So(err, ShouldBeNil)
+			So(key, ShouldEqual, "users:0:1")
+			value, err := redis.String(other.Get("1"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "alice")
+
+			So(users.Invalidate(), ShouldBeNil)
+			reply, err := users.Get("1")
+			So(err, ShouldBeNil)
+			So(reply, ShouldBeNil)
+			So(scanAll(users, "*"), ShouldBeEmpty)
+			So(users.Set("1", "carol"), ShouldBeNil)
+			key, _ = users.Key("1")
+			So(key, ShouldEqual, "users:1:1")
+
+			// The other instance still uses the generation it has cached.
+			value, err = redis.String(other.Get("1"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "alice")
+		})
+
+		Convey("Namespaces Should Work Over The Memory Cacher", func() {
+			memory := NewMemoryCacher(100, 0)
+			users := NewNamespace(memory, "users", NamespaceOptions{Versioned: true})
+			So(users.Set("1", "alice"), ShouldBeNil)
+			So(scanAll(users, "*"), ShouldResemble, []string{"1"})
+			So(scanAll(memory, "*"), ShouldResemble, []string{"users:0:1"})
+
+			So(users.Invalidate(), ShouldBeNil)
+			reply, err := users.Get("1")
+			So(err, ShouldBeNil)
+			So(reply, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// ErrTxConflict is returned by Watch when a watched key kept changing for all the retries.
+var ErrTxConflict = errors.New("cache: transaction aborted because a watched key changed")
+
+// Cmd is a command queued in a pipeline or a transaction, its reply is set once it's executed.
+type Cmd struct {
+	Name  string
+	Args  []interface{}
+	Reply interface{}
+	Err   error
+}
+
+// Pipeline queues commands and sends them to redis in one round trip.
+// The commands are not atomic, use Watch for that.
+type Pipeline struct {
+	cacher *RedisCacher
+	cmds   []*Cmd
+}
+
+// Pipeline creates a new pipeline.
+func (o *RedisCacher) Pipeline() *Pipeline {
+	return &Pipeline{cacher: o}
+}
+
+// Queue queues a command, its reply is available in the returned Cmd after Exec.
+func (p *Pipeline) Queue(commandName string, args ...interface{}) *Cmd {
+	cmd := &Cmd{Name: commandName, Args: args}
+	p.cmds = append(p.cmds, cmd)
+	return cmd
+}
+
+// Len gets the number of queued commands.
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+// Exec sends all the queued commands and reads their replies, the queue is emptied afterwards.
+// It returns the first error among the commands, the result of every command is in the returned Cmds.
+// If the connection fails, every command gets the error of the connection.
+func (p *Pipeline) Exec(ctx context.Context) ([]*Cmd, error) {
+	cmds := p.cmds
+	p.cmds = nil
+	if len(cmds) == 0 {
+		return cmds, nil
+	}
+
+	// The replies are read into a copy, so the commands are never touched after Exec returns on a canceled context.
+	results, err := p.cacher.withConn(ctx, func(conn redis.Conn) (interface{}, error) {
+		return execPipeline(conn, cmds)
+	})
+	if err != nil {
+		for _, cmd := range cmds {
+			cmd.Err = err
+		}
+		return cmds, err
+	}
+	for i, result := range results.([]Cmd) {
+		cmds[i].Reply, cmds[i].Err = result.Reply, result.Err
+	}
+	return cmds, firstError(cmds)
+}
+
+// execPipeline sends the commands in one flush and reads back their replies in order.
+// It only returns an error if the connection fails, errors replied by redis are set in the results.
+func execPipeline(conn redis.Conn, cmds []*Cmd) ([]Cmd, error) {
+	for _, cmd := range cmds {
+		if err := conn.Send(cmd.Name, cmd.Args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	results := make([]Cmd, len(cmds))
+	for i := range cmds {
+		results[i].Reply, results[i].Err = conn.Receive()
+		if _, ok := results[i].Err.(redis.Error); results[i].Err != nil && !ok {
+			return nil, results[i].Err
+		}
+	}
+	return results, nil
+}
+
+func firstError(cmds []*Cmd) error {
+	for _, cmd := range cmds {
+		if cmd.Err != nil {
+			return cmd.Err
+		}
+	}
+	return nil
+}
+
+// Tx is an optimistic transaction created by Watch.
+type Tx struct {
+	conn redis.Conn
+	cmds []*Cmd
+}
+
+// Do runs a command immediately, it's meant to read the watched keys before queueing the writes.
+func (tx *Tx) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return tx.conn.Do(commandName, args...)
+}
+
+// Queue queues a command which is executed atomically with the others in MULTI/EXEC.
+func (tx *Tx) Queue(commandName string, args ...interface{}) *Cmd {
+	cmd := &Cmd{Name: commandName, Args: args}
+	tx.cmds = append(tx.cmds, cmd)
+	return cmd
+}
+
+// Watch runs fn in an optimistic transaction which watches keys.
+// fn reads the current values with tx.Do and queues the writes with tx.Queue, the queued commands are executed in MULTI/EXEC
+// only if none of the keys has been modified since they are watched. Otherwise fn is run again, up to MaxTxRetries times.
+// An error returned by fn aborts the transaction.
+// fn runs in the calling goroutine, so it's never running once Watch has returned. The commands are limited by
+// the deadline of ctx and fail once it's done, so fn may be interrupted: tx.Do then returns the error of ctx.
+func (o *RedisCacher) Watch(ctx context.Context, fn func(tx *Tx) error, keys ...string) error {
+	for i := 0; i < o.options.MaxTxRetries; i++ {
+		conn, err := o.GetConnContext(ctx)
+		if err != nil {
+			return err
+		}
+		err = runTx(conn, fn, keys)
+		conn.Close()
+		if err != ErrTxConflict {
+			if err != nil {
+				return contextError(ctx, err)
+			}
+			return nil
+		}
+	}
+	return ErrTxConflict
+}
+
+func runTx(conn redis.Conn, fn func(tx *Tx) error, keys []string) error {
+	if len(keys) > 0 {
+		if _, err := conn.Do("WATCH", redis.Args{}.AddFlat(keys)...); err != nil {
+			return err
+		}
+	}
+
+	tx := &Tx{conn: conn}
+	if err := fn(tx); err != nil {
+		conn.Do("UNWATCH")
+		return err
+	}
+	if len(tx.cmds) == 0 {
+		_, err := conn.Do("UNWATCH")
+		return err
+	}
+
+	conn.Send("MULTI")
+	for _, cmd := range tx.cmds {
+		conn.Send(cmd.Name, cmd.Args...)
+	}
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err == redis.ErrNil {
+		return ErrTxConflict
+	} else if err != nil {
+		return err
+	}
+
+	for i, cmd := range tx.cmds {
+		if e, ok := replies[i].(redis.Error); ok {
+			cmd.Err = e
+		} else {
+			cmd.Reply = replies[i]
+		}
+	}
+	return firstError(tx.cmds)
+}
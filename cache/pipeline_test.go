@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPipeline(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Test Pipelines And Transactions\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		Convey("Pipeline Should Execute The Commands In Order", func() {
+			pipeline := cacher.Pipeline()
+			set := pipeline.Queue("SET", "testKey", "testValue")
+			expire := pipeline.Queue("EXPIRE", "testKey", 300)
+			get := pipeline.Queue("GET", "testKey")
+			So(pipeline.Len(), ShouldEqual, 3)
+
+			cmds, err := pipeline.Exec(ctx)
+			So(err, ShouldBeNil)
+			So(len(cmds), ShouldEqual, 3)
+			So(pipeline.Len(), ShouldEqual, 0)
+			So(set.Reply, ShouldEqual, "OK")
+			So(expire.Reply, ShouldEqual, int64(1))
+			value, _ := redis.String(get.Reply, get.Err)
+			So(value, ShouldEqual, "testValue")
+
+			ttl, _ := cacher.TTL("testKey")
+			So(ttl, ShouldBeBetween, 298, 301)
+		})
+
+		Convey("Pipeline Should Report The Errors Of Commands", func() {
+			pipeline := cacher.Pipeline()
+			pipeline.Queue("SET", "testKey", "testValue")
+			incr := pipeline.Queue("INCR", "testKey")
+			get := pipeline.Queue("GET", "testKey")
+
+			_, err := pipeline.Exec(ctx)
+			So(err, ShouldNotBeNil)
+			So(incr.Err, ShouldEqual, err)
+			So(get.Err, ShouldBeNil)
+		})
+
+		Convey("Transaction Should Execute The Commands Atomically", func() {
+			cacher.Set("counter", 0)
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					err := cacher.Watch(ctx, func(tx *Tx) error {
+						n, err := redis.Int(tx.Do("GET", "counter"))
+						if err != nil {
+							return err
+						}
+						tx.Queue("SET", "counter", n+1)
+						return nil
+					}, "counter")
+					if err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			n, err := cacher.GetInt64("counter")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 5)
+		})
+
+		Convey("Transaction Should Fail When A Watched Key Changes", func() {
+			cacher.Set("counter", 0)
+			calls := 0
+			err := cacher.Watch(ctx, func(tx *Tx) error {
+				calls++
+				// Modify the watched key from another connection.
+				cacher.Set("counter", calls)
+				tx.Queue("SET", "counter", -1)
+				return nil
+			}, "counter")
+			So(err, ShouldEqual, ErrTxConflict)
+			So(calls, ShouldEqual, 10)
+			n, _ := cacher.GetInt64("counter")
+			So(n, ShouldEqual, 10)
+		})
+
+		Convey("Transaction Should Be Aborted By The Error Of The Function", func() {
+			abortErr := errors.New("abort")
+			err := cacher.Watch(ctx, func(tx *Tx) error {
+				tx.Queue("SET", "counter", -1)
+				return abortErr
+			}, "counter")
+			So(err, ShouldEqual, abortErr)
+		})
+
+		Convey("Transaction Should Not Outlive Watch When The Context Is Canceled", func() {
+			watchCtx, cancel := context.WithCancel(ctx)
+			finished := false
+			err := cacher.Watch(watchCtx, func(tx *Tx) error {
+				cancel()
+				time.Sleep(20 * time.Millisecond)
+				_, err := tx.Do("GET", "counter")
+				tx.Queue("SET", "counter", 1)
+				finished = true
+				return err
+			}, "counter")
+			So(err, ShouldEqual, context.Canceled)
+			So(finished, ShouldBeTrue)
+			value, _ := cacher.Get("counter")
+			So(value, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,287 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	defaultPingInterval = 30 * time.Second
+	minReconnectDelay   = 100 * time.Millisecond
+	maxReconnectDelay   = 10 * time.Second
+)
+
+// Publish publishes a message to channel and returns the number of subscribers which received it.
+func (o *RedisCacher) Publish(channel string, message interface{}) (int, error) {
+	return o.PublishContext(context.Background(), channel, message)
+}
+
+// PublishContext publishes a message to channel with context.
+func (o *RedisCacher) PublishContext(ctx context.Context, channel string, message interface{}) (int, error) {
+	return redis.Int(o.DoContext(ctx, "PUBLISH", channel, message))
+}
+
+// Message is a message received by a Subscriber, Pattern is set if it's received through PSubscribe.
+type Message struct {
+	Channel string
+	Pattern string
+	Data    []byte
+}
+
+// Subscriber receives the messages of the subscribed channels and patterns on a dedicated connection.
+// It pings redis to detect dead connections, and reconnects with backoff and subscribes again when the connection is lost.
+// Messages published while it's disconnected are lost, as redis doesn't keep them.
+type Subscriber struct {
+	cacher       *RedisCacher
+	pingInterval time.Duration
+	messages     chan Message
+
+	mu       sync.Mutex
+	conn     *redis.PubSubConn
+	channels map[string]bool
+	patterns map[string]bool
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	done      chan struct{}
+}
+
+// NewSubscriber creates a subscriber which pings redis every pingInterval, it defaults to 30 seconds if it's 0.
+// The subscriber must be closed after use.
+func (o *RedisCacher) NewSubscriber(pingInterval time.Duration) *Subscriber {
+	if pingInterval <= 0 {
+		pingInterval = defaultPingInterval
+	}
+	s := &Subscriber{
+		cacher:       o,
+		pingInterval: pingInterval,
+		messages:     make(chan Message, 100),
+		channels:     make(map[string]bool),
+		patterns:     make(map[string]bool),
+		closed:       make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Messages returns the channel of the received messages, it's closed when the subscriber is closed.
+func (s *Subscriber) Messages() <-chan Message {
+	return s.messages
+}
+
+// Subscribe subscribes to the channels.
+func (s *Subscriber) Subscribe(channels ...string) error {
+	return s.update(s.channels, true, "SUBSCRIBE", channels)
+}
+
+// PSubscribe subscribes to the channels matching the patterns.
+func (s *Subscriber) PSubscribe(patterns ...string) error {
+	return s.update(s.patterns, true, "PSUBSCRIBE", patterns)
+}
+
+// Unsubscribe unsubscribes from the channels, or from all the channels if none is given.
+func (s *Subscriber) Unsubscribe(channels ...string) error {
+	return s.update(s.channels, false, "UNSUBSCRIBE", channels)
+}
+
+// PUnsubscribe unsubscribes from the patterns, or from all the patterns if none is given.
+func (s *Subscriber) PUnsubscribe(patterns ...string) error {
+	return s.update(s.patterns, false, "PUNSUBSCRIBE", patterns)
+}
+
+// update records the subscriptions and sends the command if the subscriber is connected,
+// otherwise they're sent when the connection is established.
+func (s *Subscriber) update(set map[string]bool, subscribe bool, commandName string, names []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if subscribe {
+		for _, name := range names {
+			set[name] = true
+		}
+	} else if len(names) == 0 {
+		for name := range set {
+			delete(set, name)
+		}
+	} else {
+		for _, name := range names {
+			delete(set, name)
+		}
+	}
+
+	if s.conn == nil || (subscribe && len(names) == 0) {
+		return nil
+	}
+	return s.send(commandName, names)
+}
+
+// send sends a command on the connection, the caller must hold the lock.
+func (s *Subscriber) send(commandName string, names []string) error {
+	if err := s.conn.Conn.Send(commandName, redis.Args{}.AddFlat(names)...); err != nil {
+		return err
+	}
+	return s.conn.Conn.Flush()
+}
+
+// Close closes the subscriber and its connection.
+func (s *Subscriber) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.mu.Lock()
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		s.mu.Unlock()
+	})
+	<-s.done
+	return nil
+}
+
+func (s *Subscriber) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// run keeps a connection and receives the messages until the subscriber is closed.
+func (s *Subscriber) run() {
+	defer close(s.done)
+	defer close(s.messages)
+
+	delay := minReconnectDelay
+	for !s.isClosed() {
+		conn, err := s.cacher.dial()
+		if err == nil {
+			err = s.subscribeAll(conn)
+		}
+		if err != nil {
+			select {
+			case <-time.After(delay):
+			case <-s.closed:
+				return
+			}
+			if delay *= 2; delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+			continue
+		}
+		delay = minReconnectDelay
+
+		s.receive()
+
+		s.mu.Lock()
+		if s.conn != nil {
+			s.conn.Close()
+			s.conn = nil
+		}
+		s.mu.Unlock()
+	}
+}
+
+// subscribeAll sets up a new connection with all the subscriptions.
+func (s *Subscriber) subscribeAll(conn redis.Conn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isClosed() {
+		conn.Close()
+		return nil
+	}
+
+	s.conn = &redis.PubSubConn{Conn: pongConn{conn}}
+	for commandName, set := range map[string]map[string]bool{"SUBSCRIBE": s.channels, "PSUBSCRIBE": s.patterns} {
+		if len(set) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(set))
+		for name := range set {
+			names = append(names, name)
+		}
+		if err := s.send(commandName, names); err != nil {
+			s.conn = nil
+			conn.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// receive delivers the messages until the connection fails, a ping is sent every interval
+// and the connection is considered dead if nothing is received for two intervals.
+func (s *Subscriber) receive() {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return
+	}
+
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+	go func() {
+		ticker := time.NewTicker(s.pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.mu.Lock()
+				conn.Ping("")
+				s.mu.Unlock()
+			case <-stopPing:
+				return
+			}
+		}
+	}()
+
+	for {
+		var message Message
+		switch v := conn.ReceiveWithTimeout(2 * s.pingInterval).(type) {
+		case redis.Message:
+			message = Message{Channel: v.Channel, Data: v.Data}
+		case redis.PMessage:
+			message = Message{Channel: v.Channel, Pattern: v.Pattern, Data: v.Data}
+		case error:
+			return
+		default:
+			// Subscription confirmations and pongs.
+			continue
+		}
+
+		select {
+		case s.messages <- message:
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// pongConn turns the replies to PING of a connection without subscriptions into pongs, redis replies to them
+// like outside of the subscribed mode, which PubSubConn takes for an error.
+type pongConn struct {
+	redis.Conn
+}
+
+func (c pongConn) Receive() (interface{}, error) {
+	return pong(c.Conn.Receive())
+}
+
+func (c pongConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	return redis.DoWithTimeout(c.Conn, timeout, commandName, args...)
+}
+
+func (c pongConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return pong(redis.ReceiveWithTimeout(c.Conn, timeout))
+}
+
+func pong(reply interface{}, err error) (interface{}, error) {
+	switch reply.(type) {
+	case []byte, string:
+		return []interface{}{[]byte("pong"), reply}, err
+	}
+	return reply, err
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// receiveMessage waits for a message for up to a second.
+func receiveMessage(subscriber *Subscriber) (Message, bool) {
+	select {
+	case message, ok := <-subscriber.Messages():
+		return message, ok
+	case <-time.After(time.Second):
+		return Message{}, false
+	}
+}
+
+// waitSubscribers publishes to channel until it reaches a subscriber.
+func waitSubscribers(cacher *RedisCacher, channel string) bool {
+	for i := 0; i < 100; i++ {
+		if n, _ := cacher.Publish(channel, "ping"); n > 0 {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestPubSub(t *testing.T) {
+	Convey("Test Publish And Subscribe\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+
+		subscriber := cacher.NewSubscriber(100 * time.Millisecond)
+		defer subscriber.Close()
+
+		Convey("Subscriber Should Receive The Published Messages", func() {
+			So(subscriber.Subscribe("news"), ShouldBeNil)
+			So(subscriber.PSubscribe("events.*"), ShouldBeNil)
+			So(waitSubscribers(cacher, "news"), ShouldBeTrue)
+			message, ok := receiveMessage(subscriber)
+			So(ok, ShouldBeTrue)
+			So(message.Channel, ShouldEqual, "news")
+
+			n, err := cacher.Publish("events.login", "user1")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			message, ok = receiveMessage(subscriber)
+			So(ok, ShouldBeTrue)
+			So(message.Channel, ShouldEqual, "events.login")
+			So(message.Pattern, ShouldEqual, "events.*")
+			So(string(message.Data), ShouldEqual, "user1")
+
+			So(subscriber.Unsubscribe("news"), ShouldBeNil)
+			So(subscriber.PUnsubscribe(), ShouldBeNil)
+			time.Sleep(50 * time.Millisecond)
+			n, _ = cacher.Publish("news", "nobody")
+			So(n, ShouldEqual, 0)
+		})
+
+		Convey("Subscriber Should Stay Connected With Keepalive Pings", func() {
+			So(subscriber.Subscribe("news"), ShouldBeNil)
+			So(waitSubscribers(cacher, "news"), ShouldBeTrue)
+			receiveMessage(subscriber)
+
+			time.Sleep(500 * time.Millisecond)
+			cacher.Publish("news", "still here")
+			message, ok := receiveMessage(subscriber)
+			So(ok, ShouldBeTrue)
+			So(string(message.Data), ShouldEqual, "still here")
+		})
+
+		Convey("Subscriber Should Stay Connected Without Subscriptions", func() {
+			currentConn := func() *redis.PubSubConn {
+				subscriber.mu.Lock()
+				defer subscriber.mu.Unlock()
+				return subscriber.conn
+			}
+			var conn *redis.PubSubConn
+			for i := 0; i < 100 && conn == nil; i++ {
+				time.Sleep(10 * time.Millisecond)
+				conn = currentConn()
+			}
+			So(conn, ShouldNotBeNil)
+
+			time.Sleep(350 * time.Millisecond)
+			So(currentConn() == conn, ShouldBeTrue)
+
+			So(subscriber.Subscribe("news"), ShouldBeNil)
+			So(waitSubscribers(cacher, "news"), ShouldBeTrue)
+		})
+
+		Convey("Subscriber Should Reconnect And Resubscribe After A Connection Loss", func() {
+			So(subscriber.Subscribe("news"), ShouldBeNil)
+			So(subscriber.PSubscribe("events.*"), ShouldBeNil)
+			So(waitSubscribers(cacher, "news"), ShouldBeTrue)
+			receiveMessage(subscriber)
+
+			// Drop the connection under the subscriber.
+			subscriber.mu.Lock()
+			subscriber.conn.Conn.Close()
+			subscriber.mu.Unlock()
+
+			So(waitSubscribers(cacher, "events.login"), ShouldBeTrue)
+			message, ok := receiveMessage(subscriber)
+			So(ok, ShouldBeTrue)
+			So(message.Pattern, ShouldEqual, "events.*")
+			So(waitSubscribers(cacher, "news"), ShouldBeTrue)
+		})
+
+		Convey("Messages Should Be Closed When The Subscriber Is Closed", func() {
+			So(subscriber.Close(), ShouldBeNil)
+			_, ok := <-subscriber.Messages()
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,177 @@
+package queue
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/WUMUXIAN/go-common-utils/cache/cachetest"
+	"github.com/garyburd/redigo/redis"
+)
+
+// clusterNode is a cluster of a single node which forwards the commands to the test redis, after checking that
+// the keys of every command and of every transaction are in the same slot like a cluster node does.
+type clusterNode struct {
+	server *cachetest.ScriptedServer
+
+	mu    sync.Mutex
+	conns map[*cachetest.Conn]*nodeConn
+}
+
+// nodeConn is the connection to the test redis of a client of the node.
+type nodeConn struct {
+	redis.Conn
+	multi bool
+	// slot is the slot of the keys of the current transaction, -1 if it has none yet,
+	// and aborted tells that a command of the transaction has been refused.
+	slot    int
+	aborted bool
+}
+
+func newClusterNode() (*clusterNode, error) {
+	n := &clusterNode{conns: make(map[*cachetest.Conn]*nodeConn)}
+	server, err := cachetest.NewScriptedServer(func(client *cachetest.Conn, args []string) {
+		client.Reply(n.handle(client, args))
+	})
+	if err != nil {
+		return nil, err
+	}
+	n.server = server
+	return n, nil
+}
+
+func (n *clusterNode) close() {
+	n.server.Close()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, conn := range n.conns {
+		conn.Close()
+	}
+}
+
+func (n *clusterNode) conn(client *cachetest.Conn) (*nodeConn, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if conn, ok := n.conns[client]; ok {
+		return conn, nil
+	}
+	conn, err := redis.Dial("tcp", "127.0.0.1:6379", redis.DialDatabase(2))
+	if err != nil {
+		return nil, err
+	}
+	n.conns[client] = &nodeConn{Conn: conn, slot: -1}
+	return n.conns[client], nil
+}
+
+func (n *clusterNode) handle(client *cachetest.Conn, args []string) interface{} {
+	command := strings.ToUpper(args[0])
+	if command == "CLUSTER" {
+		host, port, _ := net.SplitHostPort(n.server.Addr())
+		p, _ := strconv.Atoi(port)
+		return []interface{}{[]interface{}{0, 16383, []interface{}{host, p, "id"}}}
+	}
+	conn, err := n.conn(client)
+	if err != nil {
+		return err
+	}
+
+	slot := -1
+	if conn.multi {
+		slot = conn.slot
+	}
+	for _, key := range commandKeys(command, args) {
+		if keySlot := slotOf(key); slot < 0 {
+			slot = keySlot
+		} else if keySlot != slot {
+			conn.aborted = conn.multi
+			return redis.Error("CROSSSLOT Keys in request don't hash to the same slot")
+		}
+	}
+	switch command {
+	case "MULTI":
+		conn.multi, conn.slot, conn.aborted = true, -1, false
+	case "EXEC", "DISCARD":
+		aborted := conn.aborted
+		conn.multi, conn.aborted = false, false
+		if aborted {
+			conn.Do("DISCARD")
+			return redis.Error("EXECABORT Transaction discarded because of previous errors.")
+		}
+	default:
+		if conn.multi {
+			conn.slot = slot
+		}
+	}
+
+	commandArgs := make([]interface{}, len(args)-1)
+	for i, arg := range args[1:] {
+		commandArgs[i] = arg
+	}
+	reply, err := conn.Do(args[0], commandArgs...)
+	if err != nil {
+		return err
+	}
+	return forwardedReply(reply)
+}
+
+// forwardedReply converts a reply of redigo to a reply of the scripted server.
+func forwardedReply(reply interface{}) interface{} {
+	switch reply := reply.(type) {
+	case string:
+		return cachetest.Status(reply)
+	case []interface{}:
+		values := make([]interface{}, len(reply))
+		for i, value := range reply {
+			values[i] = forwardedReply(value)
+		}
+		return values
+	}
+	return reply
+}
+
+// commandKeys gets the keys of the commands used by the queues.
+func commandKeys(command string, args []string) []string {
+	switch command {
+	case "PING", "SCAN", "MULTI", "EXEC", "DISCARD", "UNWATCH", "SCRIPT", "FLUSHDB":
+		return nil
+	case "LMOVE", "BLMOVE":
+		return args[1:3]
+	case "DEL", "EXISTS", "WATCH":
+		return args[1:]
+	case "EVAL", "EVALSHA":
+		n, _ := strconv.Atoi(args[2])
+		return args[3 : 3+n]
+	case "XGROUP":
+		return args[2:3]
+	case "XREADGROUP":
+		for i, arg := range args {
+			if strings.ToUpper(arg) == "STREAMS" {
+				streams := args[i+1:]
+				return streams[:len(streams)/2]
+			}
+		}
+	}
+	return args[1:2]
+}
+
+// slotOf gets the cluster slot of key.
+func slotOf(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return int(crc % 16384)
+}
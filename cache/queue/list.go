@@ -0,0 +1,235 @@
+package queue
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cache"
+	"github.com/WUMUXIAN/go-common-utils/cryptowrapper"
+	"github.com/garyburd/redigo/redis"
+)
+
+// ListHandler processes a payload of a ListQueue, the payload is removed if it returns nil and requeued otherwise.
+type ListHandler func(ctx context.Context, payload []byte) error
+
+// ListOptions defines the options of a list queue.
+type ListOptions struct {
+	// Consumer is the name of this consumer, defaults to a random name.
+	// A consumer which restarts with the same name gets back the payloads it was processing when it stopped.
+	Consumer string
+	// LeaseTimeout is how long a consumer is considered alive after its last heartbeat, defaults to 30 seconds.
+	// The payloads of a consumer which is no longer alive are requeued by Reap.
+	LeaseTimeout time.Duration
+	// BlockTimeout is how long Reserve waits for a payload, defaults to 1 second.
+	BlockTimeout time.Duration
+	// ReapInterval is how often Run requeues the payloads of the dead consumers, defaults to LeaseTimeout.
+	ReapInterval time.Duration
+	// Workers is the number of payloads Run handles concurrently, defaults to 1.
+	Workers int
+}
+
+func (options *ListOptions) setDefaults() {
+	if options.Consumer == "" {
+		options.Consumer = cryptowrapper.GenUUID()
+	}
+	if options.LeaseTimeout <= 0 {
+		options.LeaseTimeout = 30 * time.Second
+	}
+	if options.BlockTimeout <= 0 {
+		options.BlockTimeout = time.Second
+	}
+	if options.ReapInterval <= 0 {
+		options.ReapInterval = options.LeaseTimeout
+	}
+	if options.Workers <= 0 {
+		options.Workers = 1
+	}
+}
+
+// reapScript requeues the payloads of a processing list if its consumer's lease has expired, or if ARGV[1] is 1, oldest first.
+// KEYS[1] is the processing list, KEYS[2] the lease and KEYS[3] the queue.
+var reapScript = redis.NewScript(3, `
+if ARGV[1] ~= '1' and redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+local n = 0
+while redis.call('LMOVE', KEYS[1], KEYS[3], 'LEFT', 'RIGHT') do
+	n = n + 1
+end
+return n
+`)
+
+// ListQueue is a reliable queue on the list "queue:{<name>}:list", simpler than the streams of Queue.
+// Reserve moves a payload atomically to the processing list "queue:{<name>}:processing:<consumer>" where it stays
+// until it's acknowledged, and the lease "queue:{<name>}:lease:<consumer>" tells whether the consumer is alive.
+// The name is a hash tag, so that the keys of a queue are in the same slot in cluster mode.
+// The payloads should be unique, e.g. include an ID, since Ack removes a payload by its value.
+type ListQueue struct {
+	cacher     *cache.RedisCacher
+	prefix     string
+	list       string
+	processing string
+	lease      string
+	options    ListOptions
+}
+
+// NewListQueue creates a list queue.
+func NewListQueue(cacher *cache.RedisCacher, name string, options ListOptions) *ListQueue {
+	options.setDefaults()
+	prefix := keyPrefix + "{" + name + "}:"
+	return &ListQueue{
+		cacher:     cacher,
+		prefix:     prefix,
+		list:       prefix + "list",
+		processing: prefix + "processing:" + options.Consumer,
+		lease:      prefix + "lease:" + options.Consumer,
+		options:    options,
+	}
+}
+
+// Enqueue adds payloads to the queue.
+func (q *ListQueue) Enqueue(ctx context.Context, payloads ...[]byte) error {
+	args := make([]interface{}, len(payloads))
+	for i, payload := range payloads {
+		args[i] = payload
+	}
+	_, err := q.cacher.LPushContext(ctx, q.list, args...)
+	return err
+}
+
+// Reserve moves the oldest payload of the queue to the processing list of this consumer and returns it,
+// waiting up to BlockTimeout for one and returning nil if none arrives.
+// Every payload must be acknowledged with Ack once it's processed, or given back with Release.
+func (q *ListQueue) Reserve(ctx context.Context) ([]byte, error) {
+	if err := q.Heartbeat(ctx); err != nil {
+		return nil, err
+	}
+	payload, err := q.cacher.BLMoveContext(ctx, q.list, q.processing, "RIGHT", "LEFT", q.options.BlockTimeout)
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	return payload, err
+}
+
+// Heartbeat extends the lease of this consumer, Reserve and Run call it so that it's only needed
+// when a payload takes longer than LeaseTimeout to process outside of Run.
+func (q *ListQueue) Heartbeat(ctx context.Context) error {
+	_, err := q.cacher.DoContext(ctx, "SET", q.lease, 1, "PX", int64(q.options.LeaseTimeout/time.Millisecond))
+	return err
+}
+
+// Ack removes a processed payload from the processing list.
+func (q *ListQueue) Ack(ctx context.Context, payload []byte) error {
+	_, err := q.cacher.LRemContext(ctx, q.processing, -1, payload)
+	return err
+}
+
+// Release gives a payload back to the queue, it's delivered again after the payloads already in the queue.
+func (q *ListQueue) Release(ctx context.Context, payload []byte) error {
+	return q.cacher.Watch(ctx, func(tx *cache.Tx) error {
+		tx.Queue("LREM", q.processing, -1, payload)
+		tx.Queue("LPUSH", q.list, payload)
+		return nil
+	})
+}
+
+// Len gets the number of payloads waiting in the queue, the reserved ones aren't counted.
+func (q *ListQueue) Len(ctx context.Context) (int, error) {
+	return q.cacher.LLenContext(ctx, q.list)
+}
+
+// Reap requeues the payloads left in the processing lists of the consumers whose lease has expired,
+// they are delivered before the payloads already in the queue. It returns the number of payloads requeued.
+func (q *ListQueue) Reap(ctx context.Context) (int, error) {
+	processingPrefix := q.prefix + "processing:"
+	var lists []string
+	err := q.cacher.ScanEach(ctx, cache.ScanOptions{Match: processingPrefix + "*", Type: "list"}, func(key string) error {
+		lists = append(lists, key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	requeued := 0
+	for _, list := range lists {
+		lease := q.prefix + "lease:" + strings.TrimPrefix(list, processingPrefix)
+		n, err := redis.Int(q.cacher.RunScript(ctx, reapScript, list, lease, q.list, 0))
+		if err != nil {
+			return requeued, err
+		}
+		requeued += n
+	}
+	return requeued, nil
+}
+
+// Run handles the payloads with handler on Workers goroutines until the context is canceled,
+// meanwhile it keeps the lease of this consumer and reaps the dead consumers every ReapInterval.
+// It returns once all the running handlers have returned.
+func (q *ListQueue) Run(ctx context.Context, handler ListHandler) error {
+	// The payloads this consumer was processing before a restart are handled again.
+	if _, err := q.cacher.RunScript(ctx, reapScript, q.processing, q.lease, q.list, 1); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.maintain(ctx)
+	}()
+	for i := 0; i < q.options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, handler)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// maintain extends the lease of this consumer and reaps the dead consumers until the context is canceled.
+func (q *ListQueue) maintain(ctx context.Context) {
+	heartbeat := time.NewTicker(q.options.LeaseTimeout / 3)
+	defer heartbeat.Stop()
+	reap := time.NewTicker(q.options.ReapInterval)
+	defer reap.Stop()
+
+	q.Reap(ctx)
+	for {
+		select {
+		case <-heartbeat.C:
+			q.Heartbeat(ctx)
+		case <-reap.C:
+			q.Reap(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *ListQueue) work(ctx context.Context, handler ListHandler) {
+	for ctx.Err() == nil {
+		payload, err := q.Reserve(ctx)
+		if err != nil {
+			// Wait a bit for redis to recover.
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		if payload == nil {
+			continue
+		}
+		// The acknowledgement isn't canceled with the context, so a finished payload isn't handled again after a shutdown.
+		if handler(ctx, payload) == nil {
+			q.Ack(context.Background(), payload)
+		} else {
+			q.Release(context.Background(), payload)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cache"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestListQueue(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Test The List Queue\n", t, func() {
+		cacher, err := cache.NewRedisCacherWithOptions(cache.RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		options := ListOptions{
+			Consumer:     "consumer1",
+			LeaseTimeout: 100 * time.Millisecond,
+			BlockTimeout: 50 * time.Millisecond,
+		}
+		q := NewListQueue(cacher, "jobs", options)
+
+		Convey("Payloads Should Be Delivered In Order And Removed When Acknowledged", func() {
+			So(q.Enqueue(ctx, []byte("job1"), []byte("job2")), ShouldBeNil)
+			n, err := q.Len(ctx)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+
+			payload, err := q.Reserve(ctx)
+			So(err, ShouldBeNil)
+			So(string(payload), ShouldEqual, "job1")
+			So(q.Ack(ctx, payload), ShouldBeNil)
+
+			payload, err = q.Reserve(ctx)
+			So(err, ShouldBeNil)
+			So(string(payload), ShouldEqual, "job2")
+			So(q.Release(ctx, payload), ShouldBeNil)
+			processing, _ := cacher.LLen("queue:{jobs}:processing:consumer1")
+			So(processing, ShouldEqual, 0)
+
+			payload, err = q.Reserve(ctx)
+			So(err, ShouldBeNil)
+			So(string(payload), ShouldEqual, "job2")
+			So(q.Ack(ctx, payload), ShouldBeNil)
+
+			payload, err = q.Reserve(ctx)
+			So(err, ShouldBeNil)
+			So(payload, ShouldBeNil)
+		})
+
+		Convey("The Payloads Of Dead Consumers Should Be Requeued", func() {
+			So(q.Enqueue(ctx, []byte("job1"), []byte("job2"), []byte("job3")), ShouldBeNil)
+			q.Reserve(ctx)
+			q.Reserve(ctx)
+
+			q2 := NewListQueue(cacher, "jobs", ListOptions{Consumer: "consumer2", LeaseTimeout: time.Minute})
+			n, err := q2.Reap(ctx)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+
+			time.Sleep(150 * time.Millisecond)
+			n, err = q2.Reap(ctx)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+
+			for _, expected := range []string{"job1", "job2", "job3"} {
+				payload, err := q2.Reserve(ctx)
+				So(err, ShouldBeNil)
+				So(string(payload), ShouldEqual, expected)
+			}
+		})
+
+		Convey("Run Should Handle The Payloads And Retry The Failed Ones", func() {
+			// A payload left by a previous run of the same consumer.
+			cacher.LPush("queue:{jobs}:processing:consumer1", "job0")
+			So(q.Enqueue(ctx, []byte("job1"), []byte("job2")), ShouldBeNil)
+
+			var mu sync.Mutex
+			handled := make(map[string]int)
+			runCtx, cancel := context.WithCancel(ctx)
+			done := make(chan error)
+			go func() {
+				done <- q.Run(runCtx, func(ctx context.Context, payload []byte) error {
+					mu.Lock()
+					defer mu.Unlock()
+					handled[string(payload)]++
+					if string(payload) == "job2" && handled["job2"] == 1 {
+						return errors.New("failed")
+					}
+					return nil
+				})
+			}()
+
+			time.Sleep(300 * time.Millisecond)
+			cancel()
+			So(<-done, ShouldEqual, context.Canceled)
+			So(handled, ShouldResemble, map[string]int{"job0": 1, "job1": 1, "job2": 2})
+			n, _ := q.Len(ctx)
+			So(n, ShouldEqual, 0)
+			n, _ = cacher.LLen("queue:{jobs}:processing:consumer1")
+			So(n, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestListQueueCluster(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Test The List Queue In Cluster Mode\n", t, func() {
+		node, err := newClusterNode()
+		So(err, ShouldBeNil)
+		defer node.close()
+		cacher, err := cache.NewRedisCacherWithOptions(cache.RedisOptions{ClusterAddrs: []string{node.server.Addr()}})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		Convey("The Keys Of A Queue Should Be In The Same Slot", func() {
+			err := cacher.Watch(ctx, func(tx *cache.Tx) error {
+				tx.Queue("LPUSH", "queue:tasks:list", "job")
+				tx.Queue("LPUSH", "queue:tasks:processing:consumer1", "job")
+				return nil
+			})
+			So(err, ShouldNotBeNil)
+
+			q := NewListQueue(cacher, "tasks", ListOptions{Consumer: "consumer1", LeaseTimeout: 100 * time.Millisecond, BlockTimeout: 50 * time.Millisecond})
+			So(q.Enqueue(ctx, []byte("job1"), []byte("job2")), ShouldBeNil)
+			payload, err := q.Reserve(ctx)
+			So(err, ShouldBeNil)
+			So(string(payload), ShouldEqual, "job1")
+			So(q.Release(ctx, payload), ShouldBeNil)
+			payload, err = q.Reserve(ctx)
+			So(err, ShouldBeNil)
+			So(string(payload), ShouldEqual, "job2")
+
+			time.Sleep(150 * time.Millisecond)
+			n, err := NewListQueue(cacher, "tasks", ListOptions{Consumer: "consumer2"}).Reap(ctx)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			n, err = q.Len(ctx)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+		})
+	})
+}
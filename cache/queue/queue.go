@@ -0,0 +1,292 @@
+// Package queue implements an at-least-once job queue on redis streams with consumer groups.
+package queue
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cache"
+	"github.com/WUMUXIAN/go-common-utils/cryptowrapper"
+	"github.com/garyburd/redigo/redis"
+)
+
+const keyPrefix = "queue:"
+
+// Job is a job read from the queue.
+type Job struct {
+	ID      string
+	Payload []byte
+	// Deliveries is the number of times the job has been delivered, including this one.
+	Deliveries int
+}
+
+// Handler processes a job, the job is acknowledged if it returns nil and delivered again later otherwise.
+type Handler func(ctx context.Context, job *Job) error
+
+// Options defines the options of a queue.
+type Options struct {
+	// Group is the consumer group sharing the jobs, defaults to "workers".
+	Group string
+	// Consumer is the name of this consumer in the group, defaults to a random name.
+	Consumer string
+	// VisibilityTimeout is how long a delivered job can stay unacknowledged before it's delivered to another consumer,
+	// defaults to 30 seconds. It should be longer than the time a handler takes.
+	VisibilityTimeout time.Duration
+	// MaxRetries is the number of times a job is retried after its first delivery before it's moved to the dead-letter stream,
+	// defaults to 5.
+	MaxRetries int
+	// BlockTimeout is how long Reserve waits for new jobs, defaults to 1 second.
+	// The ReadTimeout of the redis connections must be longer.
+	BlockTimeout time.Duration
+	// Workers is the number of jobs Run handles concurrently, defaults to 1.
+	Workers int
+}
+
+func (options *Options) setDefaults() {
+	if options.Group == "" {
+		options.Group = "workers"
+	}
+	if options.Consumer == "" {
+		options.Consumer = cryptowrapper.GenUUID()
+	}
+	if options.VisibilityTimeout <= 0 {
+		options.VisibilityTimeout = 30 * time.Second
+	}
+	if options.MaxRetries <= 0 {
+		options.MaxRetries = 5
+	}
+	if options.BlockTimeout <= 0 {
+		options.BlockTimeout = time.Second
+	}
+	if options.Workers <= 0 {
+		options.Workers = 1
+	}
+}
+
+// Queue is a job queue stored in the stream "queue:{<name>}", jobs which fail too many times
+// are moved to the stream "queue:{<name>}:dead". The name is a hash tag, so that both streams are in the same slot
+// in cluster mode.
+type Queue struct {
+	cacher     *cache.RedisCacher
+	stream     string
+	deadLetter string
+	options    Options
+
+	// mu guards cursor, which is where the next reclaim resumes the scan of the pending jobs.
+	mu     sync.Mutex
+	cursor string
+}
+
+// New creates a queue and its consumer group if they don't exist.
+func New(ctx context.Context, cacher *cache.RedisCacher, name string, options Options) (*Queue, error) {
+	options.setDefaults()
+	q := &Queue{
+		cacher:     cacher,
+		stream:     keyPrefix + "{" + name + "}",
+		deadLetter: keyPrefix + "{" + name + "}:dead",
+		options:    options,
+		cursor:     "0-0",
+	}
+	_, err := cacher.DoContext(ctx, "XGROUP", "CREATE", q.stream, options.Group, "0", "MKSTREAM")
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Enqueue adds a job to the queue and returns its ID.
+func (q *Queue) Enqueue(ctx context.Context, payload []byte) (string, error) {
+	return redis.String(q.cacher.DoContext(ctx, "XADD", q.stream, "*", "payload", payload))
+}
+
+// Reserve delivers up to count jobs to this consumer. Jobs whose visibility timeout has expired are reclaimed first,
+// otherwise it waits up to BlockTimeout for new jobs and returns no job if none arrives.
+// Every job must be acknowledged with Ack once it's processed.
+func (q *Queue) Reserve(ctx context.Context, count int) ([]*Job, error) {
+	jobs, err := q.reclaim(ctx, count)
+	if err != nil || len(jobs) > 0 {
+		return jobs, err
+	}
+
+	reply, err := q.cacher.DoContext(ctx, "XREADGROUP", "GROUP", q.options.Group, q.options.Consumer,
+		"COUNT", count, "BLOCK", int64(q.options.BlockTimeout/time.Millisecond), "STREAMS", q.stream, ">")
+	if err == redis.ErrNil || reply == nil {
+		return nil, nil
+	}
+	streams, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	for _, stream := range streams {
+		values, err := redis.Values(stream, nil)
+		if err != nil || len(values) != 2 {
+			return nil, err
+		}
+		if jobs, err = parseJobs(values[1], nil); err != nil {
+			return nil, err
+		}
+	}
+	for _, job := range jobs {
+		job.Deliveries = 1
+	}
+	return jobs, nil
+}
+
+// reclaim claims the jobs which have been pending for longer than the visibility timeout,
+// the jobs which have been delivered too many times are moved to the dead-letter stream instead.
+// Each call resumes the scan of the pending jobs where the previous one stopped, and it starts over once it reaches the end.
+func (q *Queue) reclaim(ctx context.Context, count int) ([]*Job, error) {
+	q.mu.Lock()
+	cursor := q.cursor
+	q.mu.Unlock()
+	values, err := redis.Values(q.cacher.DoContext(ctx, "XAUTOCLAIM", q.stream, q.options.Group, q.options.Consumer,
+		int64(q.options.VisibilityTimeout/time.Millisecond), cursor, "COUNT", count))
+	if err != nil || len(values) < 2 {
+		return nil, err
+	}
+	if next, err := redis.String(values[0], nil); err == nil {
+		q.mu.Lock()
+		q.cursor = next
+		q.mu.Unlock()
+	}
+	claimed, err := parseJobs(values[1], nil)
+	if err != nil || len(claimed) == 0 {
+		return nil, err
+	}
+
+	// XAUTOCLAIM doesn't report the delivery counts, they're read from the pending entries.
+	pipeline := q.cacher.Pipeline()
+	pending := make([]*cache.Cmd, len(claimed))
+	for i, job := range claimed {
+		pending[i] = pipeline.Queue("XPENDING", q.stream, q.options.Group, job.ID, job.ID, 1)
+	}
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	jobs := claimed[:0]
+	for i, job := range claimed {
+		entries, err := redis.Values(pending[i].Reply, nil)
+		if err != nil || len(entries) == 0 {
+			// The job has been acknowledged meanwhile.
+			continue
+		}
+		entry, err := redis.Values(entries[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		var id, consumer string
+		var idle int64
+		if _, err := redis.Scan(entry, &id, &consumer, &idle, &job.Deliveries); err != nil {
+			return nil, err
+		}
+		if job.Deliveries > q.options.MaxRetries+1 {
+			if err := q.bury(ctx, job); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// bury moves a job to the dead-letter stream.
+func (q *Queue) bury(ctx context.Context, job *Job) error {
+	return q.cacher.Watch(ctx, func(tx *cache.Tx) error {
+		tx.Queue("XADD", q.deadLetter, "*", "id", job.ID, "payload", job.Payload, "deliveries", job.Deliveries-1)
+		tx.Queue("XACK", q.stream, q.options.Group, job.ID)
+		tx.Queue("XDEL", q.stream, job.ID)
+		return nil
+	})
+}
+
+// Ack acknowledges a processed job and removes it from the queue.
+func (q *Queue) Ack(ctx context.Context, job *Job) error {
+	return q.cacher.Watch(ctx, func(tx *cache.Tx) error {
+		tx.Queue("XACK", q.stream, q.options.Group, job.ID)
+		tx.Queue("XDEL", q.stream, job.ID)
+		return nil
+	})
+}
+
+// Len gets the number of jobs in the queue, including the delivered ones which are not acknowledged yet.
+func (q *Queue) Len(ctx context.Context) (int, error) {
+	return redis.Int(q.cacher.DoContext(ctx, "XLEN", q.stream))
+}
+
+// DeadLetters gets up to count jobs from the dead-letter stream, oldest first.
+// Their IDs are the IDs they had in the queue, and Deliveries is the number of times they have been tried.
+func (q *Queue) DeadLetters(ctx context.Context, count int) ([]*Job, error) {
+	return parseJobs(q.cacher.DoContext(ctx, "XRANGE", q.deadLetter, "-", "+", "COUNT", count))
+}
+
+// Run handles the jobs with handler on Workers goroutines until the context is canceled,
+// it returns once all the running handlers have returned.
+func (q *Queue) Run(ctx context.Context, handler Handler) error {
+	var wg sync.WaitGroup
+	for i := 0; i < q.options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, handler)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (q *Queue) work(ctx context.Context, handler Handler) {
+	for ctx.Err() == nil {
+		jobs, err := q.Reserve(ctx, 1)
+		if err != nil {
+			// Wait a bit for redis to recover.
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		for _, job := range jobs {
+			// A failed job stays pending and it's delivered again once its visibility timeout expires.
+			// The acknowledgement isn't canceled with the context, so a finished job isn't run again after a shutdown.
+			if handler(ctx, job) == nil {
+				q.Ack(context.Background(), job)
+			}
+		}
+	}
+}
+
+// parseJobs parses stream entries, every entry is an ID followed by a list of fields and values.
+func parseJobs(reply interface{}, err error) ([]*Job, error) {
+	entries, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*Job, 0, len(entries))
+	for _, entry := range entries {
+		values, ok := entry.([]interface{})
+		if !ok || len(values) != 2 {
+			// Entries deleted while pending are nil.
+			continue
+		}
+		id, err := redis.String(values[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		fields, err := redis.StringMap(values[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		job := &Job{ID: id, Payload: []byte(fields["payload"])}
+		if originalID, ok := fields["id"]; ok {
+			job.ID = originalID
+			job.Deliveries, _ = strconv.Atoi(fields["deliveries"])
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
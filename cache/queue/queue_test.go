@@ -0,0 +1,191 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cache"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Test The Job Queue\n", t, func() {
+		cacher, err := cache.NewRedisCacherWithOptions(cache.RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		options := Options{
+			Consumer:          "consumer1",
+			VisibilityTimeout: 100 * time.Millisecond,
+			MaxRetries:        1,
+			BlockTimeout:      50 * time.Millisecond,
+		}
+		q, err := New(ctx, cacher, "jobs", options)
+		So(err, ShouldBeNil)
+
+		Convey("Creating The Queue Again Should Reuse The Group", func() {
+			_, err := New(ctx, cacher, "jobs", options)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Jobs Should Be Delivered Once And Removed When Acknowledged", func() {
+			id, err := q.Enqueue(ctx, []byte("job1"))
+			So(err, ShouldBeNil)
+			So(id, ShouldNotBeEmpty)
+
+			jobs, err := q.Reserve(ctx, 10)
+			So(err, ShouldBeNil)
+			So(len(jobs), ShouldEqual, 1)
+			So(jobs[0].ID, ShouldEqual, id)
+			So(string(jobs[0].Payload), ShouldEqual, "job1")
+			So(jobs[0].Deliveries, ShouldEqual, 1)
+
+			jobs2, err := q.Reserve(ctx, 10)
+			So(err, ShouldBeNil)
+			So(len(jobs2), ShouldEqual, 0)
+
+			So(q.Ack(ctx, jobs[0]), ShouldBeNil)
+			n, err := q.Len(ctx)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+		})
+
+		Convey("Unacknowledged Jobs Should Be Reclaimed And Then Dead Lettered", func() {
+			id, _ := q.Enqueue(ctx, []byte("job1"))
+			jobs, _ := q.Reserve(ctx, 10)
+			So(len(jobs), ShouldEqual, 1)
+
+			time.Sleep(150 * time.Millisecond)
+			q2, _ := New(ctx, cacher, "jobs", Options{Consumer: "consumer2", VisibilityTimeout: 100 * time.Millisecond, MaxRetries: 1})
+			jobs, err := q2.Reserve(ctx, 10)
+			So(err, ShouldBeNil)
+			So(len(jobs), ShouldEqual, 1)
+			So(jobs[0].ID, ShouldEqual, id)
+			So(jobs[0].Deliveries, ShouldEqual, 2)
+
+			time.Sleep(150 * time.Millisecond)
+			jobs, err = q.Reserve(ctx, 10)
+			So(err, ShouldBeNil)
+			So(len(jobs), ShouldEqual, 0)
+
+			dead, err := q.DeadLetters(ctx, 10)
+			So(err, ShouldBeNil)
+			So(len(dead), ShouldEqual, 1)
+			So(dead[0].ID, ShouldEqual, id)
+			So(string(dead[0].Payload), ShouldEqual, "job1")
+			So(dead[0].Deliveries, ShouldEqual, 2)
+			n, _ := q.Len(ctx)
+			So(n, ShouldEqual, 0)
+		})
+
+		Convey("The Pending Jobs Should Be Reclaimed Past The First Batch", func() {
+			var ids []string
+			for i := 0; i < 3; i++ {
+				id, _ := q.Enqueue(ctx, []byte{byte(i)})
+				ids = append(ids, id)
+			}
+			jobs, _ := q.Reserve(ctx, 10)
+			So(len(jobs), ShouldEqual, 3)
+
+			time.Sleep(150 * time.Millisecond)
+			q2, _ := New(ctx, cacher, "jobs", Options{Consumer: "consumer2", VisibilityTimeout: 100 * time.Millisecond})
+			// Every reclaim resumes after the job it claimed.
+			for i, id := range ids {
+				if i > 0 {
+					So(q2.cursor, ShouldEqual, id)
+				}
+				jobs, err := q2.Reserve(ctx, 1)
+				So(err, ShouldBeNil)
+				So(len(jobs), ShouldEqual, 1)
+				So(jobs[0].ID, ShouldEqual, id)
+			}
+			So(q2.cursor, ShouldEqual, "0-0")
+		})
+
+		Convey("Run Should Handle The Jobs With The Workers", func() {
+			q, err := New(ctx, cacher, "jobs", Options{Consumer: "consumer1", VisibilityTimeout: 100 * time.Millisecond,
+				BlockTimeout: 50 * time.Millisecond, Workers: 3})
+			So(err, ShouldBeNil)
+			for i := 0; i < 10; i++ {
+				q.Enqueue(ctx, []byte{byte(i)})
+			}
+
+			var mu sync.Mutex
+			handled := make(map[byte]int)
+			failed := false
+			runCtx, cancel := context.WithCancel(ctx)
+			done := make(chan error)
+			go func() {
+				done <- q.Run(runCtx, func(ctx context.Context, job *Job) error {
+					mu.Lock()
+					defer mu.Unlock()
+					// Fail the first job once, it should be retried.
+					if job.Payload[0] == 0 && !failed {
+						failed = true
+						return errors.New("failed")
+					}
+					handled[job.Payload[0]]++
+					if len(handled) == 10 {
+						cancel()
+					}
+					return nil
+				})
+			}()
+
+			select {
+			case err := <-done:
+				So(err, ShouldEqual, context.Canceled)
+			case <-time.After(5 * time.Second):
+				cancel()
+				<-done
+			}
+			So(len(handled), ShouldEqual, 10)
+			So(failed, ShouldBeTrue)
+			n, _ := q.Len(ctx)
+			So(n, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestQueueCluster(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Test The Job Queue In Cluster Mode\n", t, func() {
+		node, err := newClusterNode()
+		So(err, ShouldBeNil)
+		defer node.close()
+		cacher, err := cache.NewRedisCacherWithOptions(cache.RedisOptions{ClusterAddrs: []string{node.server.Addr()}})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		Convey("Poison Jobs Should Be Dead Lettered", func() {
+			q, err := New(ctx, cacher, "tasks", Options{Consumer: "consumer1", VisibilityTimeout: 100 * time.Millisecond,
+				MaxRetries: 1, BlockTimeout: 50 * time.Millisecond})
+			So(err, ShouldBeNil)
+			id, err := q.Enqueue(ctx, []byte("task"))
+			So(err, ShouldBeNil)
+			for deliveries := 1; deliveries <= 2; deliveries++ {
+				jobs, err := q.Reserve(ctx, 10)
+				So(err, ShouldBeNil)
+				So(len(jobs), ShouldEqual, 1)
+				So(jobs[0].Deliveries, ShouldEqual, deliveries)
+				time.Sleep(150 * time.Millisecond)
+			}
+			jobs, err := q.Reserve(ctx, 10)
+			So(err, ShouldBeNil)
+			So(len(jobs), ShouldEqual, 0)
+
+			dead, err := q.DeadLetters(ctx, 10)
+			So(err, ShouldBeNil)
+			So(len(dead), ShouldEqual, 1)
+			So(dead[0].ID, ShouldEqual, id)
+		})
+	})
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// KeyFunc extracts the key to rate limit a request by.
+type KeyFunc func(r *http.Request) string
+
+// RemoteIP rate limits requests by the IP address of the client.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Middleware rate limits the requests to the handler by the key returned by keyFunc, RemoteIP is used if it's nil.
+// It sets the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers on every response,
+// and responds 429 Too Many Requests with a Retry-After header when the limit is exceeded.
+// Requests are let through if the limiter fails, so redis being unavailable doesn't take the service down.
+func Middleware(limiter Limiter, keyFunc KeyFunc) func(http.Handler) http.Handler {
+	if keyFunc == nil {
+		keyFunc = RemoteIP
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := limiter.Allow(r.Context(), keyFunc(r))
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header := w.Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", seconds(result.ResetAfter))
+			if !result.Allowed {
+				header.Set("Retry-After", seconds(result.RetryAfter))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds formats a duration as whole seconds rounded up, as the headers require.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,203 @@
+// Package ratelimit offers cluster wide rate limiters backed by redis, the limits are checked atomically by lua scripts.
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cache"
+	"github.com/WUMUXIAN/go-common-utils/cryptowrapper"
+	"github.com/garyburd/redigo/redis"
+)
+
+// keyPrefix is prepended to the keys of all the limiters.
+const keyPrefix = "ratelimit:"
+
+// Result is the outcome of checking a rate limit.
+type Result struct {
+	// Allowed tells whether the request is allowed.
+	Allowed bool
+	// Limit is the maximum number of requests in a burst.
+	Limit int
+	// Remaining is how many more requests are allowed right now.
+	Remaining int
+	// RetryAfter is how long to wait until the next request is allowed, it's 0 when the request is allowed.
+	RetryAfter time.Duration
+	// ResetAfter is how long it takes until the limit is fully available again.
+	ResetAfter time.Duration
+}
+
+// Limiter defines the interface of the rate limiters.
+type Limiter interface {
+	// Allow checks and consumes one request for key.
+	Allow(ctx context.Context, key string) (*Result, error)
+}
+
+// Limit defines Rate requests per Period with bursts of up to Burst requests.
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// PerSecond allows rate requests per second.
+func PerSecond(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Second, Burst: rate}
+}
+
+// PerMinute allows rate requests per minute.
+func PerMinute(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Minute, Burst: rate}
+}
+
+// PerHour allows rate requests per hour.
+func PerHour(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Hour, Burst: rate}
+}
+
+// gcraScript implements the generic cell rate algorithm, which behaves like a token bucket but stores one timestamp,
+// the theoretical arrival time (TAT). The time is read from redis so the clocks of the clients don't matter.
+var gcraScript = redis.NewScript(1, `
+redis.replicate_commands()
+
+local key = KEYS[1]
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+
+local emission_interval = period / rate
+local burst_offset = emission_interval * burst
+
+-- Count from 2017-01-01 to keep enough precision in the floats.
+local time = redis.call("TIME")
+local now = (time[1] - 1483228800) + (time[2] / 1000000)
+
+local tat = redis.call("GET", key)
+if tat then
+	tat = math.max(tonumber(tat), now)
+else
+	tat = now
+end
+
+local new_tat = tat + emission_interval
+local diff = now - (new_tat - burst_offset)
+if diff < 0 then
+	return {0, 0, tostring(-diff), tostring(tat - now)}
+end
+
+local reset_after = new_tat - now
+redis.call("SET", key, tostring(new_tat), "EX", math.ceil(reset_after))
+return {1, math.floor(diff / emission_interval), "0", tostring(reset_after)}
+`)
+
+// GCRA is a token bucket rate limiter implemented with the generic cell rate algorithm.
+type GCRA struct {
+	cacher *cache.RedisCacher
+	limit  Limit
+}
+
+// NewGCRA creates a token bucket rate limiter, it panics if the rate or the period isn't positive.
+func NewGCRA(cacher *cache.RedisCacher, limit Limit) *GCRA {
+	if limit.Rate <= 0 || limit.Period <= 0 {
+		panic("ratelimit: the rate and the period must be positive")
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = 1
+	}
+	return &GCRA{cacher: cacher, limit: limit}
+}
+
+// Allow checks and consumes one request for key.
+func (o *GCRA) Allow(ctx context.Context, key string) (*Result, error) {
+	values, err := redis.Values(o.cacher.RunScript(ctx, gcraScript, keyPrefix+key,
+		o.limit.Burst, o.limit.Rate, o.limit.Period.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	return parseResult(values, o.limit.Burst, time.Second)
+}
+
+// slidingWindowScript keeps a log of the requests in a sorted set scored by their time in milliseconds.
+var slidingWindowScript = redis.NewScript(1, `
+redis.replicate_commands()
+
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+local count = redis.call("ZCARD", key)
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", key, now, ARGV[3])
+	redis.call("PEXPIRE", key, window)
+	count = count + 1
+	allowed = 1
+end
+
+local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+local reset_after = window
+if oldest[2] then
+	reset_after = tonumber(oldest[2]) + window - now
+end
+if allowed == 1 then
+	return {1, limit - count, "0", tostring(reset_after)}
+end
+return {0, 0, tostring(reset_after), tostring(reset_after)}
+`)
+
+// SlidingWindow is a rate limiter which allows up to limit requests in any window of time.
+// It's exact but it stores every request of the window, so it suits small limits.
+type SlidingWindow struct {
+	cacher *cache.RedisCacher
+	limit  int
+	window time.Duration
+}
+
+// NewSlidingWindow creates a sliding window log rate limiter, it panics if the limit isn't positive
+// or if the window is shorter than a millisecond.
+func NewSlidingWindow(cacher *cache.RedisCacher, limit int, window time.Duration) *SlidingWindow {
+	if limit <= 0 || window < time.Millisecond {
+		panic("ratelimit: the limit must be positive and the window at least a millisecond")
+	}
+	return &SlidingWindow{cacher: cacher, limit: limit, window: window}
+}
+
+// Allow checks and consumes one request for key.
+func (o *SlidingWindow) Allow(ctx context.Context, key string) (*Result, error) {
+	values, err := redis.Values(o.cacher.RunScript(ctx, slidingWindowScript, keyPrefix+key,
+		o.limit, int64(o.window/time.Millisecond), cryptowrapper.GenUUID()))
+	if err != nil {
+		return nil, err
+	}
+	return parseResult(values, o.limit, time.Millisecond)
+}
+
+// parseResult parses the {allowed, remaining, retry after, reset after} reply of the scripts,
+// the durations are strings in the given unit.
+func parseResult(values []interface{}, limit int, unit time.Duration) (*Result, error) {
+	var allowed, remaining int
+	var retryAfter, resetAfter string
+	if _, err := redis.Scan(values, &allowed, &remaining, &retryAfter, &resetAfter); err != nil {
+		return nil, err
+	}
+	retry, err := strconv.ParseFloat(retryAfter, 64)
+	if err != nil {
+		return nil, err
+	}
+	reset, err := strconv.ParseFloat(resetAfter, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Allowed:    allowed == 1,
+		Limit:      limit,
+		Remaining:  remaining,
+		RetryAfter: time.Duration(retry * float64(unit)),
+		ResetAfter: time.Duration(reset * float64(unit)),
+	}, nil
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cache"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRateLimit(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Test The Rate Limiters\n", t, func() {
+		cacher, err := cache.NewRedisCacherWithOptions(cache.RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		Convey("GCRA Should Allow Bursts Up To The Limit", func() {
+			limiter := NewGCRA(cacher, PerMinute(3))
+			for i := 2; i >= 0; i-- {
+				result, err := limiter.Allow(ctx, "gcra")
+				So(err, ShouldBeNil)
+				So(result.Allowed, ShouldBeTrue)
+				So(result.Limit, ShouldEqual, 3)
+				So(result.Remaining, ShouldEqual, i)
+				So(result.RetryAfter, ShouldEqual, 0)
+			}
+
+			result, err := limiter.Allow(ctx, "gcra")
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeFalse)
+			So(result.Remaining, ShouldEqual, 0)
+			So(result.RetryAfter, ShouldBeBetween, 19*time.Second, 21*time.Second)
+			So(result.ResetAfter, ShouldBeBetween, 59*time.Second, 61*time.Second)
+
+			result, err = limiter.Allow(ctx, "another")
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeTrue)
+		})
+
+		Convey("Sliding Window Should Allow Up To The Limit In The Window", func() {
+			limiter := NewSlidingWindow(cacher, 2, time.Minute)
+			result, err := limiter.Allow(ctx, "window")
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeTrue)
+			So(result.Remaining, ShouldEqual, 1)
+			result, err = limiter.Allow(ctx, "window")
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeTrue)
+			So(result.Remaining, ShouldEqual, 0)
+
+			result, err = limiter.Allow(ctx, "window")
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeFalse)
+			So(result.RetryAfter, ShouldBeBetween, 59*time.Second, 61*time.Second)
+		})
+
+		Convey("Invalid Limits Should Be Rejected", func() {
+			So(func() { NewGCRA(cacher, PerMinute(0)) }, ShouldPanic)
+			So(func() { NewGCRA(cacher, Limit{Rate: 1}) }, ShouldPanic)
+			So(func() { NewSlidingWindow(cacher, 0, time.Minute) }, ShouldPanic)
+			So(func() { NewSlidingWindow(cacher, 1, 0) }, ShouldPanic)
+			So(func() { NewSlidingWindow(cacher, 1, time.Millisecond) }, ShouldNotPanic)
+		})
+
+		Convey("The Middleware Should Set The Headers And Reject Exceeding Requests", func() {
+			handler := Middleware(NewGCRA(cacher, PerMinute(1)), nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			request := httptest.NewRequest("GET", "/", nil)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("RateLimit-Limit"), ShouldEqual, "1")
+			So(recorder.Header().Get("RateLimit-Remaining"), ShouldEqual, "0")
+			So(recorder.Header().Get("RateLimit-Reset"), ShouldBeIn, "60", "61")
+
+			recorder = httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			So(recorder.Code, ShouldEqual, http.StatusTooManyRequests)
+			So(recorder.Header().Get("Retry-After"), ShouldBeIn, "60", "61")
+		})
+	})
+}
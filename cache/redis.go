@@ -1,9 +1,11 @@
 package cache
 
 import (
-	"bytes"
-	"encoding/gob"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"net"
+	"sync"
 	"time"
 
 	"github.com/garyburd/redigo/redis"
@@ -11,58 +13,366 @@ import (
 
 // RedisCacher is an redis implementation of cacher.
 type RedisCacher struct {
-	p       *redis.Pool
-	dbIndex int
+	p        *redis.Pool
+	options  RedisOptions
+	sentinel *sentinel
+	cluster  *cluster
+
+	// blocking is the pool of the blocking commands, it's created on first use.
+	blockingOnce sync.Once
+	blocking     *redis.Pool
+}
+
+// RedisOptions defines the options to create a redis cacher.
+type RedisOptions struct {
+	// Addr is the address of the redis server, e.g. 127.0.0.1:6379.
+	Addr string
+	// Username is used together with Password to authenticate with redis ACL, leave it empty to use the default user.
+	Username string
+	Password string
+	// DB is the index of the database to select.
+	DB int
+	// ClientName is set with CLIENT SETNAME on each connection if not empty.
+	ClientName string
+
+	// MaxActive is the maximum number of connections allocated by the pool at a given time, 0 means no limit.
+	MaxActive int
+	// MaxIdle is the maximum number of idle connections in the pool, defaults to 3.
+	MaxIdle int
+	// IdleTimeout closes connections after remaining idle for this duration, defaults to 240 seconds.
+	IdleTimeout time.Duration
+	// Wait makes the pool wait for a connection to be returned when MaxActive is reached instead of failing.
+	Wait bool
+
+	// DialTimeout is the timeout for connecting to redis, defaults to 1 second.
+	DialTimeout time.Duration
+	// ReadTimeout and WriteTimeout are the timeouts of a single command, 0 means no timeout.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// TLSConfig enables TLS when not nil.
+	TLSConfig *tls.Config
+
+	// Serializer is used by the typed API like SetValue and GetInto, defaults to JSONSerializer.
+	Serializer Serializer
+
+	// MaxTxRetries is how many times Watch runs a transaction when the watched keys keep changing, defaults to 10.
+	MaxTxRetries int
+
+	// MasterName enables the sentinel mode, the address of the master named MasterName is asked to the sentinels
+	// at SentinelAddrs instead of using Addr, and the connections follow the master when it fails over.
+	MasterName       string
+	SentinelAddrs    []string
+	SentinelPassword string
+
+	// ClusterAddrs enables the cluster mode, they are the addresses of some nodes used to discover the cluster.
+	// Commands are sent to the node serving the slot of their key, DB must be 0.
+	ClusterAddrs []string
+	// MaxRedirects is how many MOVED and ASK redirections a command follows in the cluster mode, defaults to 5.
+	MaxRedirects int
+
+	// Hooks are invoked before and after every command, e.g. Metrics and SlowLog.
+	Hooks []Hook
+
+	// Compression compresses the large values written by SetJSON, SetGob and SetValue.
+	// The compressed values are decompressed by the reads whatever the options.
+	Compression CompressionOptions
+}
+
+func (options *RedisOptions) setDefaults() {
+	if options.MaxIdle == 0 {
+		options.MaxIdle = 3
+	}
+	if options.IdleTimeout == 0 {
+		options.IdleTimeout = 240 * time.Second
+	}
+	if options.DialTimeout == 0 {
+		options.DialTimeout = time.Second
+	}
+	if options.MaxTxRetries <= 0 {
+		options.MaxTxRetries = 10
+	}
+	if options.MaxRedirects <= 0 {
+		options.MaxRedirects = 5
+	}
+	options.Serializer = serializerOrDefault(options.Serializer)
+	options.Compression.setDefaults()
 }
 
 // GetConn gets a connection
 func (o *RedisCacher) GetConn() redis.Conn {
-	conn := o.p.Get()
-	if o.dbIndex != 0 {
-		conn.Do("SELECT", o.dbIndex)
+	if o.cluster != nil {
+		return o.hook(context.Background(), o.cluster.conn(context.Background()))
+	}
+	return o.hook(context.Background(), o.p.Get())
+}
+
+// GetConnContext gets a connection, waiting for the pool no longer than the context allows.
+// The returned connection runs its commands within the context's deadline.
+func (o *RedisCacher) GetConnContext(ctx context.Context) (redis.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if o.cluster != nil {
+		return contextConn{o.hook(ctx, o.cluster.conn(ctx)), ctx}, nil
+	}
+	conn, err := o.p.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return contextConn{o.hook(ctx, conn), ctx}, nil
+}
+
+// hook wraps conn to report its commands to the hooks, if any.
+func (o *RedisCacher) hook(ctx context.Context, conn redis.Conn) redis.Conn {
+	if len(o.options.Hooks) == 0 {
+		return conn
+	}
+	return &hookConn{Conn: conn, ctx: ctx, hooks: o.options.Hooks}
+}
+
+// DoContext sends a command to redis and returns the reply.
+// It returns ctx.Err() as soon as the context is done, even if the reply hasn't arrived yet.
+func (o *RedisCacher) DoContext(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	return o.withConn(ctx, func(conn redis.Conn) (interface{}, error) {
+		return conn.Do(commandName, args...)
+	})
+}
+
+// RunScript evaluates the lua script with the keys and arguments, the script is sent with EVALSHA and falls back to EVAL.
+func (o *RedisCacher) RunScript(ctx context.Context, script *redis.Script, keysAndArgs ...interface{}) (interface{}, error) {
+	return o.withConn(ctx, func(conn redis.Conn) (interface{}, error) {
+		return script.Do(conn, keysAndArgs...)
+	})
+}
+
+// withConn runs fn with a connection from the pool, the connection is closed once fn returns.
+// If the context can be canceled, fn runs in its own goroutine so that withConn can return ctx.Err() without waiting for it.
+func (o *RedisCacher) withConn(ctx context.Context, fn func(conn redis.Conn) (interface{}, error)) (interface{}, error) {
+	conn, err := o.GetConnContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return runConn(ctx, conn, fn)
+}
+
+// runConn runs fn with conn like withConn.
+func runConn(ctx context.Context, conn redis.Conn, fn func(conn redis.Conn) (interface{}, error)) (interface{}, error) {
+	if ctx.Done() == nil {
+		defer conn.Close()
+		return fn(conn)
+	}
+
+	type result struct {
+		reply interface{}
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer conn.Close()
+		reply, err := fn(conn)
+		done <- result{reply, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, contextError(ctx, r.err)
+		}
+		return r.reply, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// contextError gets the error of a command run with ctx, a command which failed because ctx is done,
+// or timed out because of its deadline, reports ctx.Err() instead of the i/o error.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if _, ok := ctx.Deadline(); ok && isTimeout(err) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// contextConn is a connection which limits the time of its commands to the context's deadline,
+// and fails them once the context is done.
+type contextConn struct {
+	redis.Conn
+	ctx context.Context
+}
+
+func (c contextConn) timeout() (time.Duration, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	deadline, ok := c.ctx.Deadline()
+	if !ok {
+		return 0, nil
+	}
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		return 0, context.DeadlineExceeded
+	}
+	return timeout, nil
+}
+
+func (c contextConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	timeout, err := c.timeout()
+	if err != nil {
+		return nil, err
+	}
+	if timeout == 0 {
+		return c.Conn.Do(commandName, args...)
+	}
+	return redis.DoWithTimeout(c.Conn, timeout, commandName, args...)
+}
+
+func (c contextConn) Receive() (interface{}, error) {
+	timeout, err := c.timeout()
+	if err != nil {
+		return nil, err
+	}
+	if timeout == 0 {
+		return c.Conn.Receive()
 	}
-	return conn
+	return redis.ReceiveWithTimeout(c.Conn, timeout)
 }
 
-// Redis is the only one redis cacher, we don't support multiple intances of redis cacher for now.
+// Redis is the default redis cacher created by NewRedisCacher, use NewRedisCacherWithOptions to create more instances.
 var Redis *RedisCacher
 
-// NewRedisCacher creates a new redis cacher
+// NewRedisCacher creates the default redis cacher
 func NewRedisCacher(server, password string, dbIndex ...int) error {
 	// Has an existing pool, close it.
 	if Redis != nil {
 		Redis.ClosePool()
 	}
 
-	Redis = new(RedisCacher)
+	options := RedisOptions{Addr: server, Password: password}
 	if len(dbIndex) > 0 {
-		Redis.dbIndex = dbIndex[0]
-	}
-
-	if Redis.p == nil {
-		Redis.p = &redis.Pool{
-			MaxIdle:     3,
-			IdleTimeout: 240 * time.Second,
-			Dial: func() (redis.Conn, error) {
-				c, err := redis.Dial("tcp", server, redis.DialConnectTimeout(time.Second*1))
-				if err != nil {
-					return nil, err
-				}
-				if password != "" {
-					if _, err = c.Do("AUTH", password); err != nil {
-						c.Close()
-						return nil, err
-					}
-				}
-				return c, err
-			},
-			TestOnBorrow: func(c redis.Conn, t time.Time) error {
-				_, err := c.Do("PING")
+		options.DB = dbIndex[0]
+	}
+
+	Redis = newRedisCacher(options)
+	return Redis.ping()
+}
+
+// NewRedisCacherWithOptions creates a new redis cacher which is independent from the default one.
+func NewRedisCacherWithOptions(options RedisOptions) (*RedisCacher, error) {
+	o := newRedisCacher(options)
+	if err := o.ping(); err != nil {
+		o.ClosePool()
+		return nil, err
+	}
+	return o, nil
+}
+
+func newRedisCacher(options RedisOptions) *RedisCacher {
+	options.setDefaults()
+	o := &RedisCacher{options: options}
+	switch {
+	case len(options.ClusterAddrs) > 0:
+		o.cluster = newCluster(o)
+	case options.MasterName != "":
+		o.sentinel = newSentinel(o)
+		o.p = o.newPool(o.dial)
+	default:
+		o.p = o.newPool(o.dial)
+	}
+	return o
+}
+
+// newPool creates a connection pool with the options of the cacher.
+func (o *RedisCacher) newPool(dial func() (redis.Conn, error)) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     o.options.MaxIdle,
+		MaxActive:   o.options.MaxActive,
+		IdleTimeout: o.options.IdleTimeout,
+		Wait:        o.options.Wait,
+		Dial:        dial,
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			// The connections to a former master report an error in the sentinel mode.
+			if err := c.Err(); err != nil {
 				return err
-			},
+			}
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+}
+
+// dial creates a new connection to the redis server, the current master in the sentinel mode
+// or any node in the cluster mode.
+func (o *RedisCacher) dial() (redis.Conn, error) {
+	switch {
+	case o.cluster != nil:
+		return o.cluster.dial()
+	case o.sentinel != nil:
+		return o.sentinel.dial()
+	default:
+		return o.dialAddr(o.options.Addr)
+	}
+}
+
+// dialAddr creates a new connection to addr which is authenticated and has the database selected.
+func (o *RedisCacher) dialAddr(addr string) (redis.Conn, error) {
+	options := []redis.DialOption{
+		redis.DialConnectTimeout(o.options.DialTimeout),
+		redis.DialReadTimeout(o.options.ReadTimeout),
+		redis.DialWriteTimeout(o.options.WriteTimeout),
+	}
+	if o.options.TLSConfig != nil {
+		options = append(options, redis.DialUseTLS(true), redis.DialTLSConfig(o.options.TLSConfig))
+	}
+
+	c, err := redis.Dial("tcp", addr, options...)
+	if err != nil {
+		return nil, err
+	}
+	if err = o.setupConn(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// setupConn authenticates the connection, sets the client name and selects the database.
+func (o *RedisCacher) setupConn(c redis.Conn) (err error) {
+	if o.options.Password != "" {
+		if o.options.Username != "" {
+			_, err = c.Do("AUTH", o.options.Username, o.options.Password)
+		} else {
+			_, err = c.Do("AUTH", o.options.Password)
+		}
+		if err != nil {
+			return
+		}
+	}
+	if o.options.ClientName != "" {
+		if _, err = c.Do("CLIENT", "SETNAME", o.options.ClientName); err != nil {
+			return
 		}
 	}
-	return Redis.p.Get().Err()
+	if o.options.DB != 0 {
+		_, err = c.Do("SELECT", o.options.DB)
+	}
+	return
+}
+
+// ping checks that a connection can be made.
+func (o *RedisCacher) ping() error {
+	conn := o.GetConn()
+	defer conn.Close()
+	_, err := conn.Do("PING")
+	return err
 }
 
 // ClosePool closes the redis pool
@@ -70,43 +380,70 @@ func (o *RedisCacher) ClosePool() {
 	if o.p != nil {
 		o.p.Close()
 	}
+	if o.sentinel != nil {
+		o.sentinel.close()
+	}
+	if o.cluster != nil {
+		o.cluster.close()
+	}
+	// The blocking pool can't be created anymore once the cacher is closed.
+	o.blockingOnce.Do(func() {})
+	if o.blocking != nil {
+		o.blocking.Close()
+	}
 }
 
 // Set a key value pair, the value can be string, int64 and etc.
 func (o *RedisCacher) Set(key string, value interface{}, expiration ...interface{}) error {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	_, err := redisConnection.Do("SET", key, value)
+	return o.SetContext(context.Background(), key, value, expiration...)
+}
 
-	if err == nil && expiration != nil {
-		_, err = redisConnection.Do("EXPIRE", key, expiration[0])
-	}
+// SetContext sets a key value pair with context.
+func (o *RedisCacher) SetContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	_, err := o.withConn(ctx, func(conn redis.Conn) (interface{}, error) {
+		_, err := conn.Do("SET", key, value)
+		if err == nil && expiration != nil {
+			_, err = conn.Do("EXPIRE", key, expiration[0])
+		}
+		return nil, err
+	})
 	return err
 }
 
 // Expire sets a expiration time for key
 func (o *RedisCacher) Expire(key string, expiration int) error {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	_, err := redisConnection.Do("EXPIRE", key, expiration)
+	return o.ExpireContext(context.Background(), key, expiration)
+}
+
+// ExpireContext sets a expiration time for key with context.
+func (o *RedisCacher) ExpireContext(ctx context.Context, key string, expiration int) error {
+	_, err := o.DoContext(ctx, "EXPIRE", key, expiration)
 	return err
 }
 
 // TTL gets the remaining seconds for key
 func (o *RedisCacher) TTL(key string) (int, error) {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	return redis.Int(redisConnection.Do("TTL", key))
+	return o.TTLContext(context.Background(), key)
+}
+
+// TTLContext gets the remaining seconds for key with context.
+func (o *RedisCacher) TTLContext(ctx context.Context, key string) (int, error) {
+	return redis.Int(o.DoContext(ctx, "TTL", key))
 }
 
 // SetGob sets a key value pair, value will be gob encoded
 func (o *RedisCacher) SetGob(key string, value interface{}, expiration ...interface{}) error {
-	var buffer bytes.Buffer
-	enc := gob.NewEncoder(&buffer)
-	err := enc.Encode(map[interface{}]interface{}{"value": value})
+	return o.SetGobContext(context.Background(), key, value, expiration...)
+}
 
+// SetGobContext sets a key value pair with context, value will be gob encoded
+func (o *RedisCacher) SetGobContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	b, err := encodeGob(value)
 	if err == nil {
-		err = o.Set(key, buffer.Bytes(), expiration...)
+		b, err = o.compress(ctx, b)
+	}
+	if err == nil {
+		err = o.SetContext(ctx, key, b, expiration...)
 	}
 
 	return err
@@ -114,69 +451,164 @@ func (o *RedisCacher) SetGob(key string, value interface{}, expiration ...interf
 
 // GetGob gets a gob encoded value from key
 func (o *RedisCacher) GetGob(key string) (interface{}, error) {
-	b, err := o.GetBytes(key)
-	value := make(map[interface{}]interface{})
+	return o.GetGobContext(context.Background(), key)
+}
+
+// GetGobContext gets a gob encoded value from key with context.
+func (o *RedisCacher) GetGobContext(ctx context.Context, key string) (interface{}, error) {
+	b, err := o.GetBytesContext(ctx, key)
 	if err == nil {
-		buffer := bytes.NewBuffer(b)
-		dec := gob.NewDecoder(buffer)
-		err = dec.Decode(&value)
+		b, err = decompress(b)
+	}
+	if err != nil {
+		return nil, err
 	}
-	return value["value"], err
+	return decodeGob(b)
 }
 
 // SetJSON sets a key value pair, the value is a json
 func (o *RedisCacher) SetJSON(key string, value interface{}, expiration ...interface{}) error {
+	return o.SetJSONContext(context.Background(), key, value, expiration...)
+}
+
+// SetJSONContext sets a key value pair with context, the value is a json
+func (o *RedisCacher) SetJSONContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
 	str, err := json.Marshal(value)
 	if err == nil {
-		err = o.Set(key, str, expiration...)
+		str, err = o.compress(ctx, str)
+	}
+	if err == nil {
+		err = o.SetContext(ctx, key, str, expiration...)
 	}
 	return err
 }
 
 // GetJSON gets a json value from key
 func (o *RedisCacher) GetJSON(key string) (jsonBytes []byte, err error) {
-	return o.GetBytes(key)
+	return o.GetJSONContext(context.Background(), key)
+}
+
+// GetJSONContext gets a json value from key with context.
+func (o *RedisCacher) GetJSONContext(ctx context.Context, key string) (jsonBytes []byte, err error) {
+	jsonBytes, err = o.GetBytesContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(jsonBytes)
+}
+
+// SetValue sets a key value pair, the value is encoded by the configured serializer.
+// A ttl <= 0 means the key doesn't expire.
+func (o *RedisCacher) SetValue(key string, value interface{}, ttl time.Duration) error {
+	return o.SetValueContext(context.Background(), key, value, ttl)
+}
+
+// SetValueContext sets a key value pair with context, the value is encoded by the configured serializer.
+func (o *RedisCacher) SetValueContext(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	b, err := o.options.Serializer.Marshal(value)
+	if err == nil {
+		b, err = o.compress(ctx, b)
+	}
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		_, err = o.DoContext(ctx, "SET", key, b, "PX", durationMilliseconds(ttl))
+	} else {
+		_, err = o.DoContext(ctx, "SET", key, b)
+	}
+	return err
+}
+
+// GetInto gets a value set by SetValue and decodes it into dst, which must be a pointer.
+// It returns ErrCacheMiss if the key doesn't exist.
+func (o *RedisCacher) GetInto(key string, dst interface{}) error {
+	return o.GetIntoContext(context.Background(), key, dst)
+}
+
+// GetIntoContext gets a value set by SetValue with context and decodes it into dst.
+func (o *RedisCacher) GetIntoContext(ctx context.Context, key string, dst interface{}) error {
+	b, err := o.GetBytesContext(ctx, key)
+	if err == redis.ErrNil {
+		return ErrCacheMiss
+	} else if err == nil {
+		b, err = decompress(b)
+	}
+	if err != nil {
+		return err
+	}
+	return o.options.Serializer.Unmarshal(b, dst)
 }
 
 // GetString gets a string value from key
 func (o *RedisCacher) GetString(key string) (string, error) {
-	return redis.String(o.Get(key))
+	return o.GetStringContext(context.Background(), key)
+}
+
+// GetStringContext gets a string value from key with context.
+func (o *RedisCacher) GetStringContext(ctx context.Context, key string) (string, error) {
+	return redis.String(o.GetContext(ctx, key))
 }
 
 // GetBytes gets a []byte value from key
 func (o *RedisCacher) GetBytes(key string) ([]byte, error) {
-	return redis.Bytes(o.Get(key))
+	return o.GetBytesContext(context.Background(), key)
+}
+
+// GetBytesContext gets a []byte value from key with context.
+func (o *RedisCacher) GetBytesContext(ctx context.Context, key string) ([]byte, error) {
+	return redis.Bytes(o.GetContext(ctx, key))
 }
 
 // GetInt64 gets a int64 value from key
 func (o *RedisCacher) GetInt64(key string) (int64, error) {
-	return redis.Int64(o.Get(key))
+	return o.GetInt64Context(context.Background(), key)
+}
+
+// GetInt64Context gets a int64 value from key with context.
+func (o *RedisCacher) GetInt64Context(ctx context.Context, key string) (int64, error) {
+	return redis.Int64(o.GetContext(ctx, key))
 }
 
 // GetFloat64 gets a float64 value from key
 func (o *RedisCacher) GetFloat64(key string) (float64, error) {
-	return redis.Float64(o.Get(key))
+	return o.GetFloat64Context(context.Background(), key)
+}
+
+// GetFloat64Context gets a float64 value from key with context.
+func (o *RedisCacher) GetFloat64Context(ctx context.Context, key string) (float64, error) {
+	return redis.Float64(o.GetContext(ctx, key))
 }
 
 // Get a value from key
 func (o *RedisCacher) Get(key string) (interface{}, error) {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	return redisConnection.Do("GET", key)
+	return o.GetContext(context.Background(), key)
+}
+
+// GetContext gets a value from key with context.
+func (o *RedisCacher) GetContext(ctx context.Context, key string) (interface{}, error) {
+	return o.DoContext(ctx, "GET", key)
 }
 
 // Del a key
 func (o *RedisCacher) Del(key string) {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	redisConnection.Do("DEL", key)
+	o.DelContext(context.Background(), key)
+}
+
+// DelContext deletes a key with context.
+func (o *RedisCacher) DelContext(ctx context.Context, key string) error {
+	_, err := o.DoContext(ctx, "DEL", key)
+	return err
 }
 
 // Scan through the keys with given cursor, pattern and count
 func (o *RedisCacher) Scan(cursor int, count int, pattern string) (nextCursor int, keys []string, err error) {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	result, err := redis.Values(redisConnection.Do("SCAN", cursor, "MATCH", pattern, "COUNT", count))
+	return o.ScanContext(context.Background(), cursor, count, pattern)
+}
+
+// ScanContext scans through the keys with given cursor, pattern and count with context.
+func (o *RedisCacher) ScanContext(ctx context.Context, cursor int, count int, pattern string) (nextCursor int, keys []string, err error) {
+	result, err := redis.Values(o.DoContext(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", count))
 	if err != nil {
 		return
 	}
@@ -188,85 +620,130 @@ func (o *RedisCacher) Scan(cursor int, count int, pattern string) (nextCursor in
 
 // HSet sets a key:value in hash set.
 func (o *RedisCacher) HSet(hash, key string, value interface{}, expiration ...interface{}) error {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	_, err := redisConnection.Do("HSET", hash, key, value)
+	return o.HSetContext(context.Background(), hash, key, value, expiration...)
+}
 
-	if err == nil && expiration != nil {
-		_, err = redisConnection.Do("EXPIRE", key, expiration[0])
-	}
+// HSetContext sets a key:value in hash set with context.
+func (o *RedisCacher) HSetContext(ctx context.Context, hash, key string, value interface{}, expiration ...interface{}) error {
+	_, err := o.withConn(ctx, func(conn redis.Conn) (interface{}, error) {
+		_, err := conn.Do("HSET", hash, key, value)
+		if err == nil && expiration != nil {
+			_, err = conn.Do("EXPIRE", hash, expiration[0])
+		}
+		return nil, err
+	})
 	return err
 }
 
 // HGet gets a value from hash set
 func (o *RedisCacher) HGet(hash, key string) (interface{}, error) {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	return redisConnection.Do("HGET", hash, key)
+	return o.HGetContext(context.Background(), hash, key)
+}
+
+// HGetContext gets a value from hash set with context.
+func (o *RedisCacher) HGetContext(ctx context.Context, hash, key string) (interface{}, error) {
+	return o.DoContext(ctx, "HGET", hash, key)
 }
 
 // HGetAll gets all key:values for give hash
 func (o *RedisCacher) HGetAll(hash string) (interface{}, error) {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	return redisConnection.Do("HGETAll", hash)
+	return o.HGetAllContext(context.Background(), hash)
+}
+
+// HGetAllContext gets all key:values for give hash with context.
+func (o *RedisCacher) HGetAllContext(ctx context.Context, hash string) (interface{}, error) {
+	return o.DoContext(ctx, "HGETALL", hash)
 }
 
 // HDel deletes a given key in a hash
 func (o *RedisCacher) HDel(hash, key string) error {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	_, err := redisConnection.Do("HDEL", hash, key)
+	return o.HDelContext(context.Background(), hash, key)
+}
+
+// HDelContext deletes a given key in a hash with context.
+func (o *RedisCacher) HDelContext(ctx context.Context, hash, key string) error {
+	_, err := o.DoContext(ctx, "HDEL", hash, key)
 	return err
 }
 
 // HINCRBY increments a value for hash set by key.
 func (o *RedisCacher) HINCRBY(hash, key string, value interface{}) error {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	_, err := redisConnection.Do("HINCRBY", hash, key, value)
+	return o.HINCRBYContext(context.Background(), hash, key, value)
+}
+
+// HINCRBYContext increments a value for hash set by key with context.
+func (o *RedisCacher) HINCRBYContext(ctx context.Context, hash, key string, value interface{}) error {
+	_, err := o.DoContext(ctx, "HINCRBY", hash, key, value)
 	return err
 }
 
 // FlushAll flushes all keys in all db.
 func (o *RedisCacher) FlushAll() error {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	_, err := redisConnection.Do("FLUSHALL")
+	return o.FlushAllContext(context.Background())
+}
+
+// FlushAllContext flushes all keys in all db with context.
+func (o *RedisCacher) FlushAllContext(ctx context.Context) error {
+	_, err := o.DoContext(ctx, "FLUSHALL")
 	return err
 }
 
 // Flush flushes all keys in selected db.
 func (o *RedisCacher) Flush() error {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	_, err := redisConnection.Do("FLUSHDB")
+	return o.FlushContext(context.Background())
+}
+
+// FlushContext flushes all keys in selected db with context.
+func (o *RedisCacher) FlushContext(ctx context.Context) error {
+	_, err := o.DoContext(ctx, "FLUSHDB")
 	return err
 }
 
 // GetDBSize get the number of keys in the currently-selected database.
 func (o *RedisCacher) GetDBSize() (interface{}, error) {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
-	return redisConnection.Do("DBSIZE")
+	return o.GetDBSizeContext(context.Background())
+}
+
+// GetDBSizeContext get the number of keys in the currently-selected database with context.
+func (o *RedisCacher) GetDBSizeContext(ctx context.Context) (interface{}, error) {
+	return o.DoContext(ctx, "DBSIZE")
 }
 
 // MultipleGet gets the values for keys in bulk.
 func (o *RedisCacher) MultipleGet(keys ...string) (values []interface{}, err error) {
-	redisConnection := o.GetConn()
-	defer redisConnection.Close()
+	return o.MultipleGetContext(context.Background(), keys...)
+}
 
+// MultipleGetContext gets the values for keys in bulk with context.
+func (o *RedisCacher) MultipleGetContext(ctx context.Context, keys ...string) (values []interface{}, err error) {
 	args := make([]interface{}, len(keys))
 	for i := range keys {
 		args[i] = keys[i]
 	}
 
-	values, err = redis.Values(redisConnection.Do("MGET", args...))
+	values, err = redis.Values(o.DoContext(ctx, "MGET", args...))
 
 	return
 }
 
 // MultipleGetJSON gets the values for keys in bulk and return json bytes
 func (o *RedisCacher) MultipleGetJSON(keys ...string) (values [][]byte, err error) {
-	return redis.ByteSlices(o.MultipleGet(keys...))
+	return o.MultipleGetJSONContext(context.Background(), keys...)
+}
+
+// MultipleGetJSONContext gets the values for keys in bulk with context and return json bytes
+func (o *RedisCacher) MultipleGetJSONContext(ctx context.Context, keys ...string) (values [][]byte, err error) {
+	values, err = redis.ByteSlices(o.MultipleGetContext(ctx, keys...))
+	if err != nil {
+		return nil, err
+	}
+	for i, b := range values {
+		if b == nil {
+			continue
+		}
+		if values[i], err = decompress(b); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
 }
@@ -2,11 +2,13 @@ package cache
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/garyburd/redigo/redis"
 	. "github.com/smartystreets/goconvey/convey"
@@ -281,3 +283,72 @@ func TestRedis(t *testing.T) {
 		So(value, ShouldEqual, int64(0))
 	})
 }
+
+func TestRedisWithOptions(t *testing.T) {
+	Convey("Test Multiple Redis Cachers With Options\n", t, func() {
+		_, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6380"})
+		So(err, ShouldNotBeNil)
+
+		_, err = NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", Password: "WrongPassword"})
+		So(err, ShouldNotBeNil)
+
+		redis2, err := NewRedisCacherWithOptions(RedisOptions{
+			Addr:         "127.0.0.1:6379",
+			DB:           2,
+			MaxActive:    2,
+			Wait:         true,
+			ReadTimeout:  time.Second,
+			WriteTimeout: time.Second,
+		})
+		So(err, ShouldBeNil)
+		defer redis2.ClosePool()
+
+		redis3, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 3})
+		So(err, ShouldBeNil)
+		defer redis3.ClosePool()
+
+		So(redis2.Set("testKey", "2", 300), ShouldBeNil)
+		So(redis3.Set("testKey", "3", 300), ShouldBeNil)
+
+		value, err := redis2.GetString("testKey")
+		So(err, ShouldBeNil)
+		So(value, ShouldEqual, "2")
+		value, err = redis3.GetString("testKey")
+		So(err, ShouldBeNil)
+		So(value, ShouldEqual, "3")
+
+		So(redis2.Flush(), ShouldBeNil)
+		So(redis3.Flush(), ShouldBeNil)
+	})
+}
+
+func TestRedisContext(t *testing.T) {
+	Convey("Test Redis Cacher With Context\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+
+		ctx := context.Background()
+		So(cacher.SetContext(ctx, "testKey", "testValue", 300), ShouldBeNil)
+		value, err := cacher.GetStringContext(ctx, "testKey")
+		So(err, ShouldBeNil)
+		So(value, ShouldEqual, "testValue")
+
+		canceledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = cacher.GetContext(canceledCtx, "testKey")
+		So(err, ShouldEqual, context.Canceled)
+		So(cacher.DelContext(canceledCtx, "testKey"), ShouldEqual, context.Canceled)
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err = cacher.DoContext(timeoutCtx, "BLPOP", "emptyList", 2)
+		So(err, ShouldResemble, context.DeadlineExceeded)
+		So(time.Since(start), ShouldBeLessThan, time.Second)
+
+		So(cacher.DelContext(ctx, "testKey"), ShouldBeNil)
+		value, err = cacher.GetStringContext(ctx, "testKey")
+		So(err, ShouldEqual, redis.ErrNil)
+	})
+}
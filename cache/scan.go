@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// ScanOptions defines the options of a scan through the whole keyspace.
+type ScanOptions struct {
+	// Match is the pattern of the keys, defaults to "*".
+	Match string
+	// Type only returns the keys of this type, e.g. "string", "hash" or "zset", empty for all the keys.
+	Type string
+	// Count is the number of keys redis looks at per SCAN, defaults to 100.
+	Count int
+}
+
+func (options *ScanOptions) setDefaults() {
+	if options.Match == "" {
+		options.Match = "*"
+	}
+	if options.Count <= 0 {
+		options.Count = 100
+	}
+}
+
+// ScanEach walks through the whole keyspace and calls fn for every key, until fn returns an error or ctx is done.
+// As with SCAN, a key may be visited more than once and the keys changed during the scan may be missed.
+func (o *RedisCacher) ScanEach(ctx context.Context, options ScanOptions, fn func(key string) error) error {
+	options.setDefaults()
+	args := []interface{}{"MATCH", options.Match, "COUNT", options.Count}
+	if options.Type != "" {
+		args = append(args, "TYPE", options.Type)
+	}
+
+	cursor := 0
+	for {
+		result, err := redis.Values(o.DoContext(ctx, "SCAN", append([]interface{}{cursor}, args...)...))
+		if err != nil {
+			return err
+		}
+		if cursor, err = redis.Int(result[0], nil); err != nil {
+			return err
+		}
+		keys, err := redis.Strings(result[1], nil)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// ScanChannel walks through the whole keyspace like ScanEach and sends the keys on the returned channel.
+// The channel is closed at the end of the scan, then the error channel gets the result of the scan.
+// Cancel ctx to stop the scan early.
+func (o *RedisCacher) ScanChannel(ctx context.Context, options ScanOptions) (<-chan string, <-chan error) {
+	options.setDefaults()
+	keys := make(chan string, options.Count)
+	errs := make(chan error, 1)
+	go func() {
+		err := o.ScanEach(ctx, options, func(key string) error {
+			select {
+			case keys <- key:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(keys)
+		errs <- err
+	}()
+	return keys, errs
+}
+
+// DelKeys deletes the keys and returns how many existed.
+func (o *RedisCacher) DelKeys(keys ...string) (int, error) {
+	return o.DelKeysContext(context.Background(), keys...)
+}
+
+// DelKeysContext deletes the keys with context and returns how many existed.
+func (o *RedisCacher) DelKeysContext(ctx context.Context, keys ...string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	return redis.Int(o.DoContext(ctx, "DEL", redis.Args{}.AddFlat(keys)...))
+}
+
+// DeleteByPattern removes the keys matching pattern and returns how many were removed.
+// The keys are removed in batches with UNLINK, so that redis frees their memory in the background.
+func (o *RedisCacher) DeleteByPattern(pattern string) (int, error) {
+	return o.DeleteByPatternContext(context.Background(), pattern)
+}
+
+// DeleteByPatternContext removes the keys matching pattern with context and returns how many were removed.
+func (o *RedisCacher) DeleteByPatternContext(ctx context.Context, pattern string) (int, error) {
+	options := ScanOptions{Match: pattern}
+	options.setDefaults()
+
+	removed := 0
+	batch := make([]interface{}, 0, options.Count)
+	unlink := func() error {
+		n, err := redis.Int(o.DoContext(ctx, "UNLINK", batch...))
+		removed += n
+		batch = batch[:0]
+		return err
+	}
+
+	err := o.ScanEach(ctx, options, func(key string) error {
+		batch = append(batch, key)
+		if len(batch) < options.Count {
+			return nil
+		}
+		return unlink()
+	})
+	if err == nil && len(batch) > 0 {
+		err = unlink()
+	}
+	return removed, err
+}
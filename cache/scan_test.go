@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestScanAndDelete(t *testing.T) {
+	Convey("Test Scanning And Deleting Keys\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		for i := 0; i < 250; i++ {
+			So(cacher.Set(fmt.Sprintf("user:%d", i), i), ShouldBeNil)
+		}
+		for i := 0; i < 5; i++ {
+			So(cacher.HSet(fmt.Sprintf("user:profile:%d", i), "name", "alice"), ShouldBeNil)
+		}
+		So(cacher.Set("other", 1), ShouldBeNil)
+
+		Convey("ScanEach Should Walk The Whole Keyspace", func() {
+			seen := make(map[string]bool)
+			err := cacher.ScanEach(context.Background(), ScanOptions{Match: "user:*", Count: 10}, func(key string) error {
+				seen[key] = true
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(len(seen), ShouldEqual, 255)
+
+			var hashes []string
+			err = cacher.ScanEach(context.Background(), ScanOptions{Type: "hash"}, func(key string) error {
+				hashes = append(hashes, key)
+				return nil
+			})
+			So(err, ShouldBeNil)
+			sort.Strings(hashes)
+			So(hashes, ShouldResemble, []string{"user:profile:0", "user:profile:1", "user:profile:2", "user:profile:3", "user:profile:4"})
+		})
+
+		Convey("ScanEach Should Stop On Errors And Cancellation", func() {
+			stop := errors.New("stop")
+			n := 0
+			err := cacher.ScanEach(context.Background(), ScanOptions{}, func(key string) error {
+				n++
+				if n == 3 {
+					return stop
+				}
+				return nil
+			})
+			So(err, ShouldEqual, stop)
+			So(n, ShouldEqual, 3)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			n = 0
+			err = cacher.ScanEach(ctx, ScanOptions{}, func(key string) error {
+				n++
+				cancel()
+				return nil
+			})
+			So(err, ShouldEqual, context.Canceled)
+			So(n, ShouldEqual, 1)
+		})
+
+		Convey("ScanChannel Should Send Every Key", func() {
+			keys, errs := cacher.ScanChannel(context.Background(), ScanOptions{Match: "user:*", Type: "string"})
+			seen := make(map[string]bool)
+			for key := range keys {
+				seen[key] = true
+			}
+			So(<-errs, ShouldBeNil)
+			So(len(seen), ShouldEqual, 250)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			keys, errs = cacher.ScanChannel(ctx, ScanOptions{Count: 1})
+			<-keys
+			cancel()
+			for range keys {
+			}
+			So(<-errs, ShouldEqual, context.Canceled)
+		})
+
+		Convey("DeleteByPattern Should Remove The Matching Keys", func() {
+			n, err := cacher.DeleteByPattern("user:*")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 255)
+			So(scanAll(cacher, "*"), ShouldResemble, []string{"other"})
+
+			n, err = cacher.DeleteByPattern("user:*")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+		})
+
+		Convey("DelKeys Should Delete Several Keys", func() {
+			n, err := cacher.DelKeys("user:0", "user:1", "notExist")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			n, err = cacher.DelKeys()
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err = cacher.DelKeysContext(ctx, "user:2")
+			So(err, ShouldEqual, context.Canceled)
+		})
+	})
+}
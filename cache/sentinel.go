@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// errMasterChanged is reported by the connections to a former master, so that the pool discards them.
+var errMasterChanged = errors.New("cache: the master has changed")
+
+// sentinel finds the master with the redis sentinels and follows it when it fails over.
+// The master is asked to the sentinels when a connection is created,
+// and the sentinels' +switch-master notifications make the connections to the former master stale.
+type sentinel struct {
+	o           *RedisCacher
+	sentinels   []*RedisCacher
+	subscribers []*Subscriber
+
+	mu     sync.RWMutex
+	master string
+}
+
+func newSentinel(o *RedisCacher) *sentinel {
+	s := &sentinel{o: o}
+	for _, addr := range o.options.SentinelAddrs {
+		c := newRedisCacher(RedisOptions{
+			Addr:         addr,
+			Password:     o.options.SentinelPassword,
+			MaxIdle:      1,
+			DialTimeout:  o.options.DialTimeout,
+			ReadTimeout:  o.options.ReadTimeout,
+			WriteTimeout: o.options.WriteTimeout,
+			TLSConfig:    o.options.TLSConfig,
+		})
+		subscriber := c.NewSubscriber(0)
+		subscriber.Subscribe("+switch-master")
+		go s.watch(subscriber)
+		s.sentinels = append(s.sentinels, c)
+		s.subscribers = append(s.subscribers, subscriber)
+	}
+	return s
+}
+
+func (s *sentinel) close() {
+	for _, subscriber := range s.subscribers {
+		subscriber.Close()
+	}
+	for _, c := range s.sentinels {
+		c.ClosePool()
+	}
+}
+
+// watch follows the failovers, the messages are "<master name> <old ip> <old port> <new ip> <new port>".
+func (s *sentinel) watch(subscriber *Subscriber) {
+	for message := range subscriber.Messages() {
+		fields := strings.Fields(string(message.Data))
+		if len(fields) == 5 && fields[0] == s.o.options.MasterName {
+			s.setMaster(net.JoinHostPort(fields[3], fields[4]))
+		}
+	}
+}
+
+func (s *sentinel) setMaster(addr string) {
+	s.mu.Lock()
+	s.master = addr
+	s.mu.Unlock()
+}
+
+func (s *sentinel) currentMaster() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.master
+}
+
+// resolve asks the sentinels for the address of the master, the first sentinel which knows it wins.
+func (s *sentinel) resolve() (string, error) {
+	err := errors.New("cache: no sentinel address")
+	for _, c := range s.sentinels {
+		ctx, cancel := context.WithTimeout(context.Background(), c.options.DialTimeout+time.Second)
+		var values []string
+		values, err = redis.Strings(c.DoContext(ctx, "SENTINEL", "get-master-addr-by-name", s.o.options.MasterName))
+		cancel()
+		if err == redis.ErrNil {
+			err = fmt.Errorf("cache: master %s is unknown to sentinel %s", s.o.options.MasterName, c.options.Addr)
+		}
+		if err == nil && len(values) == 2 {
+			addr := net.JoinHostPort(values[0], values[1])
+			s.setMaster(addr)
+			return addr, nil
+		}
+	}
+	return "", err
+}
+
+// dial connects to the current master.
+func (s *sentinel) dial() (redis.Conn, error) {
+	addr, err := s.resolve()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.o.dialAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The sentinels may still report the former master in the middle of a failover.
+	// Servers which don't support ROLE are trusted.
+	if role, err := redis.Values(conn.Do("ROLE")); err == nil && len(role) > 0 {
+		if name, _ := redis.String(role[0], nil); name != "master" {
+			conn.Close()
+			return nil, fmt.Errorf("cache: %s is a %s instead of the master", addr, name)
+		}
+	}
+	return &sentinelConn{Conn: conn, addr: addr, sentinel: s}, nil
+}
+
+// sentinelConn is a connection to the master, it reports errMasterChanged once the master has failed over.
+type sentinelConn struct {
+	redis.Conn
+	addr     string
+	sentinel *sentinel
+}
+
+func (c *sentinelConn) Err() error {
+	if err := c.Conn.Err(); err != nil {
+		return err
+	}
+	if c.sentinel.currentMaster() != c.addr {
+		return errMasterChanged
+	}
+	return nil
+}
+
+// check asks the sentinels for the master again when a write is rejected because the server has become a replica.
+func (c *sentinelConn) check(err error) {
+	if e, ok := err.(redis.Error); ok && strings.HasPrefix(string(e), "READONLY") {
+		go c.sentinel.resolve()
+	}
+}
+
+func (c *sentinelConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Conn.Do(commandName, args...)
+	c.check(err)
+	return reply, err
+}
+
+func (c *sentinelConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := redis.DoWithTimeout(c.Conn, timeout, commandName, args...)
+	c.check(err)
+	return reply, err
+}
+
+func (c *sentinelConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	c.check(err)
+	return reply, err
+}
+
+func (c *sentinelConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	reply, err := redis.ReceiveWithTimeout(c.Conn, timeout)
+	c.check(err)
+	return reply, err
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cache/cachetest"
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeSentinel reports the master of "mymaster" and pushes +switch-master to its subscribers.
+type fakeSentinel struct {
+	*cachetest.ScriptedServer
+
+	mu          sync.Mutex
+	master      []string
+	subscribers []*cachetest.Conn
+}
+
+func newFakeSentinel(host, port string) (*fakeSentinel, error) {
+	s := &fakeSentinel{master: []string{host, port}}
+	server, err := cachetest.NewScriptedServer(s.handle)
+	s.ScriptedServer = server
+	return s, err
+}
+
+func (s *fakeSentinel) handle(c *cachetest.Conn, args []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		if len(args) > 1 {
+			c.Reply([]string{"pong", args[1]})
+		} else {
+			c.Reply(cachetest.Status("PONG"))
+		}
+	case "SENTINEL":
+		if len(args) == 3 && args[2] == "mymaster" {
+			c.Reply(s.master)
+		} else {
+			c.Reply(nil)
+		}
+	case "SUBSCRIBE":
+		s.subscribers = append(s.subscribers, c)
+		c.Reply([]interface{}{"subscribe", args[1], 1})
+	default:
+		c.Reply(redis.Error("ERR unknown command"))
+	}
+}
+
+func (s *fakeSentinel) subscriberCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscribers)
+}
+
+// failover switches the master and notifies the subscribers.
+func (s *fakeSentinel) failover(host, port string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	message := strings.Join([]string{"mymaster", s.master[0], s.master[1], host, port}, " ")
+	s.master = []string{host, port}
+	for _, c := range s.subscribers {
+		c.Reply([]string{"message", "+switch-master", message})
+	}
+}
+
+func TestSentinel(t *testing.T) {
+	Convey("Test Sentinel Mode\n", t, func() {
+		fake, err := newFakeSentinel("127.0.0.1", "6379")
+		So(err, ShouldBeNil)
+		defer fake.Close()
+
+		Convey("Unknown Masters Should Fail", func() {
+			_, err := NewRedisCacherWithOptions(RedisOptions{MasterName: "unknown", SentinelAddrs: []string{fake.Addr()}})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Connections Should Follow The Master", func() {
+			cacher, err := NewRedisCacherWithOptions(RedisOptions{
+				MasterName:    "mymaster",
+				SentinelAddrs: []string{"127.0.0.1:1", fake.Addr()},
+				DB:            2,
+			})
+			So(err, ShouldBeNil)
+			defer cacher.ClosePool()
+			defer cacher.Flush()
+
+			So(cacher.Set("testKey", "testValue"), ShouldBeNil)
+			So(cacher.sentinel.currentMaster(), ShouldEqual, "127.0.0.1:6379")
+
+			conn, err := cacher.sentinel.dial()
+			So(err, ShouldBeNil)
+			defer conn.Close()
+			So(conn.Err(), ShouldBeNil)
+
+			for i := 0; i < 100 && fake.subscriberCount() == 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			// The same redis is the new master under another name.
+			fake.failover("localhost", "6379")
+			for i := 0; i < 100 && cacher.sentinel.currentMaster() != "localhost:6379"; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			So(cacher.sentinel.currentMaster(), ShouldEqual, "localhost:6379")
+			So(conn.Err(), ShouldEqual, errMasterChanged)
+
+			value, err := redis.String(cacher.Get("testKey"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "testValue")
+		})
+	})
+}
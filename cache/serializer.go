@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+// ErrCacheMiss is returned by the typed getters when the key doesn't exist.
+var ErrCacheMiss = errors.New("cache: key not found")
+
+// Serializer defines how the typed API encodes values before caching them and decodes them back.
+type Serializer interface {
+	Marshal(value interface{}) ([]byte, error)
+	Unmarshal(data []byte, value interface{}) error
+}
+
+// GobSerializer serializes values with encoding/gob.
+// Unlike SetGob the value is not wrapped, so it must be decoded into the same concrete type.
+type GobSerializer struct{}
+
+// Marshal encodes the value with gob.
+func (GobSerializer) Marshal(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(value)
+	return buffer.Bytes(), err
+}
+
+// Unmarshal decodes the gob data into value.
+func (GobSerializer) Unmarshal(data []byte, value interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
+
+// JSONSerializer serializes values with encoding/json, it's the default serializer.
+type JSONSerializer struct{}
+
+// Marshal encodes the value with json.
+func (JSONSerializer) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Unmarshal decodes the json data into value.
+func (JSONSerializer) Unmarshal(data []byte, value interface{}) error {
+	return json.Unmarshal(data, value)
+}
+
+// MsgpackSerializer serializes values with MessagePack.
+type MsgpackSerializer struct{}
+
+// Marshal encodes the value with MessagePack.
+func (MsgpackSerializer) Marshal(value interface{}) ([]byte, error) {
+	return msgpack.Marshal(value)
+}
+
+// Unmarshal decodes the MessagePack data into value.
+func (MsgpackSerializer) Unmarshal(data []byte, value interface{}) error {
+	return msgpack.Unmarshal(data, value)
+}
+
+// durationMilliseconds converts a ttl to milliseconds, rounding up so that a positive ttl never becomes 0.
+func durationMilliseconds(ttl time.Duration) int64 {
+	return int64((ttl + time.Millisecond - 1) / time.Millisecond)
+}
+
+func serializerOrDefault(serializer Serializer) Serializer {
+	if serializer == nil {
+		return JSONSerializer{}
+	}
+	return serializer
+}
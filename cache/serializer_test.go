@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSerializer(t *testing.T) {
+	serializers := map[string]Serializer{
+		"Gob":     GobSerializer{},
+		"JSON":    JSONSerializer{},
+		"Msgpack": MsgpackSerializer{},
+	}
+
+	Convey("Test The Serializers\n", t, func() {
+		for name, serializer := range serializers {
+			Convey(name+" Should Round Trip Values", func() {
+				b, err := serializer.Marshal(&TestValues{"A", 1, int64(1)})
+				So(err, ShouldBeNil)
+				var testValues TestValues
+				So(serializer.Unmarshal(b, &testValues), ShouldBeNil)
+				So(testValues, ShouldResemble, TestValues{"A", 1, int64(1)})
+			})
+		}
+	})
+
+	Convey("Test The Typed API Of Memory Cacher\n", t, func() {
+		for name, serializer := range serializers {
+			Convey(name+" Should Be OK", func() {
+				memory := NewMemoryCacher(0, 0)
+				memory.Serializer = serializer
+
+				So(memory.SetValue("testKey", &TestValues{"A", 1, int64(1)}, time.Minute), ShouldBeNil)
+				var testValues TestValues
+				So(memory.GetInto("testKey", &testValues), ShouldBeNil)
+				So(testValues, ShouldResemble, TestValues{"A", 1, int64(1)})
+				ttl, _ := memory.TTL("testKey")
+				So(ttl, ShouldEqual, 60)
+
+				So(memory.GetInto("notExist", &testValues), ShouldEqual, ErrCacheMiss)
+			})
+		}
+	})
+
+	Convey("Test The Typed API Of Redis Cacher\n", t, func() {
+		for name, serializer := range serializers {
+			Convey(name+" Should Be OK", func() {
+				cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2, Serializer: serializer})
+				So(err, ShouldBeNil)
+				defer cacher.ClosePool()
+
+				So(cacher.SetValue("testKey", &TestValues{"A", 1, int64(1)}, 1500*time.Millisecond), ShouldBeNil)
+				var testValues TestValues
+				So(cacher.GetInto("testKey", &testValues), ShouldBeNil)
+				So(testValues, ShouldResemble, TestValues{"A", 1, int64(1)})
+				ttl, _ := cacher.TTL("testKey")
+				So(ttl, ShouldBeBetween, 0, 3)
+
+				So(cacher.GetInto("notExist", &testValues), ShouldEqual, ErrCacheMiss)
+				cacher.Del("testKey")
+			})
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+type contextKey struct{}
+
+// FromContext gets the session loaded by Middleware, nil if there's none.
+func FromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(contextKey{}).(*Session)
+	return s
+}
+
+// Middleware loads the session of the request from its cookie and puts it in the context of the request for the handler.
+// The session is saved and the cookie set when the handler starts writing the response, flushes it or hijacks the connection,
+// or when it returns without writing. The values changed later are saved when the handler returns, but the ID can't change
+// anymore since the cookie has been sent, SetUser and Regenerate then fail with ErrCommitted.
+// It responds 500 Internal Server Error if the session can't be loaded, or if it can't be saved before the response is written.
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id string
+		if cookie, err := r.Cookie(m.options.CookieName); err == nil {
+			id = cookie.Value
+		}
+		s, err := m.Load(id)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		sw := &responseWriter{ResponseWriter: w, manager: m, session: s, hadCookie: id != ""}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), contextKey{}, s)))
+		if !sw.committed {
+			sw.commit()
+		} else if s.modified || s.destroyed {
+			// The response has been written, so a failure can't be reported anymore.
+			m.Save(s)
+		}
+	})
+}
+
+// cookie gets the cookie holding the ID of a saved session.
+func (m *Manager) cookie(s *Session) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     m.options.CookieName,
+		Value:    s.id,
+		Path:     m.options.CookiePath,
+		Domain:   m.options.CookieDomain,
+		MaxAge:   m.ttl(),
+		Secure:   m.options.CookieSecure,
+		HttpOnly: true,
+		SameSite: m.options.CookieSameSite,
+	}
+	if s.destroyed || s.isNew {
+		cookie.Value = ""
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
+
+// responseWriter saves the session before the response is written.
+type responseWriter struct {
+	http.ResponseWriter
+	manager   *Manager
+	session   *Session
+	hadCookie bool
+
+	committed bool
+	err       error
+}
+
+// commit saves the session and sets the cookie, or responds 500 if the session can't be saved.
+func (w *responseWriter) commit() error {
+	if w.committed {
+		return w.err
+	}
+	w.committed = true
+	w.session.committed = true
+	if w.err = w.manager.Save(w.session); w.err != nil {
+		http.Error(w.ResponseWriter, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return w.err
+	}
+	// A session which isn't stored doesn't need a cookie, the one of an expired session is removed.
+	if !w.session.isNew || w.hadCookie {
+		http.SetCookie(w.ResponseWriter, w.manager.cookie(w.session))
+	}
+	return nil
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.commit() == nil {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if err := w.commit(); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush commits the session before flushing the response, e.g. for server-sent events.
+func (w *responseWriter) Flush() {
+	if w.commit() != nil {
+		return
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack commits the session before handing over the connection, e.g. for websockets.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if err := w.commit(); err != nil {
+		return nil, nil, err
+	}
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("session: the response writer doesn't support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap gets the wrapped response writer for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
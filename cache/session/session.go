@@ -0,0 +1,314 @@
+// Package session offers HTTP sessions stored in a cache.Cacher, with sliding expiration, flash values
+// and the invalidation of all the sessions of a user.
+package session
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cache"
+	"github.com/WUMUXIAN/go-common-utils/cryptowrapper"
+	"github.com/garyburd/redigo/redis"
+)
+
+// ErrCommitted is returned when the ID of a session is changed after Middleware has sent its cookie,
+// the new ID couldn't reach the client anymore.
+var ErrCommitted = errors.New("session: the cookie has been sent, the session ID can't be changed anymore")
+
+// Options defines the options of a session manager.
+type Options struct {
+	// TTL is how long a session lives after its last request, defaults to 30 minutes.
+	TTL time.Duration
+	// KeyPrefix is prepended to the session IDs to get their keys, defaults to "session:".
+	// The generations of the users are stored in the hash "<KeyPrefix>users".
+	KeyPrefix string
+
+	// CookieName is the name of the cookie holding the session ID, defaults to "session".
+	CookieName string
+	// CookiePath defaults to "/".
+	CookiePath   string
+	CookieDomain string
+	// CookieSecure restricts the cookie to HTTPS, it should be set in production.
+	CookieSecure bool
+	// CookieSameSite defaults to http.SameSiteLaxMode.
+	CookieSameSite http.SameSite
+}
+
+func (options *Options) setDefaults() {
+	if options.TTL <= 0 {
+		options.TTL = 30 * time.Minute
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = "session:"
+	}
+	if options.CookieName == "" {
+		options.CookieName = "session"
+	}
+	if options.CookiePath == "" {
+		options.CookiePath = "/"
+	}
+	if options.CookieSameSite == 0 {
+		options.CookieSameSite = http.SameSiteLaxMode
+	}
+}
+
+// record is a session as it's stored.
+type record struct {
+	UserID     string                     `json:"user_id,omitempty"`
+	Generation int64                      `json:"generation,omitempty"`
+	Values     map[string]json.RawMessage `json:"values,omitempty"`
+	Flashes    map[string]json.RawMessage `json:"flashes,omitempty"`
+}
+
+// Session is the session of a client, it's not safe for concurrent use.
+// The values are json encoded, they are saved by Manager.Save.
+type Session struct {
+	id     string
+	record record
+
+	isNew bool
+	// previousID is the ID to delete on save once the ID has been regenerated.
+	previousID string
+	// userChanged tells that the generation of the user must be read on save.
+	userChanged bool
+	modified    bool
+	destroyed   bool
+	// committed tells that Middleware has sent the cookie, so the ID can't change anymore.
+	committed bool
+}
+
+// newID generates a session ID from 32 random bytes.
+func newID() string {
+	return base64.RawURLEncoding.EncodeToString(cryptowrapper.RandBytes(32))
+}
+
+// validID tells whether id has the format of the generated IDs, so that no other key is looked up with it.
+func validID(id string) bool {
+	if len(id) != base64.RawURLEncoding.EncodedLen(32) {
+		return false
+	}
+	for _, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func newSession() *Session {
+	return &Session{id: newID(), isNew: true}
+}
+
+// ID gets the ID of the session.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// IsNew tells whether the session has been created by this request.
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// UserID gets the user the session belongs to, empty if none.
+func (s *Session) UserID() string {
+	return s.record.UserID
+}
+
+// SetUser binds the session to a user, e.g. on login, or unbinds it with an empty userID.
+// The ID is regenerated since the privileges change, so it fails with ErrCommitted once the response has started.
+func (s *Session) SetUser(userID string) error {
+	if s.committed {
+		return ErrCommitted
+	}
+	s.record.UserID = userID
+	s.userChanged = true
+	return s.Regenerate()
+}
+
+// Regenerate gives the session a new ID and keeps its values, it should be called whenever the privileges change
+// so that an ID known before can't be used anymore. The previous ID is deleted on save.
+// It fails with ErrCommitted once Middleware has sent the cookie, the client would keep the previous ID.
+func (s *Session) Regenerate() error {
+	if s.committed {
+		return ErrCommitted
+	}
+	if s.previousID == "" && !s.isNew {
+		s.previousID = s.id
+	}
+	s.id = newID()
+	s.modified = true
+	return nil
+}
+
+// Destroy deletes the session on save, e.g. on logout.
+func (s *Session) Destroy() {
+	s.destroyed = true
+}
+
+// Set sets a value, it's json encoded.
+func (s *Session) Set(key string, value interface{}) error {
+	return s.set(&s.record.Values, key, value)
+}
+
+// Get decodes the value of key into dst, it returns false if there's no value.
+func (s *Session) Get(key string, dst interface{}) (bool, error) {
+	b, ok := s.record.Values[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(b, dst)
+}
+
+// Delete deletes the value of key.
+func (s *Session) Delete(key string) {
+	if _, ok := s.record.Values[key]; ok {
+		delete(s.record.Values, key)
+		s.modified = true
+	}
+}
+
+// SetFlash sets a value which is deleted once it has been read by Flash, e.g. a message for the next page.
+func (s *Session) SetFlash(key string, value interface{}) error {
+	return s.set(&s.record.Flashes, key, value)
+}
+
+// Flash decodes the flash value of key into dst and deletes it, it returns false if there's no value.
+func (s *Session) Flash(key string, dst interface{}) (bool, error) {
+	b, ok := s.record.Flashes[key]
+	if !ok {
+		return false, nil
+	}
+	delete(s.record.Flashes, key)
+	s.modified = true
+	return true, json.Unmarshal(b, dst)
+}
+
+func (s *Session) set(values *map[string]json.RawMessage, key string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if *values == nil {
+		*values = make(map[string]json.RawMessage)
+	}
+	(*values)[key] = b
+	s.modified = true
+	return nil
+}
+
+// Manager loads and saves the sessions.
+type Manager struct {
+	cacher  cache.Cacher
+	options Options
+	users   string
+}
+
+// NewManager creates a session manager storing the sessions in cacher.
+func NewManager(cacher cache.Cacher, options Options) *Manager {
+	options.setDefaults()
+	return &Manager{cacher: cacher, options: options, users: options.KeyPrefix + "users"}
+}
+
+func (m *Manager) key(id string) string {
+	return m.options.KeyPrefix + id
+}
+
+// ttl gets the TTL in seconds as the cachers take it.
+func (m *Manager) ttl() int {
+	seconds := int(m.options.TTL / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// generation gets the generation of a user, it's bumped by InvalidateUser.
+func (m *Manager) generation(userID string) (int64, error) {
+	reply, err := m.cacher.HGet(m.users, userID)
+	if reply == nil || err != nil {
+		return 0, err
+	}
+	return redis.Int64(reply, nil)
+}
+
+// Load loads the session with the given ID. A new session is returned if the ID is empty, malformed or unknown,
+// if the session has expired, or if the sessions of its user have been invalidated.
+func (m *Manager) Load(id string) (*Session, error) {
+	if !validID(id) {
+		return newSession(), nil
+	}
+	b, err := m.cacher.GetJSON(m.key(id))
+	if err == redis.ErrNil {
+		return newSession(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{id: id}
+	if err := json.Unmarshal(b, &s.record); err != nil {
+		return nil, err
+	}
+	if s.record.UserID != "" {
+		generation, err := m.generation(s.record.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if generation != s.record.Generation {
+			m.cacher.Del(m.key(id))
+			return newSession(), nil
+		}
+	}
+	return s, nil
+}
+
+// Save saves the session and extends its expiration. A new session without any value isn't stored.
+func (m *Manager) Save(s *Session) error {
+	if s.destroyed {
+		m.deletePrevious(s)
+		m.cacher.Del(m.key(s.id))
+		return nil
+	}
+	if !s.modified {
+		if s.isNew {
+			return nil
+		}
+		return m.cacher.Expire(m.key(s.id), m.ttl())
+	}
+
+	// The generation is only read when the user changes, so that a session loaded before its user
+	// has been invalidated isn't saved with the new generation.
+	if s.userChanged {
+		s.record.Generation = 0
+		if s.record.UserID != "" {
+			generation, err := m.generation(s.record.UserID)
+			if err != nil {
+				return err
+			}
+			s.record.Generation = generation
+		}
+	}
+	if err := m.cacher.SetJSON(m.key(s.id), s.record, m.ttl()); err != nil {
+		return err
+	}
+	m.deletePrevious(s)
+	s.isNew, s.modified, s.userChanged = false, false, false
+	return nil
+}
+
+// deletePrevious deletes the session stored under the ID the session had before it was regenerated.
+func (m *Manager) deletePrevious(s *Session) {
+	if s.previousID != "" {
+		m.cacher.Del(m.key(s.previousID))
+		s.previousID = ""
+	}
+}
+
+// InvalidateUser invalidates all the sessions of a user, e.g. after a password change.
+// The sessions are dropped when they are loaded next.
+func (m *Manager) InvalidateUser(userID string) error {
+	return m.cacher.HINCRBY(m.users, userID, 1)
+}
@@ -0,0 +1,242 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cache"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSession(t *testing.T) {
+	Convey("Test The Sessions\n", t, func() {
+		cacher, err := cache.NewRedisCacherWithOptions(cache.RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		manager := NewManager(cacher, Options{TTL: time.Minute})
+
+		Convey("Sessions Should Be Saved And Loaded", func() {
+			s, err := manager.Load("")
+			So(err, ShouldBeNil)
+			So(s.IsNew(), ShouldBeTrue)
+			So(len(s.ID()), ShouldEqual, 43)
+			So(manager.Save(s), ShouldBeNil)
+			reply, _ := cacher.Get("session:" + s.ID())
+			So(reply, ShouldBeNil)
+
+			So(s.Set("cart", []int{1, 2}), ShouldBeNil)
+			So(s.SetFlash("message", "saved"), ShouldBeNil)
+			So(manager.Save(s), ShouldBeNil)
+			ttl, _ := cacher.TTL("session:" + s.ID())
+			So(ttl, ShouldBeBetweenOrEqual, 59, 60)
+
+			loaded, err := manager.Load(s.ID())
+			So(err, ShouldBeNil)
+			So(loaded.IsNew(), ShouldBeFalse)
+			var cart []int
+			found, err := loaded.Get("cart", &cart)
+			So(found, ShouldBeTrue)
+			So(err, ShouldBeNil)
+			So(cart, ShouldResemble, []int{1, 2})
+			var message string
+			found, _ = loaded.Flash("message", &message)
+			So(found, ShouldBeTrue)
+			So(message, ShouldEqual, "saved")
+			So(manager.Save(loaded), ShouldBeNil)
+
+			loaded, _ = manager.Load(s.ID())
+			found, _ = loaded.Flash("message", &message)
+			So(found, ShouldBeFalse)
+			loaded.Delete("cart")
+			found, _ = loaded.Get("cart", &cart)
+			So(found, ShouldBeFalse)
+
+			unknown, err := manager.Load("unknown")
+			So(err, ShouldBeNil)
+			So(unknown.IsNew(), ShouldBeTrue)
+			So(unknown.ID(), ShouldNotEqual, "unknown")
+
+			So(cacher.HSet("session:users", "user", 1), ShouldBeNil)
+			malformed, err := manager.Load("users")
+			So(err, ShouldBeNil)
+			So(malformed.IsNew(), ShouldBeTrue)
+			malformed, err = manager.Load(s.ID()[1:] + "!")
+			So(err, ShouldBeNil)
+			So(malformed.IsNew(), ShouldBeTrue)
+		})
+
+		Convey("The Expiration Should Slide", func() {
+			s, _ := manager.Load("")
+			s.Set("key", "value")
+			So(manager.Save(s), ShouldBeNil)
+			So(cacher.Expire("session:"+s.ID(), 10), ShouldBeNil)
+
+			loaded, _ := manager.Load(s.ID())
+			So(manager.Save(loaded), ShouldBeNil)
+			ttl, _ := cacher.TTL("session:" + s.ID())
+			So(ttl, ShouldBeBetweenOrEqual, 59, 60)
+		})
+
+		Convey("The ID Should Be Regenerated On Login And The Session Destroyed On Logout", func() {
+			s, _ := manager.Load("")
+			s.Set("key", "value")
+			manager.Save(s)
+			anonymousID := s.ID()
+
+			s, _ = manager.Load(anonymousID)
+			s.SetUser("alice")
+			So(s.ID(), ShouldNotEqual, anonymousID)
+			So(manager.Save(s), ShouldBeNil)
+			old, _ := manager.Load(anonymousID)
+			So(old.IsNew(), ShouldBeTrue)
+			loaded, _ := manager.Load(s.ID())
+			So(loaded.UserID(), ShouldEqual, "alice")
+			var value string
+			loaded.Get("key", &value)
+			So(value, ShouldEqual, "value")
+
+			loaded.Destroy()
+			So(manager.Save(loaded), ShouldBeNil)
+			loaded, _ = manager.Load(s.ID())
+			So(loaded.IsNew(), ShouldBeTrue)
+		})
+
+		Convey("The Sessions Of A User Should Be Invalidated", func() {
+			var ids []string
+			for i := 0; i < 2; i++ {
+				s, _ := manager.Load("")
+				s.SetUser("alice")
+				So(manager.Save(s), ShouldBeNil)
+				ids = append(ids, s.ID())
+			}
+			bob, _ := manager.Load("")
+			bob.SetUser("bob")
+			manager.Save(bob)
+
+			// A session loaded before the invalidation isn't saved with the new generation.
+			pending, _ := manager.Load(ids[0])
+			So(manager.InvalidateUser("alice"), ShouldBeNil)
+			pending.Set("key", "value")
+			So(manager.Save(pending), ShouldBeNil)
+
+			for _, id := range ids {
+				s, err := manager.Load(id)
+				So(err, ShouldBeNil)
+				So(s.IsNew(), ShouldBeTrue)
+			}
+			s, _ := manager.Load(bob.ID())
+			So(s.UserID(), ShouldEqual, "bob")
+
+			s, _ = manager.Load("")
+			s.SetUser("alice")
+			manager.Save(s)
+			s, _ = manager.Load(s.ID())
+			So(s.UserID(), ShouldEqual, "alice")
+		})
+
+		Convey("The Middleware Should Load And Save The Session", func() {
+			handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				s := FromContext(r.Context())
+				switch r.URL.Path {
+				case "/login":
+					s.SetUser("alice")
+					s.SetFlash("message", "welcome")
+				case "/logout":
+					s.Destroy()
+				default:
+					var message string
+					s.Flash("message", &message)
+					w.Write([]byte(message))
+				}
+				w.Write([]byte(s.UserID()))
+			}))
+			serve := func(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+				request := httptest.NewRequest("GET", path, nil)
+				for _, cookie := range cookies {
+					request.AddCookie(cookie)
+				}
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, request)
+				return recorder
+			}
+
+			recorder := serve("/")
+			So(recorder.Body.String(), ShouldEqual, "")
+			So(recorder.Result().Cookies(), ShouldBeEmpty)
+
+			recorder = serve("/login")
+			So(recorder.Body.String(), ShouldEqual, "alice")
+			cookies := recorder.Result().Cookies()
+			So(len(cookies), ShouldEqual, 1)
+			So(cookies[0].Name, ShouldEqual, "session")
+			So(cookies[0].HttpOnly, ShouldBeTrue)
+			So(cookies[0].MaxAge, ShouldEqual, 60)
+
+			recorder = serve("/", cookies[0])
+			So(recorder.Body.String(), ShouldEqual, "welcomealice")
+			recorder = serve("/", cookies[0])
+			So(recorder.Body.String(), ShouldEqual, "alice")
+			So(recorder.Result().Cookies()[0].Value, ShouldEqual, cookies[0].Value)
+
+			So(manager.InvalidateUser("alice"), ShouldBeNil)
+			recorder = serve("/", cookies[0])
+			So(recorder.Body.String(), ShouldEqual, "")
+			So(recorder.Result().Cookies()[0].MaxAge, ShouldEqual, -1)
+
+			recorder = serve("/login")
+			cookies = recorder.Result().Cookies()
+			recorder = serve("/logout", cookies[0])
+			So(recorder.Result().Cookies()[0].MaxAge, ShouldEqual, -1)
+			s, _ := manager.Load(cookies[0].Value)
+			So(s.IsNew(), ShouldBeTrue)
+		})
+
+		Convey("The ID Should Not Change Once The Cookie Has Been Sent", func() {
+			handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				s := FromContext(r.Context())
+				if r.URL.Path == "/login" {
+					s.SetUser("alice")
+					return
+				}
+				s.Set("visited", true)
+				w.(http.Flusher).Flush()
+				if err := s.SetUser("bob"); err != nil {
+					w.Write([]byte(err.Error()))
+				}
+			}))
+			login := httptest.NewRecorder()
+			handler.ServeHTTP(login, httptest.NewRequest("GET", "/login", nil))
+			cookie := login.Result().Cookies()[0]
+
+			request := httptest.NewRequest("GET", "/", nil)
+			request.AddCookie(cookie)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			So(recorder.Flushed, ShouldBeTrue)
+			So(recorder.Body.String(), ShouldEqual, ErrCommitted.Error())
+			So(recorder.Result().Cookies()[0].Value, ShouldEqual, cookie.Value)
+
+			s, err := manager.Load(cookie.Value)
+			So(err, ShouldBeNil)
+			So(s.UserID(), ShouldEqual, "alice")
+			var visited bool
+			found, _ := s.Get("visited", &visited)
+			So(found, ShouldBeTrue)
+		})
+
+		Convey("Any Cacher Should Store The Sessions", func() {
+			memory := NewManager(cache.NewMemoryCacher(100, 0), Options{})
+			s, _ := memory.Load("")
+			s.SetUser("alice")
+			So(memory.Save(s), ShouldBeNil)
+			So(memory.InvalidateUser("alice"), ShouldBeNil)
+			loaded, err := memory.Load(s.ID())
+			So(err, ShouldBeNil)
+			So(loaded.IsNew(), ShouldBeTrue)
+		})
+	})
+}
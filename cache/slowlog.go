@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// SlowLog is a Hook which logs the commands taking longer than Threshold.
+type SlowLog struct {
+	Threshold time.Duration
+	// Logger is the logger to write to, the standard logger if nil.
+	Logger *log.Logger
+}
+
+// NewSlowLog creates a slow command logger, logger can be nil to use the standard logger.
+func NewSlowLog(threshold time.Duration, logger *log.Logger) *SlowLog {
+	return &SlowLog{Threshold: threshold, Logger: logger}
+}
+
+// BeforeCommand implements Hook.
+func (l *SlowLog) BeforeCommand(ctx context.Context, cmd *CommandInfo) context.Context {
+	return ctx
+}
+
+// AfterCommand implements Hook.
+func (l *SlowLog) AfterCommand(ctx context.Context, cmd *CommandInfo) {
+	if cmd.Duration < l.Threshold {
+		return
+	}
+	printf := log.Printf
+	if l.Logger != nil {
+		printf = l.Logger.Printf
+	}
+	if cmd.Err != nil {
+		printf("cache: slow command %s %s took %v: %v", cmd.Name, cmd.Key, cmd.Duration, cmd.Err)
+		return
+	}
+	printf("cache: slow command %s %s took %v", cmd.Name, cmd.Key, cmd.Duration)
+}
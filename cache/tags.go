@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// tagPrefix is prepended to a tag to get the key of the set of the keys tagged with it.
+const tagPrefix = "tag:"
+
+// tagPruneCount is how many members of a tag set SetWithTags checks for expired keys.
+const tagPruneCount = 5
+
+var (
+	// setWithTagsScript sets KEYS[1] to ARGV[1] for ARGV[2] milliseconds, 0 for no expiration, and adds it to the tag sets KEYS[2:].
+	// A tag set lives as long as its longest lived key, and a few of its members are checked so that the expired keys are pruned.
+	setWithTagsScript = redis.NewScript(-1, `
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ttl)
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+for i = 2, #KEYS do
+	for _, member in ipairs(redis.call("SRANDMEMBER", KEYS[i], ARGV[3])) do
+		if redis.call("EXISTS", member) == 0 then
+			redis.call("SREM", KEYS[i], member)
+		end
+	end
+	local existed = redis.call("EXISTS", KEYS[i])
+	redis.call("SADD", KEYS[i], KEYS[1])
+	if ttl == 0 then
+		redis.call("PERSIST", KEYS[i])
+	else
+		local pttl = redis.call("PTTL", KEYS[i])
+		if existed == 0 or (pttl >= 0 and pttl < ttl) then
+			redis.call("PEXPIRE", KEYS[i], ttl)
+		end
+	end
+end
+return 1`)
+	// invalidateTagsScript deletes the keys of the tag sets KEYS and the sets, it returns the number of keys deleted.
+	invalidateTagsScript = redis.NewScript(-1, `
+local deleted = 0
+for _, tag in ipairs(KEYS) do
+	local members = redis.call("SMEMBERS", tag)
+	for i = 1, #members, 1000 do
+		deleted = deleted + redis.call("DEL", unpack(members, i, math.min(i + 999, #members)))
+	end
+	redis.call("DEL", tag)
+end
+return deleted`)
+)
+
+// SetWithTags sets a key value pair like SetValue and tags the key, so that InvalidateTags deletes it when one of its tags is invalidated.
+// The keys tagged with a tag are stored in the set "tag:<tag>", which expires with its longest lived key.
+// The members left by expired keys are pruned lazily by the later calls. In the cluster mode, the key and its tag sets must be in the same slot.
+func (o *RedisCacher) SetWithTags(key string, value interface{}, ttl time.Duration, tags ...string) error {
+	return o.SetWithTagsContext(context.Background(), key, value, ttl, tags...)
+}
+
+// SetWithTagsContext sets a key value pair and tags the key with context.
+func (o *RedisCacher) SetWithTagsContext(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error {
+	b, err := o.options.Serializer.Marshal(value)
+	if err == nil {
+		b, err = o.compress(ctx, b)
+	}
+	if err != nil {
+		return err
+	}
+
+	args := redis.Args{1 + len(tags), key}
+	for _, tag := range tags {
+		args = append(args, tagPrefix+tag)
+	}
+	milliseconds := int64(0)
+	if ttl > 0 {
+		milliseconds = durationMilliseconds(ttl)
+	}
+	args = append(args, b, milliseconds, tagPruneCount)
+	_, err = o.RunScript(ctx, setWithTagsScript, args...)
+	return err
+}
+
+// InvalidateTags deletes atomically all the keys tagged with any of tags, and the tags, it returns the number of keys deleted.
+func (o *RedisCacher) InvalidateTags(tags ...string) (int, error) {
+	return o.InvalidateTagsContext(context.Background(), tags...)
+}
+
+// InvalidateTagsContext deletes all the keys tagged with any of tags with context.
+func (o *RedisCacher) InvalidateTagsContext(ctx context.Context, tags ...string) (int, error) {
+	if len(tags) == 0 {
+		return 0, nil
+	}
+	args := redis.Args{len(tags)}
+	for _, tag := range tags {
+		args = append(args, tagPrefix+tag)
+	}
+	return redis.Int(o.RunScript(ctx, invalidateTagsScript, args...))
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTags(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Test The Tag Based Invalidation\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		Convey("The Tagged Keys Should Be Deleted With Their Tags", func() {
+			So(cacher.SetWithTags("orders:1", []int{1, 2}, time.Minute, "user:1", "product:1"), ShouldBeNil)
+			So(cacher.SetWithTags("orders:2", []int{3}, 0, "user:2", "product:1"), ShouldBeNil)
+			So(cacher.SetWithTags("orders:3", []int{4}, time.Minute, "user:2"), ShouldBeNil)
+
+			var orders []int
+			So(cacher.GetInto("orders:1", &orders), ShouldBeNil)
+			So(orders, ShouldResemble, []int{1, 2})
+			members, err := redis.Strings(cacher.DoContext(ctx, "SMEMBERS", "tag:product:1"))
+			So(err, ShouldBeNil)
+			So(len(members), ShouldEqual, 2)
+
+			n, err := cacher.InvalidateTags("product:1")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			So(cacher.GetInto("orders:1", &orders), ShouldEqual, ErrCacheMiss)
+			So(cacher.GetInto("orders:3", &orders), ShouldBeNil)
+			exists, _ := redis.Int(cacher.DoContext(ctx, "EXISTS", "tag:product:1"))
+			So(exists, ShouldEqual, 0)
+
+			n, err = cacher.InvalidateTags("user:1", "user:2", "notExist")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			n, _ = cacher.InvalidateTags()
+			So(n, ShouldEqual, 0)
+		})
+
+		Convey("The Tag Sets Should Live As Long As Their Keys", func() {
+			So(cacher.SetWithTags("key1", 1, time.Minute, "tag"), ShouldBeNil)
+			ttl, _ := cacher.TTL("tag:tag")
+			So(ttl, ShouldBeBetweenOrEqual, 59, 60)
+			So(cacher.SetWithTags("key2", 2, time.Hour, "tag"), ShouldBeNil)
+			ttl, _ = cacher.TTL("tag:tag")
+			So(ttl, ShouldBeBetweenOrEqual, 3599, 3600)
+			So(cacher.SetWithTags("key3", 3, time.Second, "tag"), ShouldBeNil)
+			ttl, _ = cacher.TTL("tag:tag")
+			So(ttl, ShouldBeBetweenOrEqual, 3599, 3600)
+			So(cacher.SetWithTags("key4", 4, 0, "tag"), ShouldBeNil)
+			ttl, _ = cacher.TTL("tag:tag")
+			So(ttl, ShouldEqual, -1)
+		})
+
+		Convey("The Members Of Expired Keys Should Be Pruned", func() {
+			for i := 0; i < 3; i++ {
+				So(cacher.SetWithTags(fmt.Sprint("expired", i), i, time.Hour, "tag"), ShouldBeNil)
+				cacher.Del(fmt.Sprint("expired", i))
+			}
+			So(cacher.SetWithTags("key", 1, time.Hour, "tag"), ShouldBeNil)
+			members, err := redis.Strings(cacher.DoContext(ctx, "SMEMBERS", "tag:tag"))
+			So(err, ShouldBeNil)
+			So(members, ShouldResemble, []string{"key"})
+		})
+	})
+}
@@ -0,0 +1,436 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WUMUXIAN/go-common-utils/cryptowrapper"
+	"github.com/garyburd/redigo/redis"
+)
+
+// TieredOptions defines the options of a tiered cacher.
+type TieredOptions struct {
+	// MaxEntries is the number of keys kept in the local tier, defaults to 10000.
+	MaxEntries int
+	// LocalTTL is how long a value is kept in the local tier, defaults to 10 seconds.
+	// It bounds how stale a value can be if an invalidation is lost, e.g. while the subscriber reconnects.
+	LocalTTL time.Duration
+	// Channel is the redis channel the invalidations are published on, defaults to "cache:invalidations".
+	// The tiered cachers sharing a redis must use the same channel.
+	Channel string
+}
+
+func (options *TieredOptions) setDefaults() {
+	if options.MaxEntries <= 0 {
+		options.MaxEntries = 10000
+	}
+	if options.LocalTTL <= 0 {
+		options.LocalTTL = 10 * time.Second
+	}
+	if options.Channel == "" {
+		options.Channel = "cache:invalidations"
+	}
+}
+
+// TierStats counts the hits and misses of a tier.
+type TierStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// TieredStats reports the hits and misses of both tiers, the remote tier is only queried on local misses.
+type TieredStats struct {
+	Local  TierStats
+	Remote TierStats
+	// InvalidationErrors counts the invalidations which couldn't be published after a successful write,
+	// the other nodes keep their stale copies for up to LocalTTL.
+	InvalidationErrors uint64
+}
+
+// TieredCacher is a cacher which keeps the values read from redis in a bounded in-memory LRU cache for a short time.
+// Writes go to redis and publish the key on a channel, so that every tiered cacher evicts its local copy.
+type TieredCacher struct {
+	// The counters are accessed atomically and come first to be 64-bit aligned.
+	stats TieredStats
+
+	local      *MemoryCacher
+	remote     *RedisCacher
+	options    TieredOptions
+	nodeID     string
+	subscriber *Subscriber
+	done       chan struct{}
+
+	// reads tracks the keys being read from redis, so that a value invalidated while it's read isn't kept locally.
+	readsMu sync.Mutex
+	reads   map[string]*pendingRead
+}
+
+// pendingRead counts the reads of a key in flight and the invalidations of the key since the first one started.
+type pendingRead struct {
+	readers int
+	version uint64
+}
+
+var (
+	_ Cacher        = (*TieredCacher)(nil)
+	_ ContextCacher = (*TieredCacher)(nil)
+)
+
+// NewTieredCacher creates a tiered cacher in front of remote, it must be closed after use.
+func NewTieredCacher(remote *RedisCacher, options TieredOptions) *TieredCacher {
+	options.setDefaults()
+	o := &TieredCacher{
+		local:      NewMemoryCacher(options.MaxEntries, options.LocalTTL),
+		remote:     remote,
+		options:    options,
+		nodeID:     cryptowrapper.GenUUID(),
+		subscriber: remote.NewSubscriber(0),
+		done:       make(chan struct{}),
+		reads:      make(map[string]*pendingRead),
+	}
+	o.subscriber.Subscribe(options.Channel)
+	go o.listen()
+	return o
+}
+
+// Close stops listening to the invalidations and releases the local tier.
+func (o *TieredCacher) Close() {
+	o.subscriber.Close()
+	<-o.done
+	o.local.Close()
+}
+
+// Stats gets the hits and misses of the tiers.
+func (o *TieredCacher) Stats() TieredStats {
+	return TieredStats{
+		Local: TierStats{
+			Hits:   atomic.LoadUint64(&o.stats.Local.Hits),
+			Misses: atomic.LoadUint64(&o.stats.Local.Misses),
+		},
+		Remote: TierStats{
+			Hits:   atomic.LoadUint64(&o.stats.Remote.Hits),
+			Misses: atomic.LoadUint64(&o.stats.Remote.Misses),
+		},
+		InvalidationErrors: atomic.LoadUint64(&o.stats.InvalidationErrors),
+	}
+}
+
+func (o *TieredCacher) count(stats *TierStats, hit bool) {
+	if hit {
+		atomic.AddUint64(&stats.Hits, 1)
+	} else {
+		atomic.AddUint64(&stats.Misses, 1)
+	}
+}
+
+// listen evicts the keys invalidated by the other nodes, the messages are "<node id> <key>".
+func (o *TieredCacher) listen() {
+	defer close(o.done)
+	for message := range o.subscriber.Messages() {
+		parts := strings.SplitN(string(message.Data), " ", 2)
+		if len(parts) == 2 && parts[0] != o.nodeID {
+			o.evict(parts[1])
+		}
+	}
+}
+
+// evict deletes key from the local tier, and discards the values of key being read from redis.
+func (o *TieredCacher) evict(key string) {
+	o.readsMu.Lock()
+	defer o.readsMu.Unlock()
+	if read, ok := o.reads[key]; ok {
+		read.version++
+	}
+	o.local.Del(key)
+}
+
+// startRead registers a read of key from redis, it returns the version endRead checks.
+func (o *TieredCacher) startRead(key string) uint64 {
+	o.readsMu.Lock()
+	defer o.readsMu.Unlock()
+	read, ok := o.reads[key]
+	if !ok {
+		read = &pendingRead{}
+		o.reads[key] = read
+	}
+	read.readers++
+	return read.version
+}
+
+// endRead ends a read of key from redis, keep stores the value locally unless key has been invalidated since the read started.
+func (o *TieredCacher) endRead(key string, version uint64, keep func()) {
+	o.readsMu.Lock()
+	defer o.readsMu.Unlock()
+	read := o.reads[key]
+	if keep != nil && read.version == version {
+		keep()
+	}
+	if read.readers--; read.readers == 0 {
+		delete(o.reads, key)
+	}
+}
+
+// invalidate evicts key from the local tier and tells the other nodes to do the same.
+// The write has succeeded already, so failing to publish doesn't fail it, it's counted in the stats.
+func (o *TieredCacher) invalidate(ctx context.Context, key string) {
+	o.evict(key)
+	if _, err := o.remote.PublishContext(ctx, o.options.Channel, o.nodeID+" "+key); err != nil {
+		atomic.AddUint64(&o.stats.InvalidationErrors, 1)
+	}
+}
+
+// localTTL gets how long a value read from redis can be kept locally given its remaining time to live in milliseconds.
+func (o *TieredCacher) localTTL(pttl int64) time.Duration {
+	if pttl >= 0 && time.Duration(pttl)*time.Millisecond < o.options.LocalTTL {
+		return time.Duration(pttl) * time.Millisecond
+	}
+	return o.options.LocalTTL
+}
+
+// Set a key value pair in redis and invalidates it in the local tiers.
+func (o *TieredCacher) Set(key string, value interface{}, expiration ...interface{}) error {
+	return o.SetContext(context.Background(), key, value, expiration...)
+}
+
+// SetContext sets a key value pair with context.
+func (o *TieredCacher) SetContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	if err := o.remote.SetContext(ctx, key, value, expiration...); err != nil {
+		return err
+	}
+	o.invalidate(ctx, key)
+	return nil
+}
+
+// Get a value from key, it's read from the local tier if it's there and from redis otherwise.
+func (o *TieredCacher) Get(key string) (interface{}, error) {
+	return o.GetContext(context.Background(), key)
+}
+
+// GetContext gets a value from key with context.
+func (o *TieredCacher) GetContext(ctx context.Context, key string) (interface{}, error) {
+	b, err := o.getBytes(ctx, key)
+	if b == nil || err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// getBytes reads key from the local tier, or from redis along with its time to live and keeps it locally.
+func (o *TieredCacher) getBytes(ctx context.Context, key string) ([]byte, error) {
+	if value, err := o.local.Get(key); err == nil && value != nil {
+		o.count(&o.stats.Local, true)
+		return value.([]byte), nil
+	}
+	o.count(&o.stats.Local, false)
+
+	var keep func()
+	version := o.startRead(key)
+	defer func() { o.endRead(key, version, keep) }()
+
+	pipeline := o.remote.Pipeline()
+	get := pipeline.Queue("GET", key)
+	pttl := pipeline.Queue("PTTL", key)
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if get.Reply == nil {
+		o.count(&o.stats.Remote, false)
+		return nil, nil
+	}
+	o.count(&o.stats.Remote, true)
+	b, err := redis.Bytes(get.Reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	ms, _ := redis.Int64(pttl.Reply, nil)
+	keep = func() { o.local.setBytes(key, argBytes(b), o.localTTL(ms)) }
+	return b, nil
+}
+
+// Del deletes key from redis and the local tiers.
+func (o *TieredCacher) Del(key string) {
+	o.DelContext(context.Background(), key)
+}
+
+// DelContext deletes key with context.
+func (o *TieredCacher) DelContext(ctx context.Context, key string) error {
+	if err := o.remote.DelContext(ctx, key); err != nil {
+		return err
+	}
+	o.invalidate(ctx, key)
+	return nil
+}
+
+// Scan through the keys in redis with given cursor, pattern and count.
+func (o *TieredCacher) Scan(cursor int, count int, pattern string) (nextCursor int, keys []string, err error) {
+	return o.ScanContext(context.Background(), cursor, count, pattern)
+}
+
+// ScanContext scans through the keys in redis with context.
+func (o *TieredCacher) ScanContext(ctx context.Context, cursor int, count int, pattern string) (nextCursor int, keys []string, err error) {
+	return o.remote.ScanContext(ctx, cursor, count, pattern)
+}
+
+// Expire sets a expiration time for key
+func (o *TieredCacher) Expire(key string, expiration int) error {
+	return o.ExpireContext(context.Background(), key, expiration)
+}
+
+// ExpireContext sets a expiration time for key with context.
+func (o *TieredCacher) ExpireContext(ctx context.Context, key string, expiration int) error {
+	if err := o.remote.ExpireContext(ctx, key, expiration); err != nil {
+		return err
+	}
+	o.invalidate(ctx, key)
+	return nil
+}
+
+// TTL gets the remaining seconds for key from redis.
+func (o *TieredCacher) TTL(key string) (int, error) {
+	return o.TTLContext(context.Background(), key)
+}
+
+// TTLContext gets the remaining seconds for key from redis with context.
+func (o *TieredCacher) TTLContext(ctx context.Context, key string) (int, error) {
+	return o.remote.TTLContext(ctx, key)
+}
+
+// SetGob sets a key value pair, value will be gob encoded
+func (o *TieredCacher) SetGob(key string, value interface{}, expiration ...interface{}) error {
+	return o.SetGobContext(context.Background(), key, value, expiration...)
+}
+
+// SetGobContext sets a key value pair with context, value will be gob encoded
+func (o *TieredCacher) SetGobContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	b, err := encodeGob(value)
+	if err == nil {
+		b, err = o.remote.compress(ctx, b)
+	}
+	if err != nil {
+		return err
+	}
+	return o.SetContext(ctx, key, b, expiration...)
+}
+
+// GetGob gets a gob encoded value from key
+func (o *TieredCacher) GetGob(key string) (interface{}, error) {
+	return o.GetGobContext(context.Background(), key)
+}
+
+// GetGobContext gets a gob encoded value from key with context.
+func (o *TieredCacher) GetGobContext(ctx context.Context, key string) (interface{}, error) {
+	b, err := o.getBytes(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, redis.ErrNil
+	}
+	if b, err = decompress(b); err != nil {
+		return nil, err
+	}
+	return decodeGob(b)
+}
+
+// SetJSON sets a key value pair, the value is a json
+func (o *TieredCacher) SetJSON(key string, value interface{}, expiration ...interface{}) error {
+	return o.SetJSONContext(context.Background(), key, value, expiration...)
+}
+
+// SetJSONContext sets a key value pair with context, the value is a json
+func (o *TieredCacher) SetJSONContext(ctx context.Context, key string, value interface{}, expiration ...interface{}) error {
+	b, err := json.Marshal(value)
+	if err == nil {
+		b, err = o.remote.compress(ctx, b)
+	}
+	if err != nil {
+		return err
+	}
+	return o.SetContext(ctx, key, b, expiration...)
+}
+
+// GetJSON gets a json value from key
+func (o *TieredCacher) GetJSON(key string) (jsonBytes []byte, err error) {
+	return o.GetJSONContext(context.Background(), key)
+}
+
+// GetJSONContext gets a json value from key with context.
+func (o *TieredCacher) GetJSONContext(ctx context.Context, key string) (jsonBytes []byte, err error) {
+	b, err := o.getBytes(ctx, key)
+	if err == nil && b == nil {
+		err = redis.ErrNil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decompress(b)
+}
+
+// HSet sets a key:value in hash set in redis and invalidates the hash in the local tiers.
+func (o *TieredCacher) HSet(hash, key string, value interface{}, expiration ...interface{}) error {
+	return o.HSetContext(context.Background(), hash, key, value, expiration...)
+}
+
+// HSetContext sets a key:value in hash set with context.
+func (o *TieredCacher) HSetContext(ctx context.Context, hash, key string, value interface{}, expiration ...interface{}) error {
+	if err := o.remote.HSetContext(ctx, hash, key, value, expiration...); err != nil {
+		return err
+	}
+	o.invalidate(ctx, hash)
+	return nil
+}
+
+// HGet gets a value from hash set, it's read from the local tier if it's there and from redis otherwise.
+func (o *TieredCacher) HGet(hash, key string) (interface{}, error) {
+	return o.HGetContext(context.Background(), hash, key)
+}
+
+// HGetContext gets a value from hash set with context.
+func (o *TieredCacher) HGetContext(ctx context.Context, hash, key string) (interface{}, error) {
+	if value, err := o.local.HGet(hash, key); err == nil && value != nil {
+		o.count(&o.stats.Local, true)
+		return value, nil
+	}
+	o.count(&o.stats.Local, false)
+
+	var keep func()
+	version := o.startRead(hash)
+	defer func() { o.endRead(hash, version, keep) }()
+
+	pipeline := o.remote.Pipeline()
+	hget := pipeline.Queue("HGET", hash, key)
+	pttl := pipeline.Queue("PTTL", hash)
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if hget.Reply == nil {
+		o.count(&o.stats.Remote, false)
+		return nil, nil
+	}
+	o.count(&o.stats.Remote, true)
+	b, err := redis.Bytes(hget.Reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	ms, _ := redis.Int64(pttl.Reply, nil)
+	keep = func() { o.local.hsetBytes(hash, key, argBytes(b), o.localTTL(ms)) }
+	return b, nil
+}
+
+// HINCRBY increases the value of key in hash set by value in redis and invalidates the hash in the local tiers.
+func (o *TieredCacher) HINCRBY(hash, key string, value interface{}) error {
+	return o.HINCRBYContext(context.Background(), hash, key, value)
+}
+
+// HINCRBYContext increases the value of key in hash set by value with context.
+func (o *TieredCacher) HINCRBYContext(ctx context.Context, hash, key string, value interface{}) error {
+	if err := o.remote.HINCRBYContext(ctx, hash, key, value); err != nil {
+		return err
+	}
+	o.invalidate(ctx, hash)
+	return nil
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTieredCacher(t *testing.T) {
+	GobRegister(&TestValues{})
+
+	Convey("Test Tiered Cacher\n", t, func() {
+		remote, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 3})
+		So(err, ShouldBeNil)
+		defer remote.ClosePool()
+		defer remote.Flush()
+
+		node1 := NewTieredCacher(remote, TieredOptions{Channel: "test:invalidations"})
+		defer node1.Close()
+		node2 := NewTieredCacher(remote, TieredOptions{Channel: "test:invalidations"})
+		defer node2.Close()
+		// Wait for both nodes to listen to the invalidations.
+		for i := 0; i < 100; i++ {
+			if n, _ := remote.Publish("test:invalidations", "ping"); n == 2 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		Convey("Reads Should Be Served By The Local Tier After The First One", func() {
+			So(node1.Set("testKey", "testValue"), ShouldBeNil)
+			for i := 0; i < 3; i++ {
+				value, err := redis.String(node1.Get("testKey"))
+				So(err, ShouldBeNil)
+				So(value, ShouldEqual, "testValue")
+			}
+			value, err := node1.Get("notExist")
+			So(err, ShouldBeNil)
+			So(value, ShouldBeNil)
+
+			stats := node1.Stats()
+			So(stats.Local, ShouldResemble, TierStats{Hits: 2, Misses: 2})
+			So(stats.Remote, ShouldResemble, TierStats{Hits: 1, Misses: 1})
+		})
+
+		Convey("Local Copies Should Not Outlive The Redis Expiration", func() {
+			remote.SetValue("testKey", "testValue", 100*time.Millisecond)
+			node1.GetJSON("testKey")
+			ttl, _ := node1.local.TTL("testKey")
+			So(ttl, ShouldEqual, 0)
+		})
+
+		Convey("Writes Should Invalidate The Local Copies Of Every Node", func() {
+			node1.Set("testKey", "old")
+			node1.HSet("testHash", "field", "old")
+			// The reads racing with the invalidations of the writes above aren't kept, so read until they are.
+			for i := 0; i < 100 && node2.local.Len() < 2; i++ {
+				node2.Get("testKey")
+				node2.HGet("testHash", "field")
+				time.Sleep(10 * time.Millisecond)
+			}
+			So(node2.local.Len(), ShouldEqual, 2)
+
+			node1.Set("testKey", "new")
+			node1.HSet("testHash", "field", "new")
+			for i := 0; i < 100 && node2.local.Len() > 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			value, _ := redis.String(node2.Get("testKey"))
+			So(value, ShouldEqual, "new")
+			value, _ = redis.String(node2.HGet("testHash", "field"))
+			So(value, ShouldEqual, "new")
+
+			node2.Del("testKey")
+			for i := 0; i < 100 && node1.local.Len() > 1; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			result, err := node1.Get("testKey")
+			So(err, ShouldBeNil)
+			So(result, ShouldBeNil)
+		})
+
+		Convey("A Value Invalidated While It's Read Should Not Be Kept Locally", func() {
+			kept := false
+			version := node1.startRead("testKey")
+			node1.evict("testKey")
+			node1.endRead("testKey", version, func() { kept = true })
+			So(kept, ShouldBeFalse)
+
+			version = node1.startRead("testKey")
+			node1.evict("otherKey")
+			node1.endRead("testKey", version, func() { kept = true })
+			So(kept, ShouldBeTrue)
+			So(node1.reads, ShouldBeEmpty)
+		})
+
+		Convey("Gob And JSON Values Should Round Trip", func() {
+			So(node1.SetGob("gob", &TestValues{A: "a", B: 1, C: 2}), ShouldBeNil)
+			value, err := node1.GetGob("gob")
+			So(err, ShouldBeNil)
+			So(value, ShouldResemble, &TestValues{A: "a", B: 1, C: 2})
+
+			So(node1.SetJSON("json", map[string]int{"a": 1}), ShouldBeNil)
+			b, err := node1.GetJSON("json")
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"a":1}`)
+			_, err = node1.GetJSON("notExist")
+			So(err, ShouldEqual, redis.ErrNil)
+		})
+	})
+}
@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Z is a member of a sorted set with its score.
+type Z struct {
+	Member string
+	Score  float64
+}
+
+// ZAddOptions defines the flags of ZAdd.
+type ZAddOptions struct {
+	// NX only adds new members, XX only updates existing members.
+	NX bool
+	XX bool
+	// GT only updates the scores which increase, LT only updates the scores which decrease, new members are still added.
+	GT bool
+	LT bool
+	// CH counts the changed members in the result instead of only the added ones.
+	CH bool
+}
+
+func (options ZAddOptions) args() redis.Args {
+	var args redis.Args
+	if options.NX {
+		args = append(args, "NX")
+	}
+	if options.XX {
+		args = append(args, "XX")
+	}
+	if options.GT {
+		args = append(args, "GT")
+	}
+	if options.LT {
+		args = append(args, "LT")
+	}
+	if options.CH {
+		args = append(args, "CH")
+	}
+	return args
+}
+
+// parseZ parses a reply of members followed by their scores.
+func parseZ(reply interface{}, err error) ([]Z, error) {
+	values, err := redis.Strings(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]Z, len(values)/2)
+	for i := range members {
+		members[i].Member = values[2*i]
+		if members[i].Score, err = strconv.ParseFloat(values[2*i+1], 64); err != nil {
+			return nil, err
+		}
+	}
+	return members, nil
+}
+
+// ZAdd adds members with their scores to a sorted set, or updates their scores if they exist.
+// It returns the number of added members, or of changed members with the CH option.
+func (o *RedisCacher) ZAdd(key string, options ZAddOptions, members ...Z) (int, error) {
+	return o.ZAddContext(context.Background(), key, options, members...)
+}
+
+// ZAddContext adds members with their scores to a sorted set with context.
+func (o *RedisCacher) ZAddContext(ctx context.Context, key string, options ZAddOptions, members ...Z) (int, error) {
+	args := redis.Args{key}.AddFlat(options.args())
+	for _, member := range members {
+		args = append(args, member.Score, member.Member)
+	}
+	return redis.Int(o.DoContext(ctx, "ZADD", args...))
+}
+
+// ZIncrBy increments the score of member by increment and returns the new score, a new member starts at 0.
+func (o *RedisCacher) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	return o.ZIncrByContext(context.Background(), key, increment, member)
+}
+
+// ZIncrByContext increments the score of member by increment with context.
+func (o *RedisCacher) ZIncrByContext(ctx context.Context, key string, increment float64, member string) (float64, error) {
+	return redis.Float64(o.DoContext(ctx, "ZINCRBY", key, increment, member))
+}
+
+// ZScore gets the score of member, it returns redis.ErrNil if the member doesn't exist.
+func (o *RedisCacher) ZScore(key, member string) (float64, error) {
+	return o.ZScoreContext(context.Background(), key, member)
+}
+
+// ZScoreContext gets the score of member with context.
+func (o *RedisCacher) ZScoreContext(ctx context.Context, key, member string) (float64, error) {
+	return redis.Float64(o.DoContext(ctx, "ZSCORE", key, member))
+}
+
+// ZCard gets the number of members in a sorted set.
+func (o *RedisCacher) ZCard(key string) (int, error) {
+	return o.ZCardContext(context.Background(), key)
+}
+
+// ZCardContext gets the number of members in a sorted set with context.
+func (o *RedisCacher) ZCardContext(ctx context.Context, key string) (int, error) {
+	return redis.Int(o.DoContext(ctx, "ZCARD", key))
+}
+
+// ZRange gets the members from index start to stop inclusive with their scores, ordered from the lowest score.
+// Negative indexes count from the end, -1 is the last member.
+func (o *RedisCacher) ZRange(key string, start, stop int) ([]Z, error) {
+	return o.ZRangeContext(context.Background(), key, start, stop)
+}
+
+// ZRangeContext gets the members from index start to stop inclusive with context.
+func (o *RedisCacher) ZRangeContext(ctx context.Context, key string, start, stop int) ([]Z, error) {
+	return parseZ(o.DoContext(ctx, "ZRANGE", key, start, stop, "WITHSCORES"))
+}
+
+// ZRevRange gets the members from index start to stop inclusive with their scores, ordered from the highest score.
+func (o *RedisCacher) ZRevRange(key string, start, stop int) ([]Z, error) {
+	return o.ZRevRangeContext(context.Background(), key, start, stop)
+}
+
+// ZRevRangeContext gets the members from index start to stop inclusive with context, ordered from the highest score.
+func (o *RedisCacher) ZRevRangeContext(ctx context.Context, key string, start, stop int) ([]Z, error) {
+	return parseZ(o.DoContext(ctx, "ZREVRANGE", key, start, stop, "WITHSCORES"))
+}
+
+// ZRangeByScore gets the members with a score between min and max with their scores, ordered from the lowest score.
+// min and max are inclusive unless they're prefixed by "(", and can be "-inf" and "+inf".
+// It skips offset members and returns at most count members, count <= 0 means no limit.
+func (o *RedisCacher) ZRangeByScore(key, min, max string, offset, count int) ([]Z, error) {
+	return o.ZRangeByScoreContext(context.Background(), key, min, max, offset, count)
+}
+
+// ZRangeByScoreContext gets the members with a score between min and max with context.
+func (o *RedisCacher) ZRangeByScoreContext(ctx context.Context, key, min, max string, offset, count int) ([]Z, error) {
+	args := redis.Args{key, min, max, "WITHSCORES"}
+	if count > 0 {
+		args = append(args, "LIMIT", offset, count)
+	} else if offset > 0 {
+		args = append(args, "LIMIT", offset, -1)
+	}
+	return parseZ(o.DoContext(ctx, "ZRANGEBYSCORE", args...))
+}
+
+// ZRank gets the index of member ordered from the lowest score, it returns redis.ErrNil if the member doesn't exist.
+func (o *RedisCacher) ZRank(key, member string) (int, error) {
+	return o.ZRankContext(context.Background(), key, member)
+}
+
+// ZRankContext gets the index of member ordered from the lowest score with context.
+func (o *RedisCacher) ZRankContext(ctx context.Context, key, member string) (int, error) {
+	return redis.Int(o.DoContext(ctx, "ZRANK", key, member))
+}
+
+// ZRevRank gets the index of member ordered from the highest score, it returns redis.ErrNil if the member doesn't exist.
+func (o *RedisCacher) ZRevRank(key, member string) (int, error) {
+	return o.ZRevRankContext(context.Background(), key, member)
+}
+
+// ZRevRankContext gets the index of member ordered from the highest score with context.
+func (o *RedisCacher) ZRevRankContext(ctx context.Context, key, member string) (int, error) {
+	return redis.Int(o.DoContext(ctx, "ZREVRANK", key, member))
+}
+
+// ZRem removes members from a sorted set and returns the number of removed members.
+func (o *RedisCacher) ZRem(key string, members ...string) (int, error) {
+	return o.ZRemContext(context.Background(), key, members...)
+}
+
+// ZRemContext removes members from a sorted set with context.
+func (o *RedisCacher) ZRemContext(ctx context.Context, key string, members ...string) (int, error) {
+	return redis.Int(o.DoContext(ctx, "ZREM", redis.Args{key}.AddFlat(members)...))
+}
+
+// ZRemRangeByScore removes the members with a score between min and max and returns the number of removed members.
+// The bounds are given the same way as ZRangeByScore.
+func (o *RedisCacher) ZRemRangeByScore(key, min, max string) (int, error) {
+	return o.ZRemRangeByScoreContext(context.Background(), key, min, max)
+}
+
+// ZRemRangeByScoreContext removes the members with a score between min and max with context.
+func (o *RedisCacher) ZRemRangeByScoreContext(ctx context.Context, key, min, max string) (int, error) {
+	return redis.Int(o.DoContext(ctx, "ZREMRANGEBYSCORE", key, min, max))
+}
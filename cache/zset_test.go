@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSortedSet(t *testing.T) {
+	Convey("Test Sorted Sets\n", t, func() {
+		cacher, err := NewRedisCacherWithOptions(RedisOptions{Addr: "127.0.0.1:6379", DB: 2})
+		So(err, ShouldBeNil)
+		defer cacher.ClosePool()
+		defer cacher.Flush()
+
+		n, err := cacher.ZAdd("zset", ZAddOptions{}, Z{"a", 1}, Z{"b", 2}, Z{"c", 3})
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 3)
+
+		Convey("ZAdd Should Respect The Options", func() {
+			n, err := cacher.ZAdd("zset", ZAddOptions{NX: true}, Z{"a", 10}, Z{"d", 4})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			score, _ := cacher.ZScore("zset", "a")
+			So(score, ShouldEqual, 1)
+
+			n, _ = cacher.ZAdd("zset", ZAddOptions{XX: true, CH: true}, Z{"a", 5}, Z{"e", 5})
+			So(n, ShouldEqual, 1)
+			_, err = cacher.ZScore("zset", "e")
+			So(err, ShouldEqual, redis.ErrNil)
+
+			cacher.ZAdd("zset", ZAddOptions{GT: true}, Z{"a", 2}, Z{"b", 20})
+			score, _ = cacher.ZScore("zset", "a")
+			So(score, ShouldEqual, 5)
+			score, _ = cacher.ZScore("zset", "b")
+			So(score, ShouldEqual, 20)
+
+			cacher.ZAdd("zset", ZAddOptions{LT: true}, Z{"c", 0})
+			score, _ = cacher.ZScore("zset", "c")
+			So(score, ShouldEqual, 0)
+		})
+
+		Convey("ZIncrBy Should Increment The Score", func() {
+			score, err := cacher.ZIncrBy("zset", 2.5, "a")
+			So(err, ShouldBeNil)
+			So(score, ShouldEqual, 3.5)
+			score, _ = cacher.ZIncrBy("zset", 1, "new")
+			So(score, ShouldEqual, 1)
+			count, _ := cacher.ZCard("zset")
+			So(count, ShouldEqual, 4)
+		})
+
+		Convey("Ranges Should Return The Members With Their Scores", func() {
+			members, err := cacher.ZRange("zset", 0, -1)
+			So(err, ShouldBeNil)
+			So(members, ShouldResemble, []Z{{"a", 1}, {"b", 2}, {"c", 3}})
+			members, _ = cacher.ZRevRange("zset", 0, 1)
+			So(members, ShouldResemble, []Z{{"c", 3}, {"b", 2}})
+
+			members, _ = cacher.ZRangeByScore("zset", "(1", "+inf", 0, 0)
+			So(members, ShouldResemble, []Z{{"b", 2}, {"c", 3}})
+			members, _ = cacher.ZRangeByScore("zset", "-inf", "+inf", 1, 1)
+			So(members, ShouldResemble, []Z{{"b", 2}})
+			members, _ = cacher.ZRangeByScore("zset", "-inf", "+inf", 1, 0)
+			So(members, ShouldResemble, []Z{{"b", 2}, {"c", 3}})
+		})
+
+		Convey("Ranks Should Be Ordered By Score", func() {
+			rank, err := cacher.ZRank("zset", "a")
+			So(err, ShouldBeNil)
+			So(rank, ShouldEqual, 0)
+			rank, _ = cacher.ZRevRank("zset", "a")
+			So(rank, ShouldEqual, 2)
+			_, err = cacher.ZRank("zset", "notExist")
+			So(err, ShouldEqual, redis.ErrNil)
+		})
+
+		Convey("Members Should Be Removed", func() {
+			n, err := cacher.ZRem("zset", "a", "notExist")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			n, err = cacher.ZRemRangeByScore("zset", "-inf", "2")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			members, _ := cacher.ZRange("zset", 0, -1)
+			So(members, ShouldResemble, []Z{{"c", 3}})
+		})
+	})
+}
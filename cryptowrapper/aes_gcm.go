@@ -0,0 +1,48 @@
+package cryptowrapper
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// AESGCMEncrypt performs a AES encryption in GCM mode, the additional data is authenticated but not encrypted.
+// The random nonce is prepended to the output.
+func AESGCMEncrypt(key []byte, input []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(input)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, input, additionalData), nil
+}
+
+// AESGCMDecrypt performs a AES decryption in GCM mode, it fails if the input or the additional data has been altered.
+func AESGCMDecrypt(key []byte, input []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(input) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("Input data size is wrong")
+	}
+
+	nonce := input[:aead.NonceSize()]
+	return aead.Open(nil, nonce, input[aead.NonceSize():], additionalData)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
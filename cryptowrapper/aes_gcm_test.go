@@ -0,0 +1,53 @@
+package cryptowrapper
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAESGCM(t *testing.T) {
+	key := []byte("Hello I am a 32 byte key........")
+	plainMessage := []byte("我们")
+	additionalData := []byte("user:1")
+
+	Convey("Test AESGCMEncrypt And AESGCMDecrypt", t, func() {
+		encryptedMessage, err := AESGCMEncrypt(key, plainMessage, additionalData)
+		So(err, ShouldBeNil)
+
+		Convey("AESGCMDecrypt With The Correct Key And Additional Data Should Be Successful", func() {
+			decryptedMessage, err := AESGCMDecrypt(key, encryptedMessage, additionalData)
+			So(err, ShouldBeNil)
+			So(decryptedMessage, ShouldResemble, plainMessage)
+		})
+
+		Convey("AESGCMEncrypt Twice Should Give Different Messages", func() {
+			other, err := AESGCMEncrypt(key, plainMessage, additionalData)
+			So(err, ShouldBeNil)
+			So(other, ShouldNotResemble, encryptedMessage)
+		})
+
+		Convey("AESGCMDecrypt With The Wrong Additional Data Should Not Be Successful", func() {
+			_, err := AESGCMDecrypt(key, encryptedMessage, []byte("user:2"))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("AESGCMDecrypt With The Wrong Key Should Not Be Successful", func() {
+			_, err := AESGCMDecrypt(RandBytes(32), encryptedMessage, additionalData)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("AESGCMDecrypt An Altered Or Short Message Should Not Be Successful", func() {
+			encryptedMessage[len(encryptedMessage)-1] ^= 1
+			_, err := AESGCMDecrypt(key, encryptedMessage, additionalData)
+			So(err, ShouldNotBeNil)
+			_, err = AESGCMDecrypt(key, RandBytes(20), additionalData)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("AESGCMEncrypt With Wrong Key Size Should Not Be Successful", func() {
+			_, err := AESGCMEncrypt(key[:31], plainMessage, additionalData)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}